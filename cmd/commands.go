@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"open-library-explorer/configs"
+	"open-library-explorer/internal/catalog"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// runCommand runs a one-off maintenance command instead of the server.
+func runCommand(cfg configs.Config, stores store.Stores, name string, args []string) {
+	switch name {
+	case "import":
+		runImport(stores, args)
+	case "export":
+		runExport(stores, args)
+	case "migrate":
+		runMigration(stores, args)
+	case "enrich":
+		runEnrich(cfg, stores, args)
+	default:
+		log.Fatalf("Unknown command %q, expected import, export, migrate or enrich", name)
+	}
+}
+
+// import [-format marc|marcxml|csv] [-copies n] file
+// The format defaults to the file extension (.mrc, .xml or .csv). The report
+// is written to stdout as JSON.
+func runImport(stores store.Stores, args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "marc, marcxml or csv")
+	copies := fs.Int("copies", 0, "copies to create per imported book")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: import [-format marc|marcxml|csv] [-copies n] file")
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		*format = formatFromExtension(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := catalog.Parse(*format, f)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	importer := services.CatalogImporter{
+		Books:       stores.Books,
+		Copies:      stores.Copies,
+		AuditLogger: utils.Logger{Store: stores.Audit},
+	}
+	report := importer.Import(context.Background(), records, *copies)
+	indexImported(context.Background(), newSuggester(stores), stores.Books, report)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	fmt.Fprintf(os.Stderr, "created %d, updated %d, rejected %d, copies %d\n",
+		report.Created, report.Updated, report.Rejected, report.CopiesCreated)
+}
+
+// indexImported refreshes the autocomplete entries of every book the import
+// accepted, as POST /books/import does. The books are already stored, so a
+// failure is logged and the rest still indexed.
+func indexImported(ctx context.Context, suggester *services.Suggester, books store.BookStore, report services.ImportReport) {
+	for _, result := range report.Records {
+		if result.Status == services.ImportRejected {
+			continue
+		}
+		if err := suggester.Refresh(ctx, books, result.ISBN); err != nil {
+			log.Printf("Failed to index suggestions for book %s: %v", result.ISBN, err)
+		}
+	}
+}
+
+func newSuggester(stores store.Stores) *services.Suggester {
+	return &services.Suggester{Suggestions: stores.Suggestions, Copies: stores.Copies, Loans: stores.Loans}
+}
+
+func formatFromExtension(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".mrc"), strings.HasSuffix(path, ".marc"):
+		return catalog.FormatMARC
+	case strings.HasSuffix(path, ".xml"):
+		return catalog.FormatMARCXML
+	case strings.HasSuffix(path, ".csv"):
+		return catalog.FormatCSV
+	}
+	return ""
+}
+
+// export [-format marcxml|csv|ndjson] [-copies] [-o file]
+// The catalog is written to stdout unless -o names a file.
+func runExport(stores store.Stores, args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", catalog.FormatNDJSON, "marcxml, csv or ndjson")
+	withCopies := fs.Bool("copies", false, "include copies and their statuses")
+	output := fs.String("o", "", "file to write instead of stdout")
+	fs.Parse(args)
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	writer, err := catalog.NewWriter(*format, out, *withCopies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter := services.CatalogExporter{Books: stores.Books, Copies: stores.Copies}
+	written, err := exporter.Export(context.Background(), writer, *withCopies)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Fatalf("Export failed after %d books: %v", written, err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d books\n", written)
+}
+
+// enrich [dump...]
+// Fills in missing metadata of every book in the catalog. The dumps default
+// to ENRICHMENT_DUMPS; only editions of books in the catalog are loaded.
+func runEnrich(cfg configs.Config, stores store.Stores, args []string) {
+	if len(args) > 0 {
+		cfg.EnrichmentDumps = args
+	}
+
+	ctx := context.Background()
+	books, err := stores.Books.Find(ctx, store.BookFilter{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	catalogued := make(map[string]bool, len(books))
+	for _, book := range books {
+		catalogued[book.ISBN] = true
+	}
+
+	provider, err := metadataProvider(cfg, func(isbn string) bool { return catalogued[isbn] })
+	if err != nil {
+		log.Fatal(err)
+	}
+	if provider == nil {
+		log.Fatal("usage: enrich dump... (or set ENRICHMENT_DUMPS or ENRICHMENT_URL)")
+	}
+
+	enricher := services.Enricher{
+		Books:       stores.Books,
+		Provider:    provider,
+		AuditLogger: utils.Logger{Store: stores.Audit},
+	}
+	report, err := enricher.EnrichAll(ctx)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if err != nil {
+		log.Fatalf("Enrichment failed: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "enriched %d of %d books, %d not found, %d failed\n",
+		report.Enriched, report.Books, report.NotFound, report.Failed)
+	rebuildSuggestions(ctx, stores)
+}
+
+// rebuildSuggestions reindexes the autocomplete entries of the whole catalog
+// after a command that changed titles or contributors.
+func rebuildSuggestions(ctx context.Context, stores store.Stores) {
+	indexed, err := newSuggester(stores).Rebuild(ctx, stores.Books)
+	if err != nil {
+		log.Fatalf("Failed to rebuild suggestions after %d books: %v", indexed, err)
+	}
+	fmt.Fprintf(os.Stderr, "reindexed suggestions of %d books\n", indexed)
+}
+
+// migrations are one-off data fixes, run as migrate <name>. Each must be safe
+// to run more than once.
+var migrations = map[string]func(ctx context.Context, stores store.Stores) (any, error){
+	"isbn13": func(ctx context.Context, stores store.Stores) (any, error) {
+		return services.NormalizeCatalogISBNs(ctx, stores)
+	},
+	"contributors": func(ctx context.Context, stores store.Stores) (any, error) {
+		report, err := services.MigrateContributors(ctx, stores.Books)
+		if err == nil {
+			rebuildSuggestions(ctx, stores)
+		}
+		return report, err
+	},
+	"suggestions": func(ctx context.Context, stores store.Stores) (any, error) {
+		indexed, err := newSuggester(stores).Rebuild(ctx, stores.Books)
+		return map[string]int{"indexed": indexed}, err
+	},
+	"cardnumbers": func(ctx context.Context, stores store.Stores) (any, error) {
+		return services.AssignCardNumbers(ctx, stores.Members)
+	},
+	"memberstatus": func(ctx context.Context, stores store.Stores) (any, error) {
+		return services.MigrateMemberStatuses(ctx, stores.Members)
+	},
+}
+
+func runMigration(stores store.Stores, args []string) {
+	if len(args) != 1 || migrations[args[0]] == nil {
+		names := make([]string, 0, len(migrations))
+		for name := range migrations {
+			names = append(names, name)
+		}
+		log.Fatalf("usage: migrate <name>, where name is one of %s", strings.Join(names, ", "))
+	}
+
+	report, err := migrations[args[0]](context.Background(), stores)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if err != nil {
+		log.Fatalf("Migration %s failed: %v", args[0], err)
+	}
+}
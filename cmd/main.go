@@ -2,115 +2,85 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"open-library-explorer/internal/daemon"
-	"open-library-explorer/internal/handlers"
-	"open-library-explorer/internal/middleware"
+	"open-library-explorer/internal/enrichment"
+	"open-library-explorer/internal/exporter"
+	"open-library-explorer/internal/router"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/mux"
-
 	"open-library-explorer/configs"
 	"open-library-explorer/internal/db"
 )
 
 func main() {
 	cfg := configs.LoadConfig()
-	db.Connect(cfg.MongoURI)
 	utils.InitJwtSecret(cfg.JWTSecret)
 
-	logExporter := daemon.LogExporter{
-		Coll: db.GetCollection(configs.LoadConfig().DBName, "audit_logs"),
-	}
-	logExporter.InitLogExporter()
+	stores := openStores(cfg)
 
-	r := mux.NewRouter()
-	r.Use(middleware.JSONMiddleware)
-	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "OK")
-	})
+	if len(os.Args) > 1 {
+		runCommand(cfg, stores, os.Args[1], os.Args[2:])
+		return
+	}
 
-	authHandler := &handlers.AuthHandler{
-		ConfigCreds: struct {
-			UserId       string
-			Username     string
-			UserPassword string
-		}{UserId: cfg.UserId, Username: cfg.UserName, UserPassword: cfg.UserPassword},
+	if err := services.EnsureAdmin(context.Background(), stores.Users, cfg.AdminUserName, cfg.AdminPassword); err != nil {
+		log.Fatal("Failed to bootstrap admin account: ", err)
 	}
-	r.HandleFunc("/login", authHandler.Login).Methods("POST")
-
-	auditCol := db.GetCollection(configs.LoadConfig().DBName, "audit_logs")
-	auditLogger := utils.Logger{Collection: auditCol}
-
-	bookColl := db.GetCollection(cfg.DBName, "books")
-	copyColl := db.GetCollection(cfg.DBName, "copies")
-
-	bookHandler := handlers.NewBookHandler(bookColl, copyColl, auditLogger)
-
-	booksRouter := r.PathPrefix("/").Subrouter()
-	booksRouter.Use(middleware.JWTAuthMiddleware)
-
-	booksRouter.HandleFunc("/books", bookHandler.AddBook).Methods("POST")
-	booksRouter.HandleFunc("/books", bookHandler.GetBooks).Methods("GET")
-	booksRouter.HandleFunc("/books/search", bookHandler.SearchBooks).Methods("GET")
-	booksRouter.HandleFunc("/books/{isbn}", bookHandler.GetBook).Methods("GET")
-	booksRouter.HandleFunc("/books/{isbn}", bookHandler.UpdateBook).Methods("PUT")
-	booksRouter.HandleFunc("/books/{isbn}", bookHandler.DeleteBook).Methods("DELETE")
-
-	copyColl = db.GetCollection(cfg.DBName, "copies")
-	copyHandler := handlers.CopyHandler{Collection: copyColl, AuditLogger: auditLogger}
-
-	r.HandleFunc("/copies", copyHandler.AddCopy).Methods("POST")
-	r.HandleFunc("/copies", copyHandler.GetCopies).Methods("GET")
-	r.HandleFunc("/copies/{barcode}", copyHandler.UpdateCopy).Methods("PUT")
-	r.HandleFunc("/copies/{barcode}", copyHandler.DeleteCopy).Methods("DELETE")
-
-	memberColl := db.GetCollection(cfg.DBName, "members")
-	memberHandler := handlers.NewMemberHandler(memberColl, auditLogger)
-
-	r.HandleFunc("/members", memberHandler.RegisterMember).Methods("POST")
-	r.HandleFunc("/members/{id}", memberHandler.UpdateMember).Methods("PUT")
-	r.HandleFunc("/members/{id}/deactivate", memberHandler.DeactivateMember).Methods("PATCH")
-
-	loanHandler := &handlers.LoanHandler{
-		MemberCol:      db.GetCollection(cfg.DBName, "members"),
-		CopyCol:        db.GetCollection(cfg.DBName, "copies"),
-		LoanCol:        db.GetCollection(cfg.DBName, "loans"),
-		ReservationCol: db.GetCollection(cfg.DBName, "holds"),
-		AuditLogger:    auditLogger,
-		Config: struct {
-			PremiumMemberRenewalDays  int
-			StandardMemberRenewalDays int
-		}{PremiumMemberRenewalDays: cfg.PremiumMembersRenewalDays, StandardMemberRenewalDays: cfg.StandardMembersRenewalDays},
+
+	auditSink, err := exporter.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up audit log export: ", err)
 	}
+	defer auditSink.Close()
 
-	r.HandleFunc("/checkout", loanHandler.CheckOut).Methods("POST")
-	r.HandleFunc("/checkin", loanHandler.CheckIn).Methods("POST")
-	r.HandleFunc("/loan/renew", loanHandler.RenewLoan).Methods("POST")
-	r.HandleFunc("/loans/overdue", loanHandler.GetOverdueLoans).Methods("GET")
+	logExporter := &daemon.LogExporter{
+		Store:       stores.Audit,
+		Exporter:    auditSink,
+		BatchSize:   cfg.ExportBatchSize,
+		MaxAttempts: cfg.ExportMaxAttempts,
+	}
+	holdExpirer := &daemon.HoldExpirer{
+		Queue: &services.HoldQueue{
+			Holds:       stores.Holds,
+			Copies:      stores.Copies,
+			AuditLogger: utils.Logger{Store: stores.Audit},
+			PickupDays:  cfg.HoldPickupDays,
+		},
+	}
 
-	reservationHandler := &handlers.ReservationHandler{
-		ReservationCol: db.GetCollection(cfg.DBName, "holds"),
-		CopyCol:        db.GetCollection(cfg.DBName, "copies"),
-		MemberCol:      db.GetCollection(cfg.DBName, "members"),
-		AuditLogger:    auditLogger,
+	membershipExpirer := &daemon.MembershipExpirer{
+		Membership: &services.Membership{
+			Members:     stores.Members,
+			AuditLogger: utils.Logger{Store: stores.Audit},
+			NoticeDays:  cfg.MembershipNoticeDays,
+		},
 	}
 
-	r.HandleFunc("/holds/place", reservationHandler.PlaceHold).Methods("POST")
+	supervisor := daemon.NewSupervisor()
+	supervisor.Add(daemon.Job{
+		Name:     "audit-export",
+		Interval: time.Duration(cfg.ExportIntervalSeconds) * time.Second,
+		Run:      logExporter.Run,
+	})
+	supervisor.Add(daemon.Job{Name: "hold-expiry", Interval: time.Minute, Run: holdExpirer.Run})
+	supervisor.Add(daemon.Job{Name: "membership-expiry", Interval: 24 * time.Hour, Run: membershipExpirer.Run})
+	supervisor.Start(context.Background())
 
-	metricsHandler := handlers.MetricsHandler{
-		CopyCol:   db.GetCollection(cfg.DBName, "copies"),
-		MemberCol: db.GetCollection(cfg.DBName, "members"),
-		LoanCol:   db.GetCollection(cfg.DBName, "loans"),
-		Config:    struct{ FineRate float64 }{FineRate: cfg.FineRate},
+	metadata, err := enrichment.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up enrichment: ", err)
 	}
 
-	r.HandleFunc("/admin/metrics", metricsHandler.GetMetrics).Methods("GET")
+	r := router.New(cfg, stores, supervisor, metadata)
 
 	var server = http.Server{
 		Addr:    ":" + cfg.Port,
@@ -119,19 +89,50 @@ func main() {
 
 	go func() {
 		log.Println("Server starting on port", cfg.Port)
-		log.Fatal(server.ListenAndServe())
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
 	}()
 
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 
 	log.Println("Shutting down gracefully...")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Stop taking requests first so no new audit entries arrive, then let the
+	// daemons finish their current run
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Graceful shutdown failed: %v", err)
+		log.Printf("Graceful shutdown failed: %v", err)
+	}
+	if err := supervisor.Shutdown(ctx); err != nil {
+		log.Printf("Daemons did not stop in time: %v", err)
 	}
 	log.Println("Server shut down.")
 }
+
+func openStores(cfg configs.Config) store.Stores {
+	switch cfg.StorageBackend {
+	case configs.StorageMemory:
+		log.Println("Using in-memory storage")
+		return store.NewMemoryStores()
+	default:
+		db.Connect(cfg.MongoURI)
+		return store.NewMongoStores(db.GetDatabase(cfg.DBName))
+	}
+}
+
+// metadataProvider returns the provider the enrich command uses, or nil when
+// enrichment is not configured. keep limits which editions of the dumps are
+// held in memory.
+func metadataProvider(cfg configs.Config, keep func(isbn string) bool) (enrichment.Provider, error) {
+	switch {
+	case len(cfg.EnrichmentDumps) > 0:
+		return enrichment.LoadDumpFiles(cfg.EnrichmentDumps, keep)
+	case cfg.EnrichmentURL != "":
+		return &enrichment.HTTPProvider{BaseURL: cfg.EnrichmentURL}, nil
+	}
+	return nil, nil
+}
@@ -4,21 +4,47 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
+)
+
 type Config struct {
+	StorageBackend             string
 	Port                       string
 	MongoURI                   string
 	DBName                     string
 	JWTSecret                  string
 	FineRate                   float64
-	UserId                     string
-	UserName                   string
-	UserPassword               string
+	FineBlockThreshold         float64
+	ReplacementCost            float64 // charged for a lost or damaged copy without its own cost
+	AdminUserName              string  // bootstrap admin, created when there are no users
+	AdminPassword              string
 	PremiumMembersRenewalDays  int
 	StandardMembersRenewalDays int
+	HoldPickupDays             int
+	StandardMembershipMonths   int // how long a membership runs, from registration or renewal
+	PremiumMembershipMonths    int
+	StandardMembershipFee      float64 // charged on renewal
+	PremiumMembershipFee       float64
+	MembershipNoticeDays       int    // members are told this many days before their membership expires
+	ExportSink                 string // stdout, file, webhook or syslog
+	ExportIntervalSeconds      int
+	ExportBatchSize            int
+	ExportMaxAttempts          int // failed exports before an entry is dead-lettered
+	ExportFilePath             string
+	ExportFileMaxBytes         int64
+	ExportWebhookURL           string
+	ExportWebhookRetries       int
+	ExportSyslogNetwork        string
+	ExportSyslogAddr           string
+	EnrichmentDumps            []string // Open Library dump files, editions first
+	EnrichmentURL              string   // live Open Library API, used when there are no dumps
 }
 
 func LoadConfig() Config {
@@ -36,21 +62,109 @@ func LoadConfig() Config {
 		}
 	}
 
+	fineBlockThreshold := 10.0
+
+	if val := os.Getenv("FINE_BLOCK_THRESHOLD"); val != "" {
+		_, err := fmt.Sscanf(val, "%f", &fineBlockThreshold)
+		if err != nil {
+			log.Fatalf("Invalid FINE_BLOCK_THRESHOLD: %v", err)
+		}
+	}
+
+	replacementCost := 25.0
+
+	if val := os.Getenv("REPLACEMENT_COST"); val != "" {
+		_, err := fmt.Sscanf(val, "%f", &replacementCost)
+		if err != nil {
+			log.Fatalf("Invalid REPLACEMENT_COST: %v", err)
+		}
+	}
+
 	var premiumMemberRenewalDays, standardMemberRenewalDays int
 
 	fmt.Sscanf(os.Getenv("PREMIUM_MEMBER_RENEWAL_DAYS"), "%d", &premiumMemberRenewalDays)
 	fmt.Sscanf(os.Getenv("PREMIUM_MEMBER_RENEWAL_DAYS"), "%d", &standardMemberRenewalDays)
 
+	var holdPickupDays int
+	fmt.Sscanf(os.Getenv("HOLD_PICKUP_DAYS"), "%d", &holdPickupDays)
+
+	standardMembershipMonths, premiumMembershipMonths, membershipNoticeDays := 12, 12, 14
+	fmt.Sscanf(os.Getenv("STANDARD_MEMBERSHIP_MONTHS"), "%d", &standardMembershipMonths)
+	fmt.Sscanf(os.Getenv("PREMIUM_MEMBERSHIP_MONTHS"), "%d", &premiumMembershipMonths)
+	fmt.Sscanf(os.Getenv("MEMBERSHIP_NOTICE_DAYS"), "%d", &membershipNoticeDays)
+
+	var standardMembershipFee, premiumMembershipFee float64
+	fmt.Sscanf(os.Getenv("STANDARD_MEMBERSHIP_FEE"), "%f", &standardMembershipFee)
+	fmt.Sscanf(os.Getenv("PREMIUM_MEMBERSHIP_FEE"), "%f", &premiumMembershipFee)
+
+	exportIntervalSeconds, exportBatchSize, exportMaxAttempts := 30, 100, 5
+	fmt.Sscanf(os.Getenv("AUDIT_EXPORT_INTERVAL_SECONDS"), "%d", &exportIntervalSeconds)
+	fmt.Sscanf(os.Getenv("AUDIT_EXPORT_BATCH_SIZE"), "%d", &exportBatchSize)
+	fmt.Sscanf(os.Getenv("AUDIT_EXPORT_MAX_ATTEMPTS"), "%d", &exportMaxAttempts)
+
+	var exportFileMaxBytes int64
+	fmt.Sscanf(os.Getenv("AUDIT_EXPORT_FILE_MAX_BYTES"), "%d", &exportFileMaxBytes)
+
+	exportWebhookRetries := 3
+	fmt.Sscanf(os.Getenv("AUDIT_EXPORT_WEBHOOK_RETRIES"), "%d", &exportWebhookRetries)
+
+	exportFilePath := os.Getenv("AUDIT_EXPORT_FILE")
+	if exportFilePath == "" {
+		exportFilePath = "audit.ndjson"
+	}
+
+	var enrichmentDumps []string
+	for _, path := range strings.Split(os.Getenv("ENRICHMENT_DUMPS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			enrichmentDumps = append(enrichmentDumps, path)
+		}
+	}
+
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if storageBackend == "" {
+		storageBackend = StorageMongo
+	}
+
 	return Config{
+		StorageBackend:             storageBackend,
 		Port:                       os.Getenv("PORT"),
 		MongoURI:                   os.Getenv("MONGO_URI"),
 		DBName:                     os.Getenv("DB_NAME"),
 		JWTSecret:                  os.Getenv("JWT_SECRET"),
 		FineRate:                   fineRate,
-		UserId:                     os.Getenv("HARD_CODED_USER_ID"),
-		UserName:                   os.Getenv("HARD_CODED_USER_NAME"),
-		UserPassword:               os.Getenv("HARD_CODED_USER_PASSWORD"),
+		FineBlockThreshold:         fineBlockThreshold,
+		ReplacementCost:            replacementCost,
+		AdminUserName:              envOr("BOOTSTRAP_ADMIN_USERNAME", "HARD_CODED_USER_NAME"),
+		AdminPassword:              envOr("BOOTSTRAP_ADMIN_PASSWORD", "HARD_CODED_USER_PASSWORD"),
 		PremiumMembersRenewalDays:  premiumMemberRenewalDays,
 		StandardMembersRenewalDays: standardMemberRenewalDays,
+		HoldPickupDays:             holdPickupDays,
+		StandardMembershipMonths:   standardMembershipMonths,
+		PremiumMembershipMonths:    premiumMembershipMonths,
+		StandardMembershipFee:      standardMembershipFee,
+		PremiumMembershipFee:       premiumMembershipFee,
+		MembershipNoticeDays:       membershipNoticeDays,
+		ExportSink:                 os.Getenv("AUDIT_EXPORT_SINK"),
+		ExportIntervalSeconds:      exportIntervalSeconds,
+		ExportBatchSize:            exportBatchSize,
+		ExportMaxAttempts:          exportMaxAttempts,
+		ExportFilePath:             exportFilePath,
+		ExportFileMaxBytes:         exportFileMaxBytes,
+		ExportWebhookURL:           os.Getenv("AUDIT_EXPORT_WEBHOOK_URL"),
+		ExportWebhookRetries:       exportWebhookRetries,
+		ExportSyslogNetwork:        os.Getenv("AUDIT_EXPORT_SYSLOG_NETWORK"),
+		ExportSyslogAddr:           os.Getenv("AUDIT_EXPORT_SYSLOG_ADDR"),
+		EnrichmentDumps:            enrichmentDumps,
+		EnrichmentURL:              os.Getenv("ENRICHMENT_URL"),
+	}
+}
+
+// envOr returns the first of the named environment variables that is set.
+func envOr(names ...string) string {
+	for _, name := range names {
+		if val := os.Getenv(name); val != "" {
+			return val
+		}
 	}
+	return ""
 }
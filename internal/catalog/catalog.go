@@ -0,0 +1,55 @@
+// Package catalog converts books to and from the interchange formats
+// libraries use for bulk catalog transfers: MARC21 (ISO 2709), MARCXML, CSV
+// and, for export, NDJSON.
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"open-library-explorer/internal/models"
+)
+
+const (
+	FormatMARC    = "marc"
+	FormatMARCXML = "marcxml"
+	FormatCSV     = "csv"
+)
+
+// Record is one book read from an import file. Err is set when the record
+// could not be read; the rest of the file is still processed.
+type Record struct {
+	Book   models.Book
+	Copies int // copies requested by the record itself, or -1 when it does not say
+	Err    error
+}
+
+// Parse reads every record of r in the given format.
+func Parse(format string, r io.Reader) ([]Record, error) {
+	switch format {
+	case FormatMARC:
+		return ParseMARC(r)
+	case FormatMARCXML:
+		return ParseMARCXML(r)
+	case FormatCSV:
+		return ParseCSV(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// FormatFromContentType maps a request Content-Type to a format, or returns ""
+// when it is not one of ours.
+func FormatFromContentType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "application/marc":
+		return FormatMARC
+	case "application/marcxml+xml", "application/xml", "text/xml":
+		return FormatMARCXML
+	case "text/csv":
+		return FormatCSV
+	}
+	return ""
+}
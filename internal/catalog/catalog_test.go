@@ -0,0 +1,216 @@
+package catalog_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"open-library-explorer/internal/catalog"
+	"open-library-explorer/internal/models"
+)
+
+// marcRecord assembles an ISO 2709 record from tag/body pairs, where a body
+// is the raw field content without the field terminator.
+func marcRecord(fields ...[2]string) string {
+	var directory, data strings.Builder
+	for _, f := range fields {
+		body := f[1] + "\x1e"
+		fmt.Fprintf(&directory, "%s%04d%05d", f[0], len(body), data.Len())
+		data.WriteString(body)
+	}
+	directory.WriteString("\x1e")
+	base := 24 + directory.Len()
+	length := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam a22%05d   4500", length, base)
+	return leader + directory.String() + data.String() + "\x1d"
+}
+
+func TestParseMARC(t *testing.T) {
+	input := marcRecord(
+		[2]string{"001", "ocm123"},
+		[2]string{"008", "970101s1997    nyu           000 1 eng d"},
+		[2]string{"020", "  \x1fa9780140449136 (pbk.)"},
+		[2]string{"100", "1 \x1faDostoyevsky, Fyodor,"},
+		[2]string{"245", "10\x1faCrime and punishment /\x1fcFyodor Dostoyevsky."},
+		[2]string{"250", "  \x1faRev. ed."},
+		[2]string{"260", "  \x1faNew York :\x1fbPenguin,\x1fc2003."},
+		[2]string{"300", "  \x1faxxxviii, 656 p. ;\x1fc20 cm."},
+		[2]string{"490", "1 \x1faPenguin classics ;\x1fvv. 12"},
+		[2]string{"650", " 0\x1faMurder\x1fzRussia\x1fvFiction."},
+		[2]string{"650", " 0\x1faPsychological fiction."},
+		[2]string{"700", "1 \x1faMcDuff, David,\x1fetranslator."},
+		[2]string{"700", "1 \x1faFrank, Joseph,\x1f4aui"},
+	) + marcRecord(
+		[2]string{"008", "970101s1997    nyu           000 1 eng d"},
+		[2]string{"245", "10\x1faUntitled"},
+	) + "garbage\x1d"
+
+	records, err := catalog.ParseMARC(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	want := models.Book{
+		ISBN:  "9780140449136",
+		Title: "Crime and punishment",
+		Contributors: []models.Contributor{
+			{Name: "Dostoyevsky, Fyodor", Role: models.ContributorAuthor},
+			{Name: "McDuff, David", Role: models.ContributorTranslator},
+			{Name: "Frank, Joseph", Role: models.ContributorOther},
+		},
+		Publisher:     "Penguin",
+		Subjects:      []string{"Murder", "Psychological fiction"},
+		Language:      "eng",
+		Edition:       "Rev. ed",
+		Series:        "Penguin classics",
+		SeriesNumber:  12,
+		PublishedYear: 2003,
+		PageCount:     656,
+	}
+	if records[0].Err != nil || !reflect.DeepEqual(records[0].Book, want) {
+		t.Errorf("unexpected first record %+v (%v)", records[0].Book, records[0].Err)
+	}
+	if records[1].Book.PublishedYear != 1997 {
+		t.Errorf("expected the 008 date as fallback, got %d", records[1].Book.PublishedYear)
+	}
+	if records[2].Err == nil {
+		t.Error("expected a malformed record to carry an error")
+	}
+}
+
+func TestParseMARCXML(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a2200000 a 4500</leader>
+    <controlfield tag="001">ocm1</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">0679720200</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Camus, Albert.</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="4"><subfield code="a">The stranger :</subfield><subfield code="b">a novel /</subfield></datafield>
+    <datafield tag="264" ind1=" " ind2="1"><subfield code="b">Vintage,</subfield><subfield code="c">[1989]</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="245" ind1="0" ind2="0"><subfield code="a">No ISBN</subfield></datafield>
+  </record>
+</collection>`
+
+	records, err := catalog.ParseMARCXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	book := records[0].Book
+	if book.ISBN != "0679720200" || book.Title != "The stranger : a novel" || len(book.Authors()) != 1 || book.Authors()[0] != "Camus, Albert" ||
+		book.Publisher != "Vintage" || book.PublishedYear != 1989 {
+		t.Errorf("unexpected record %+v", book)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	// Author is the column name of files from before contributors
+	input := "ISBN,Title,Author,Published_Year,Copies,Shelf\n" +
+		"9780140449136,Crime and Punishment,Fyodor Dostoyevsky,2003,2,A1\n" +
+		"9780679720201,The Stranger,Albert Camus,,,\n" +
+		"9780000000000,Bad Year,Someone,soon,,\n"
+
+	records, err := catalog.ParseCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if r := records[0]; r.Book.Title != "Crime and Punishment" || r.Book.PublishedYear != 2003 || r.Copies != 2 ||
+		len(r.Book.Contributors) != 1 || r.Book.Contributors[0] != (models.Contributor{Name: "Fyodor Dostoyevsky", Role: models.ContributorAuthor}) {
+		t.Errorf("unexpected first record %+v", r)
+	}
+	if r := records[1]; r.Err != nil || r.Copies != -1 || r.Book.PublishedYear != 0 {
+		t.Errorf("unexpected second record %+v", r)
+	}
+	if records[2].Err == nil {
+		t.Error("expected an invalid year to be reported")
+	}
+
+	if _, err := catalog.ParseCSV(strings.NewReader("title,author\nx,y\n")); err == nil {
+		t.Error("expected a header without isbn to be refused")
+	}
+}
+
+func TestWriters_RoundTrip(t *testing.T) {
+	book := catalog.ExportBook{
+		Book: models.Book{
+			ISBN:  "9780140449136",
+			Title: "Crime & Punishment",
+			Contributors: []models.Contributor{
+				{Name: "Fyodor Dostoyevsky", Role: models.ContributorAuthor},
+				{Name: "David McDuff", Role: models.ContributorTranslator},
+			},
+			Publisher:     "Penguin",
+			Subjects:      []string{"Murder", "Psychological fiction"},
+			Language:      "eng",
+			Edition:       "3rd edition",
+			Series:        "Penguin Classics",
+			SeriesNumber:  1.5,
+			PublishedYear: 2003,
+			PageCount:     656,
+			Description:   "A student kills a pawnbroker",
+		},
+		Copies: []models.Copy{
+			{Barcode: "C-1", Status: models.StatusAvailable},
+			{Barcode: "C-2", Status: models.StatusOnLoan},
+		},
+	}
+
+	var xmlOut strings.Builder
+	w, err := catalog.NewWriter(catalog.FormatMARCXML, &xmlOut, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(book)
+	w.Write(catalog.ExportBook{Book: models.Book{ISBN: "1", Title: "Bare"}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(xmlOut.String(), `<subfield code="p">C-2</subfield>`) {
+		t.Errorf("expected copies as 876 fields:\n%s", xmlOut.String())
+	}
+	records, err := catalog.ParseMARCXML(strings.NewReader(xmlOut.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !sameBook(records[0].Book, book.Book) {
+		t.Errorf("MARCXML did not round trip: %+v", records)
+	}
+
+	var csvOut strings.Builder
+	w, _ = catalog.NewWriter(catalog.FormatCSV, &csvOut, true)
+	w.Write(book)
+	w.Close()
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 3 || lines[0] != "isbn,title,contributors,publisher,subjects,language,edition,series,series_number,published_year,page_count,description,barcode,status" ||
+		!strings.HasSuffix(lines[2], ",C-2,ON_LOAN") {
+		t.Errorf("unexpected CSV:\n%s", csvOut.String())
+	}
+	records, _ = catalog.ParseCSV(strings.NewReader(csvOut.String()))
+	if len(records) != 2 || !sameBook(records[0].Book, book.Book) {
+		t.Errorf("CSV did not round trip: %+v", records)
+	}
+
+	var ndjsonOut strings.Builder
+	w, _ = catalog.NewWriter(catalog.FormatNDJSON, &ndjsonOut, true)
+	w.Write(book)
+	w.Close()
+	if !strings.Contains(ndjsonOut.String(), `"copies":[{`) || strings.Count(ndjsonOut.String(), "\n") != 1 {
+		t.Errorf("unexpected NDJSON: %s", ndjsonOut.String())
+	}
+}
+
+func sameBook(a, b models.Book) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,171 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"open-library-explorer/internal/models"
+)
+
+// CSVColumns are the columns CSV imports understand and exports write, in
+// export order. Headers are matched case-insensitively and unknown columns
+// are ignored; "copies" is only read on import, as are the "author" and
+// "subject" columns of older files.
+//
+// Contributors and subjects hold several values separated by "; ".
+// Contributors are written as "Name (role)", where a bare name is an author.
+var CSVColumns = []string{
+	"isbn", "title", "contributors", "publisher", "subjects", "language", "edition",
+	"series", "series_number", "published_year", "page_count", "description",
+}
+
+// csvAliases are the columns of the single-author model and their successors.
+var csvAliases = map[string]string{
+	"author":  "contributors",
+	"authors": "contributors",
+	"subject": "subjects",
+}
+
+// ParseCSV reads books from CSV with a header row.
+func ParseCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := csvAliases[name]; ok {
+			name = alias
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["isbn"]; !ok {
+		return nil, errors.New("CSV header has no isbn column")
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				records = append(records, Record{Copies: -1, Err: err})
+				continue
+			}
+			return records, err
+		}
+		records = append(records, csvRecord(columns, row))
+	}
+}
+
+func csvRecord(columns map[string]int, row []string) Record {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	integer := func(name string, dst *int) error {
+		v := get(name)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", name, v)
+		}
+		*dst = n
+		return nil
+	}
+
+	rec := Record{Copies: -1}
+	rec.Book.ISBN = get("isbn")
+	rec.Book.Title = get("title")
+	rec.Book.Contributors = parseContributors(get("contributors"))
+	rec.Book.Publisher = get("publisher")
+	rec.Book.Subjects = splitList(get("subjects"))
+	rec.Book.Language = strings.ToLower(get("language"))
+	rec.Book.Edition = get("edition")
+	rec.Book.Series = get("series")
+	rec.Book.Description = get("description")
+
+	if v := get("series_number"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			rec.Err = fmt.Errorf("invalid series_number %q", v)
+			return rec
+		}
+		rec.Book.SeriesNumber = n
+	}
+	if err := integer("published_year", &rec.Book.PublishedYear); err != nil {
+		rec.Err = err
+		return rec
+	}
+	if err := integer("page_count", &rec.Book.PageCount); err != nil {
+		rec.Err = err
+		return rec
+	}
+	if v := get("copies"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			rec.Err = fmt.Errorf("invalid copies %q", v)
+			return rec
+		}
+		rec.Copies = n
+	}
+	return rec
+}
+
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseContributors reads "Name (role); Name" lists. A missing or unknown
+// role makes the contributor an author.
+func parseContributors(s string) []models.Contributor {
+	var contributors []models.Contributor
+	for _, v := range splitList(s) {
+		c := models.Contributor{Name: v, Role: models.ContributorAuthor}
+		if open := strings.LastIndex(v, " ("); open > 0 && strings.HasSuffix(v, ")") {
+			if role := strings.ToLower(v[open+2 : len(v)-1]); models.IsValidContributorRole(role) {
+				c = models.Contributor{Name: strings.TrimSpace(v[:open]), Role: models.ContributorRole(role)}
+			}
+		}
+		contributors = append(contributors, c)
+	}
+	return contributors
+}
+
+// formatContributors is the inverse of parseContributors.
+func formatContributors(contributors []models.Contributor) string {
+	parts := make([]string, 0, len(contributors))
+	for _, c := range contributors {
+		if c.Role == models.ContributorAuthor || c.Role == "" {
+			parts = append(parts, c.Name)
+		} else {
+			parts = append(parts, c.Name+" ("+string(c.Role)+")")
+		}
+	}
+	return strings.Join(parts, "; ")
+}
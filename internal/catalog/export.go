@@ -0,0 +1,223 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"open-library-explorer/internal/models"
+)
+
+const FormatNDJSON = "ndjson"
+
+const marcXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+// ExportBook is a book together with its copies, which are only filled in
+// when an export asks for them.
+type ExportBook struct {
+	models.Book
+	Copies []models.Copy `json:"copies,omitempty"`
+}
+
+// Writer streams books out in one format. Close finishes the document; it
+// does not close the underlying io.Writer.
+type Writer interface {
+	Write(book ExportBook) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format. withCopies adds copy columns to CSV,
+// which has a fixed header; the other formats include whatever copies a book
+// carries.
+func NewWriter(format string, w io.Writer, withCopies bool) (Writer, error) {
+	switch format {
+	case FormatMARCXML:
+		return newMARCXMLWriter(w)
+	case FormatCSV:
+		return newCSVWriter(w, withCopies)
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// ContentType is the media type of an export in format, or "" when format
+// cannot be exported.
+func ContentType(format string) string {
+	switch format {
+	case FormatMARCXML:
+		return "application/marcxml+xml"
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return ""
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(book ExportBook) error {
+	return n.enc.Encode(book)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// csvWriter writes one row per book, or with copies one row per copy, with
+// the book columns repeated and empty copy columns for books without any.
+type csvWriter struct {
+	w          *csv.Writer
+	withCopies bool
+}
+
+func newCSVWriter(w io.Writer, withCopies bool) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), withCopies: withCopies}
+	header := append([]string{}, CSVColumns...)
+	if withCopies {
+		header = append(header, "barcode", "status")
+	}
+	return cw, cw.w.Write(header)
+}
+
+func (c *csvWriter) Write(book ExportBook) error {
+	row := []string{
+		book.ISBN, book.Title, formatContributors(book.Contributors), book.Publisher,
+		strings.Join(book.Subjects, "; "), book.Language, book.Edition, book.Series,
+		formatNumber(book.SeriesNumber), formatNumber(float64(book.PublishedYear)),
+		formatNumber(float64(book.PageCount)), book.Description,
+	}
+
+	if !c.withCopies {
+		return c.w.Write(row)
+	}
+	if len(book.Copies) == 0 {
+		return c.w.Write(append(row, "", ""))
+	}
+	for _, copyObj := range book.Copies {
+		if err := c.w.Write(append(row, copyObj.Barcode, string(copyObj.Status))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type marcXMLWriter struct {
+	enc *xml.Encoder
+}
+
+func newMARCXMLWriter(w io.Writer) (*marcXMLWriter, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	start := xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: marcXMLNamespace}},
+	}
+	return &marcXMLWriter{enc: enc}, enc.EncodeToken(start)
+}
+
+func (m *marcXMLWriter) Write(book ExportBook) error {
+	return m.enc.EncodeElement(toXMLRecord(marcFromBook(book)), xml.StartElement{Name: xml.Name{Local: "record"}})
+}
+
+func (m *marcXMLWriter) Close() error {
+	if err := m.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}}); err != nil {
+		return err
+	}
+	return m.enc.Flush()
+}
+
+// marcFromBook is the inverse of marcRecord.record, with copies as 876 item
+// fields ($p barcode, $j status).
+func marcFromBook(book ExportBook) marcRecord {
+	m := marcRecord{Leader: "00000nam a2200000   4500"}
+	data := func(tag, ind1, ind2 string, subfields ...subfield) {
+		var kept []subfield
+		for _, sf := range subfields {
+			if sf.Value != "" {
+				kept = append(kept, sf)
+			}
+		}
+		if len(kept) > 0 {
+			m.Fields = append(m.Fields, marcField{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: kept})
+		}
+	}
+
+	m.Fields = append(m.Fields, marcField{Tag: "001", Value: book.ISBN})
+	data("020", " ", " ", subfield{"a", book.ISBN})
+	data("041", " ", " ", subfield{"a", book.Language})
+
+	// The first author is the main entry, everyone else an added entry
+	var added []models.Contributor
+	mainEntry := false
+	for _, c := range book.Contributors {
+		if c.Role == models.ContributorAuthor && !mainEntry {
+			data("100", "1", " ", subfield{"a", c.Name})
+			mainEntry = true
+			continue
+		}
+		added = append(added, c)
+	}
+
+	data("245", "1", "0", subfield{"a", book.Title})
+	data("250", " ", " ", subfield{"a", book.Edition})
+	data("264", " ", "1", subfield{"b", book.Publisher}, subfield{"c", formatNumber(float64(book.PublishedYear))})
+	if book.PageCount > 0 {
+		data("300", " ", " ", subfield{"a", strconv.Itoa(book.PageCount) + " p."})
+	}
+	data("490", "0", " ", subfield{"a", book.Series}, subfield{"v", formatNumber(book.SeriesNumber)})
+	data("520", " ", " ", subfield{"a", book.Description})
+	for _, subject := range book.Subjects {
+		data("650", " ", "0", subfield{"a", subject})
+	}
+	for _, c := range added {
+		data("700", "1", " ", subfield{"a", c.Name}, subfield{"e", string(c.Role)})
+	}
+	for _, tag := range book.Tags {
+		data("653", " ", " ", subfield{"a", tag})
+	}
+	for _, copyObj := range book.Copies {
+		data("876", " ", " ", subfield{"p", copyObj.Barcode}, subfield{"j", string(copyObj.Status)})
+	}
+	return m
+}
+
+// formatNumber writes n without a fraction when it has none, and zero as "".
+func formatNumber(n float64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func toXMLRecord(m marcRecord) xmlRecord {
+	xr := xmlRecord{Leader: m.Leader}
+	for _, f := range m.Fields {
+		if f.Subfields == nil {
+			xr.ControlFields = append(xr.ControlFields, xmlControlField{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+		df := xmlDataField{Tag: f.Tag, Ind1: f.Ind1, Ind2: f.Ind2}
+		for _, sf := range f.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: sf.Code, Value: sf.Value})
+		}
+		xr.DataFields = append(xr.DataFields, df)
+	}
+	return xr
+}
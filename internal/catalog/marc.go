@@ -0,0 +1,253 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"open-library-explorer/internal/models"
+)
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+type subfield struct {
+	Code  string
+	Value string
+}
+
+// marcField is a control field (tag below 010, Value set) or a data field
+// (indicators and subfields set).
+type marcField struct {
+	Tag       string
+	Value     string
+	Ind1      string
+	Ind2      string
+	Subfields []subfield
+}
+
+type marcRecord struct {
+	Leader string
+	Fields []marcField
+}
+
+func (f marcField) subfield(code string) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+func (m marcRecord) fields(tag string) []marcField {
+	var found []marcField
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// first returns subfield code of the first of tags that has it.
+func (m marcRecord) first(code string, tags ...string) string {
+	for _, tag := range tags {
+		for _, f := range m.fields(tag) {
+			if v := f.subfield(code); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// ParseMARC reads binary MARC21 records.
+func ParseMARC(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, raw := range bytes.Split(data, []byte{recordTerminator}) {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		m, err := decodeMARC(raw)
+		if err != nil {
+			records = append(records, Record{Copies: -1, Err: err})
+			continue
+		}
+		records = append(records, m.record())
+	}
+	return records, nil
+}
+
+func decodeMARC(raw []byte) (marcRecord, error) {
+	if len(raw) < 24 {
+		return marcRecord{}, errors.New("record shorter than its leader")
+	}
+	m := marcRecord{Leader: string(raw[:24])}
+	base, err := strconv.Atoi(string(raw[12:17]))
+	if err != nil || base <= 24 || base > len(raw) {
+		return m, errors.New("invalid base address in leader")
+	}
+
+	directory := raw[24 : base-1]
+	if len(directory)%12 != 0 {
+		return m, errors.New("malformed directory")
+	}
+	for i := 0; i < len(directory); i += 12 {
+		entry := directory[i : i+12]
+		tag := string(entry[:3])
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || base+start+length > len(raw) {
+			return m, fmt.Errorf("malformed directory entry for tag %s", tag)
+		}
+		body := bytes.TrimSuffix(raw[base+start:base+start+length], []byte{fieldTerminator})
+
+		if tag < "010" {
+			m.Fields = append(m.Fields, marcField{Tag: tag, Value: string(body)})
+			continue
+		}
+		if len(body) < 2 {
+			return m, fmt.Errorf("field %s has no indicators", tag)
+		}
+		f := marcField{Tag: tag, Ind1: string(body[0]), Ind2: string(body[1])}
+		for _, part := range bytes.Split(body[2:], []byte{subfieldDelimiter}) {
+			if len(part) == 0 {
+				continue
+			}
+			f.Subfields = append(f.Subfields, subfield{Code: string(part[0]), Value: string(part[1:])})
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	return m, nil
+}
+
+// record maps the fields a catalog import cares about onto a book:
+// 020 ISBN, 245 title, 100/110/111 author, 700/710 further contributors with
+// their relator ($e term or $4 code), 250 edition, 260/264 publisher and
+// date, 300 pages, 490 series, 520 description, 650 subjects and 041 or 008
+// language, with the 008 date as a fallback for the year.
+func (m marcRecord) record() Record {
+	book := models.Book{
+		ISBN:        firstWord(m.first("a", "020")),
+		Title:       trimPunctuation(strings.TrimSpace(m.first("a", "245") + " " + m.first("b", "245"))),
+		Publisher:   trimPunctuation(m.first("b", "264", "260")),
+		Edition:     trimPunctuation(m.first("a", "250")),
+		Series:      trimPunctuation(m.first("a", "490", "830")),
+		Description: strings.TrimSpace(m.first("a", "520")),
+		Language:    strings.ToLower(strings.TrimSpace(m.first("a", "041"))),
+		PageCount:   pages(m.first("a", "300")),
+	}
+
+	if name := trimPunctuation(m.first("a", "100", "110", "111")); name != "" {
+		book.Contributors = append(book.Contributors, models.Contributor{Name: name, Role: models.ContributorAuthor})
+	}
+	for _, tag := range []string{"700", "710"} {
+		for _, f := range m.fields(tag) {
+			if name := trimPunctuation(f.subfield("a")); name != "" {
+				book.Contributors = append(book.Contributors, models.Contributor{Name: name, Role: relatorRole(f)})
+			}
+		}
+	}
+
+	for _, f := range m.fields("650") {
+		if v := trimPunctuation(f.subfield("a")); v != "" {
+			book.Subjects = append(book.Subjects, v)
+		}
+	}
+
+	if book.Series != "" {
+		book.SeriesNumber = number(m.first("v", "490", "830"))
+	}
+
+	book.PublishedYear = year(m.first("c", "264", "260"))
+	for _, f := range m.fields("008") {
+		if book.PublishedYear == 0 && len(f.Value) >= 11 {
+			book.PublishedYear = year(f.Value[7:11])
+		}
+		if book.Language == "" && len(f.Value) >= 38 {
+			if lang := strings.TrimSpace(f.Value[35:38]); lang != "" && lang != "|||" {
+				book.Language = lang
+			}
+		}
+	}
+	return Record{Book: book, Copies: -1}
+}
+
+// relatorCodes maps MARC relator codes ($4) to contributor roles.
+var relatorCodes = map[string]models.ContributorRole{
+	"aut": models.ContributorAuthor,
+	"edt": models.ContributorEditor,
+	"trl": models.ContributorTranslator,
+	"ill": models.ContributorIllustrator,
+}
+
+// relatorRole reads the role of an added entry from its relator term or code,
+// falling back to a generic contributor.
+func relatorRole(f marcField) models.ContributorRole {
+	if role := models.ContributorRole(strings.ToLower(trimPunctuation(f.subfield("e")))); models.IsValidContributorRole(string(role)) {
+		return role
+	}
+	if role, ok := relatorCodes[strings.TrimSpace(f.subfield("4"))]; ok {
+		return role
+	}
+	return models.ContributorOther
+}
+
+// pages reads the page count from a physical description such as
+// "xii, 320 p. ;", taking the number right before the "p".
+func pages(extent string) int {
+	fields := strings.Fields(extent)
+	for i := 1; i < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "p") {
+			if n, err := strconv.Atoi(strings.Trim(fields[i-1], "[],")); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// number returns the first number in s, e.g. 2.5 in "v. 2.5".
+func number(s string) float64 {
+	for _, word := range strings.Fields(s) {
+		if n, err := strconv.ParseFloat(strings.Trim(word, "[],;"), 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func firstWord(s string) string {
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// trimPunctuation drops the ISBD punctuation MARC puts between subfields.
+func trimPunctuation(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,."))
+}
+
+// year returns the first run of four digits in s, e.g. 1997 in "c1997.".
+func year(s string) int {
+	for i := 0; i+4 <= len(s); i++ {
+		if y, err := strconv.Atoi(s[i : i+4]); err == nil && y > 0 {
+			return y
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlRecord struct {
+	Leader        string            `xml:"leader,omitempty"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+// ParseMARCXML reads the record elements of a MARCXML document, whether it
+// is a collection or a single record.
+func ParseMARCXML(r io.Reader) ([]Record, error) {
+	dec := xml.NewDecoder(r)
+
+	var records []Record
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var xr xmlRecord
+		if err := dec.DecodeElement(&xr, &start); err != nil {
+			return records, err
+		}
+		records = append(records, xr.marc().record())
+	}
+}
+
+func (xr xmlRecord) marc() marcRecord {
+	m := marcRecord{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		m.Fields = append(m.Fields, marcField{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range xr.DataFields {
+		f := marcField{Tag: df.Tag, Ind1: df.Ind1, Ind2: df.Ind2}
+		for _, sf := range df.Subfields {
+			f.Subfields = append(f.Subfields, subfield{Code: sf.Code, Value: sf.Value})
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	return m
+}
@@ -8,4 +8,23 @@ const (
 	CheckOut   = "checkout"
 	Deactivate = "deactivate"
 	RenewLoan  = "renewal"
+	Cancel     = "cancel"
+	Expire     = "expire"
+	Fulfil     = "fulfil"
+	Pay        = "pay"
+	Waive      = "waive"
+	Block      = "block"
+	Unblock    = "unblock"
+	Import     = "import"
+	Enrich     = "enrich"
+	Withdraw   = "withdraw"
+	Lost       = "lost"
+	Found      = "found"
+	Damaged    = "damaged"
+	Claim      = "claims_returned"
+	Refund     = "refund"
+	Suspend    = "suspend"
+	Reactivate = "reactivate"
+	Renew      = "renew_membership"
+	Notify     = "notify"
 )
@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"open-library-explorer/internal/services"
+	"time"
+)
+
+type HoldExpirer struct {
+	Queue *services.HoldQueue
+}
+
+// Run expires holds whose pickup deadline passed and offers their copies to
+// the next member in the queue.
+func (e *HoldExpirer) Run(ctx context.Context) error {
+	expired, err := e.Queue.ExpireStale(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		log.Println("Expired unclaimed holds:", expired)
+	}
+	return nil
+}
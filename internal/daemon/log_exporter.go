@@ -2,37 +2,77 @@ package daemon
 
 import (
 	"context"
-	"go.mongodb.org/mongo-driver/bson"
+	"log"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"open-library-explorer/internal/models"
-	"open-library-explorer/internal/utils"
-	"time"
+
+	"open-library-explorer/internal/exporter"
+	"open-library-explorer/internal/store"
+)
+
+const (
+	DefaultExportBatchSize   = 100
+	DefaultExportMaxAttempts = 5
 )
 
 type LogExporter struct {
-	Coll *mongo.Collection
+	Store       store.AuditStore
+	Exporter    exporter.Exporter
+	BatchSize   int
+	MaxAttempts int // failed exports before an entry is dead-lettered
 }
 
-func (l *LogExporter) InitLogExporter() {
-	go func() {
-		for {
-			res, _ := l.Coll.Find(context.Background(), bson.M{"exported": false})
+// Run exports everything pending; it is the supervisor job entry point.
+func (l *LogExporter) Run(ctx context.Context) error {
+	exported, err := l.ExportPending(ctx)
+	if exported > 0 {
+		log.Println("Exported audit log entries:", exported)
+	}
+	return err
+}
 
-			var logs []models.AuditLog
-			_ = res.All(context.Background(), &logs)
+// ExportPending sends unexported entries to the sink batch by batch until none
+// are left or a batch fails. Entries are only marked exported once the sink
+// has acknowledged them, so a crash in between means they are sent again.
+// It returns how many entries were exported.
+func (l *LogExporter) ExportPending(ctx context.Context) (int, error) {
+	batchSize := l.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultExportBatchSize
+	}
+	maxAttempts := l.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultExportMaxAttempts
+	}
 
-			if len(logs) > 0 {
-				_ = utils.ExportData(logs)
-				updateIds := []primitive.ObjectID{}
+	exported := 0
+	for {
+		logs, err := l.Store.FindUnexported(ctx, batchSize)
+		if err != nil {
+			return exported, err
+		}
+		if len(logs) == 0 {
+			return exported, nil
+		}
 
-				for i := 0; i < len(logs); i++ {
-					updateIds = append(updateIds, logs[i].ID)
-				}
+		ids := make([]primitive.ObjectID, len(logs))
+		for i := range logs {
+			ids[i] = logs[i].ID
+		}
 
-				l.Coll.UpdateMany(context.Background(), bson.M{"_id": bson.M{"$in": updateIds}}, bson.M{"$set": bson.M{"exported": true}})
+		if exportErr := l.Exporter.Export(ctx, logs); exportErr != nil {
+			if err := l.Store.RecordFailure(ctx, ids, exportErr.Error(), maxAttempts); err != nil {
+				return exported, err
 			}
-			time.Sleep(30 * time.Second)
+			return exported, exportErr
+		}
+		if err := l.Store.MarkExported(ctx, ids); err != nil {
+			return exported, err
+		}
+		exported += len(logs)
+
+		if len(logs) < batchSize {
+			return exported, nil
 		}
-	}()
+	}
 }
@@ -0,0 +1,64 @@
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"open-library-explorer/internal/daemon"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+type fakeSink struct {
+	fail    bool
+	batches [][]models.AuditLog
+}
+
+func (s *fakeSink) Export(_ context.Context, logs []models.AuditLog) error {
+	if s.fail {
+		return errors.New("sink down")
+	}
+	s.batches = append(s.batches, logs)
+	return nil
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func TestLogExporter_ExportPending(t *testing.T) {
+	ctx := context.Background()
+	audit := store.NewMemoryAuditStore()
+	for i := 0; i < 5; i++ {
+		audit.Insert(ctx, &models.AuditLog{Entity: "book", Action: "create"})
+	}
+
+	sink := &fakeSink{fail: true}
+	exp := &daemon.LogExporter{Store: audit, Exporter: sink, BatchSize: 2, MaxAttempts: 2}
+
+	// a failing sink leaves the entries pending
+	if _, err := exp.ExportPending(ctx); err == nil {
+		t.Fatal("expected the sink error")
+	}
+	if pending, _ := audit.FindUnexported(ctx, 0); len(pending) != 5 {
+		t.Fatalf("expected 5 pending entries, got %d", len(pending))
+	}
+
+	// the second failure dead-letters the first batch
+	exp.ExportPending(ctx)
+	dead, _ := audit.FindDeadLetters(ctx)
+	if len(dead) != 2 || dead[0].Attempts != 2 || dead[0].LastError != "sink down" {
+		t.Fatalf("expected 2 dead-lettered entries, got %+v", dead)
+	}
+
+	sink.fail = false
+	exported, err := exp.ExportPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 3 || len(sink.batches) != 2 || len(sink.batches[0]) != 2 {
+		t.Errorf("expected 3 entries in batches of 2, got %d in %d batches", exported, len(sink.batches))
+	}
+	if pending, _ := audit.FindUnexported(ctx, 0); len(pending) != 0 {
+		t.Errorf("expected nothing pending, got %d", len(pending))
+	}
+}
@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"open-library-explorer/internal/services"
+	"time"
+)
+
+type MembershipExpirer struct {
+	Membership *services.Membership
+}
+
+// Run marks members whose membership ran out as expired and reminds those
+// whose membership is about to.
+func (e *MembershipExpirer) Run(ctx context.Context) error {
+	now := time.Now()
+	expired, err := e.Membership.ExpireDue(ctx, now)
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		log.Println("Expired memberships:", expired)
+	}
+	notified, err := e.Membership.NotifyExpiring(ctx, now)
+	if err != nil {
+		return err
+	}
+	if notified > 0 {
+		log.Println("Reminded members of expiring memberships:", notified)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+)
+
+// Job is a named unit of background work that the Supervisor runs every
+// Interval until it is shut down.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// JobStatus reports what a job has been doing.
+type JobStatus struct {
+	Name      string    `json:"name"`
+	Running   bool      `json:"running"` // a run is in progress right now
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	Restarts  int       `json:"restarts"` // runs that panicked
+	LastRun   time.Time `json:"last_run,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Supervisor runs jobs in their own goroutines. A job that panics is restarted
+// after a backoff that doubles from MinBackoff up to MaxBackoff and resets
+// once a run completes. Shutdown stops scheduling new runs and waits for the
+// ones in progress.
+type Supervisor struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu     sync.Mutex
+	jobs   []Job
+	status map[string]*JobStatus
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		status:     map[string]*JobStatus{},
+	}
+}
+
+// Add registers job. Jobs must be added before Start.
+func (s *Supervisor) Add(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+	s.status[job.Name] = &JobStatus{Name: job.Name}
+}
+
+// Start launches every registered job. Cancelling ctx has the same effect as
+// Shutdown without waiting.
+func (s *Supervisor) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.cancel = cancel
+	jobs := append([]Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Supervisor) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	backoff := s.MinBackoff
+	for {
+		wait := job.Interval
+		if panicked := s.runOnce(ctx, job); panicked {
+			wait = backoff
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+		} else {
+			backoff = s.MinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *Supervisor) runOnce(ctx context.Context, job Job) (panicked bool) {
+	s.update(job.Name, func(st *JobStatus) { st.Running = true })
+
+	var err error
+	defer func() {
+		if p := recover(); p != nil {
+			panicked = true
+			err = fmt.Errorf("panic: %v", p)
+		}
+		if err != nil {
+			log.Printf("Daemon %s failed: %v", job.Name, err)
+		}
+		s.update(job.Name, func(st *JobStatus) {
+			st.Running = false
+			st.Runs++
+			st.LastRun = time.Now()
+			st.LastError = ""
+			if err != nil {
+				st.Failures++
+				st.LastError = err.Error()
+			}
+			if panicked {
+				st.Restarts++
+			}
+		})
+	}()
+
+	err = job.Run(ctx)
+	return false
+}
+
+func (s *Supervisor) update(name string, fn func(*JobStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.status[name])
+}
+
+// Status returns a snapshot of every job's status in registration order.
+func (s *Supervisor) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, *s.status[job.Name])
+	}
+	return statuses
+}
+
+// Shutdown cancels the jobs and waits for running ones to return, or for ctx
+// to expire.
+func (s *Supervisor) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,92 @@
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"open-library-explorer/internal/daemon"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisor_RestartsPanickingJob(t *testing.T) {
+	var calls int32
+	s := daemon.NewSupervisor()
+	s.MinBackoff = time.Millisecond
+	s.MaxBackoff = 4 * time.Millisecond
+	s.Add(daemon.Job{
+		Name:     "flaky",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) <= 2 {
+				panic("boom")
+			}
+			return nil
+		},
+	})
+	s.Add(daemon.Job{
+		Name:     "failing",
+		Interval: time.Hour,
+		Run:      func(ctx context.Context) error { return errors.New("store down") },
+	})
+	s.Start(context.Background())
+
+	waitFor(t, func() bool { return s.Status()[0].Runs == 3 })
+
+	status := s.Status()
+	if status[0].Restarts != 2 || status[0].Failures != 2 || status[0].LastError != "" {
+		t.Errorf("unexpected flaky status: %+v", status[0])
+	}
+	if status[1].Name != "failing" || status[1].LastError != "store down" || status[1].Restarts != 0 {
+		t.Errorf("unexpected failing status: %+v", status[1])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisor_ShutdownWaitsForRunningJob(t *testing.T) {
+	started := make(chan struct{})
+	var finished int32
+	s := daemon.NewSupervisor()
+	s.Add(daemon.Job{
+		Name:     "slow",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+			return nil
+		},
+	})
+	s.Start(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("expected Shutdown to wait for the running job")
+	}
+	if s.Status()[0].Running {
+		t.Error("expected the job to be reported as stopped")
+	}
+}
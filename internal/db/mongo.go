@@ -26,3 +26,7 @@ func Connect(uri string) {
 func GetCollection(dbName, collection string) *mongo.Collection {
 	return MongoClient.Database(dbName).Collection(collection)
 }
+
+func GetDatabase(dbName string) *mongo.Database {
+	return MongoClient.Database(dbName)
+}
@@ -0,0 +1,182 @@
+package enrichment
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"open-library-explorer/internal/models"
+)
+
+// DumpProvider answers lookups from Open Library bulk dumps held in memory.
+// Load accepts both the official tab separated dump lines (type, key,
+// revision, last_modified, JSON) and plain JSON lines.
+//
+// Dumps are large, so Load only keeps what is needed: editions pass the keep
+// filter, works only when a loaded edition refers to them and authors only
+// when a loaded edition or work does. Load the editions dump first, then
+// works, then authors.
+type DumpProvider struct {
+	mu       sync.RWMutex
+	editions map[string]olEdition // by ISBN-13
+	works    map[string]olWork
+	authors  map[string]string // key to name
+	wanted   map[string]bool   // work and author keys referenced so far
+}
+
+func NewDumpProvider() *DumpProvider {
+	return &DumpProvider{
+		editions: map[string]olEdition{},
+		works:    map[string]olWork{},
+		authors:  map[string]string{},
+		wanted:   map[string]bool{},
+	}
+}
+
+// Load reads one dump. keep, when not nil, selects the ISBN-13s whose
+// editions are kept. It returns the number of records kept; lines that are
+// not valid records are skipped.
+func (p *DumpProvider) Load(r io.Reader, keep func(isbn string) bool) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	kept := 0
+	for scanner.Scan() {
+		line := dumpRecord(scanner.Bytes())
+		var probe dumpProbe
+		if json.Unmarshal(line, &probe) != nil {
+			continue
+		}
+
+		switch {
+		case probe.isEdition():
+			if p.loadEdition(line, keep) {
+				kept++
+			}
+		case probe.isWork():
+			if p.wanted[probe.Key] || keep == nil {
+				var work olWork
+				if json.Unmarshal(line, &work) == nil {
+					p.works[work.Key] = work
+					for _, a := range work.Authors {
+						p.wanted[a.Author.Key] = true
+					}
+					kept++
+				}
+			}
+		case probe.isAuthor():
+			if p.wanted[probe.Key] || keep == nil {
+				var author olAuthor
+				if json.Unmarshal(line, &author) == nil {
+					p.authors[author.Key] = author.Name
+					kept++
+				}
+			}
+		}
+	}
+	return kept, scanner.Err()
+}
+
+// dumpRecord returns the JSON of a dump line, dropping the type, key,
+// revision and date columns of the official dumps.
+func dumpRecord(line []byte) []byte {
+	if i := bytes.LastIndexByte(line, '\t'); i >= 0 {
+		return line[i+1:]
+	}
+	return line
+}
+
+// dumpProbe is decoded first to tell the kind of a dump record.
+type dumpProbe struct {
+	Key  string `json:"key"`
+	Type olRef  `json:"type"`
+}
+
+func (p dumpProbe) isEdition() bool {
+	return p.Type.Key == "/type/edition" || strings.HasPrefix(p.Key, "/books/")
+}
+
+func (p dumpProbe) isWork() bool {
+	return p.Type.Key == "/type/work" || strings.HasPrefix(p.Key, "/works/")
+}
+
+func (p dumpProbe) isAuthor() bool {
+	return p.Type.Key == "/type/author" || strings.HasPrefix(p.Key, "/authors/")
+}
+
+func (p *DumpProvider) loadEdition(line []byte, keep func(string) bool) bool {
+	var edition olEdition
+	if json.Unmarshal(line, &edition) != nil {
+		return false
+	}
+
+	loaded := false
+	for _, raw := range append(edition.ISBN13, edition.ISBN10...) {
+		isbn, err := models.NormalizeISBN(raw)
+		if err != nil || (keep != nil && !keep(isbn)) {
+			continue
+		}
+		p.editions[isbn] = edition
+		loaded = true
+	}
+	if loaded {
+		for _, w := range edition.Works {
+			p.wanted[w.Key] = true
+		}
+		for _, a := range edition.Authors {
+			p.wanted[a.Key] = true
+		}
+	}
+	return loaded
+}
+
+func (p *DumpProvider) Lookup(_ context.Context, isbn string) (Metadata, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	edition, ok := p.editions[isbn]
+	if !ok {
+		return Metadata{}, ErrNotFound
+	}
+	var work *olWork
+	if len(edition.Works) > 0 {
+		if w, ok := p.works[edition.Works[0].Key]; ok {
+			work = &w
+		}
+	}
+
+	var names []string
+	for _, key := range authorKeys(edition, work) {
+		if name := p.authors[key]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return metadata(edition, work, names), nil
+}
+
+// LoadDumpFiles loads each file in order into a new DumpProvider. See Load
+// for keep and for the order the files should come in.
+func LoadDumpFiles(paths []string, keep func(isbn string) bool) (*DumpProvider, error) {
+	p := NewDumpProvider()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.Load(f, keep)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,152 @@
+package enrichment
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"open-library-explorer/internal/models"
+)
+
+// dumpOffset is where a record starts in one of the dump files.
+type dumpOffset struct {
+	file   int
+	offset int64
+}
+
+// DumpIndex answers lookups from Open Library bulk dumps left on disk. It
+// holds only the offset of each record, keyed by ISBN-13 for editions and by
+// key for works and authors, and reads the records when they are looked up,
+// so any ISBN can be found without loading the dumps into memory.
+type DumpIndex struct {
+	paths    []string
+	editions map[string]dumpOffset
+	records  map[string]dumpOffset // works and authors
+}
+
+// IndexDumpFiles indexes the records of each dump file. The files are read
+// again on every lookup, so they must not change while the index is in use.
+func IndexDumpFiles(paths []string) (*DumpIndex, error) {
+	idx := &DumpIndex{
+		paths:    paths,
+		editions: map[string]dumpOffset{},
+		records:  map[string]dumpOffset{},
+	}
+	for n, path := range paths {
+		if err := idx.indexFile(n); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return idx, nil
+}
+
+func (idx *DumpIndex) indexFile(n int) error {
+	f, err := os.Open(idx.paths[n])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64*1024)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		at := dumpOffset{file: n, offset: offset}
+		offset += int64(len(line))
+		if len(line) > 0 {
+			idx.indexRecord(dumpRecord(line), at)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (idx *DumpIndex) indexRecord(line []byte, at dumpOffset) {
+	var probe struct {
+		dumpProbe
+		ISBN10 []string `json:"isbn_10"`
+		ISBN13 []string `json:"isbn_13"`
+	}
+	if json.Unmarshal(line, &probe) != nil {
+		return
+	}
+	switch {
+	case probe.isEdition():
+		for _, raw := range append(probe.ISBN13, probe.ISBN10...) {
+			if isbn, err := models.NormalizeISBN(raw); err == nil {
+				idx.editions[isbn] = at
+			}
+		}
+	case probe.isWork(), probe.isAuthor():
+		idx.records[probe.Key] = at
+	}
+}
+
+// read decodes the record at, reporting whether it could.
+func (idx *DumpIndex) read(at dumpOffset, v any) (bool, error) {
+	f, err := os.Open(idx.paths[at.file])
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(at.offset, io.SeekStart); err != nil {
+		return false, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return json.Unmarshal(dumpRecord(line), v) == nil, nil
+}
+
+func (idx *DumpIndex) Lookup(_ context.Context, isbn string) (Metadata, error) {
+	at, ok := idx.editions[isbn]
+	if !ok {
+		return Metadata{}, ErrNotFound
+	}
+	var edition olEdition
+	if ok, err := idx.read(at, &edition); err != nil || !ok {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return Metadata{}, err
+	}
+
+	var work *olWork
+	if len(edition.Works) > 0 {
+		if at, ok := idx.records[edition.Works[0].Key]; ok {
+			var w olWork
+			ok, err := idx.read(at, &w)
+			if err != nil {
+				return Metadata{}, err
+			}
+			if ok {
+				work = &w
+			}
+		}
+	}
+
+	var names []string
+	for _, key := range authorKeys(edition, work) {
+		at, ok := idx.records[key]
+		if !ok {
+			continue
+		}
+		var author olAuthor
+		ok, err := idx.read(at, &author)
+		if err != nil {
+			return Metadata{}, err
+		}
+		if ok && author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+	return metadata(edition, work, names), nil
+}
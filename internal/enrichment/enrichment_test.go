@@ -0,0 +1,126 @@
+package enrichment_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"open-library-explorer/internal/enrichment"
+)
+
+const (
+	edition = `{"key": "/books/OL1M", "title": "The Odyssey", "isbn_10": ["0140449132"], "authors": [{"key": "/authors/OL1A"}], "works": [{"key": "/works/OL1W"}], "publishers": ["Penguin"], "publish_date": "March 2003", "number_of_pages": 560}`
+	other   = `{"key": "/books/OL2M", "title": "Other", "isbn_13": ["9783161484100"], "works": [{"key": "/works/OL2W"}]}`
+	work    = `{"key": "/works/OL1W", "title": "Odyssey", "subjects": ["Epic poetry", "Mythology"], "covers": [12345]}`
+	work2   = `{"key": "/works/OL2W", "title": "Other work"}`
+	author  = `{"key": "/authors/OL1A", "name": "Homer"}`
+)
+
+var odyssey = enrichment.Metadata{
+	Title:       "The Odyssey",
+	Authors:     []string{"Homer"},
+	Subjects:    []string{"Epic poetry", "Mythology"},
+	Publishers:  []string{"Penguin"},
+	PublishYear: 2003,
+	CoverID:     12345,
+	PageCount:   560,
+}
+
+func TestDumpProvider(t *testing.T) {
+	// The official dumps prefix each record with type, key, revision and date
+	editions := "/type/edition\t/books/OL1M\t3\t2010-01-01T00:00:00\t" + edition + "\n" + other + "\nnot json\n"
+	works := work + "\n" + work2 + "\n"
+	authors := author + "\n" + `{"key": "/authors/OL9A", "name": "Unrelated"}` + "\n"
+
+	p := enrichment.NewDumpProvider()
+	keep := func(isbn string) bool { return isbn == "9780140449136" }
+	for _, dump := range []string{editions, works, authors} {
+		if _, err := p.Load(strings.NewReader(dump), keep); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	md, err := p.Lookup(context.Background(), "9780140449136")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(md, odyssey) {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+
+	if _, err := p.Lookup(context.Background(), "9783161484100"); !errors.Is(err, enrichment.ErrNotFound) {
+		t.Errorf("expected editions outside keep to be skipped, got %v", err)
+	}
+}
+
+func TestDumpIndex(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, dump := range []string{
+		"/type/edition\t/books/OL1M\t3\t2010-01-01T00:00:00\t" + edition + "\nnot json\n" + other + "\n",
+		work2 + "\n" + work + "\n",
+		author, // no trailing newline
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("dump%d.txt", i))
+		if err := os.WriteFile(path, []byte(dump), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	idx, err := enrichment.IndexDumpFiles(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := idx.Lookup(context.Background(), "9780140449136")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(md, odyssey) {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+	if md, err := idx.Lookup(context.Background(), "9783161484100"); err != nil || md.Title != "Other" {
+		t.Errorf("expected every edition to be indexed, got %+v, %v", md, err)
+	}
+	if _, err := idx.Lookup(context.Background(), "9780306406157"); !errors.Is(err, enrichment.ErrNotFound) {
+		t.Errorf("expected an unknown ISBN not to be found, got %v", err)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	docs := map[string]string{
+		"/isbn/9780140449136.json": edition,
+		"/works/OL1W.json":         work,
+		"/authors/OL1A.json":       author,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	defer server.Close()
+
+	p := &enrichment.HTTPProvider{BaseURL: server.URL, Client: server.Client()}
+
+	md, err := p.Lookup(context.Background(), "9780140449136")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(md, odyssey) {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+
+	if _, err := p.Lookup(context.Background(), "9783161484100"); !errors.Is(err, enrichment.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown ISBN, got %v", err)
+	}
+}
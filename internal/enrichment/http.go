@@ -0,0 +1,70 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPProvider looks ISBNs up in the Open Library API, or anything serving
+// the same /isbn/{isbn}.json, /works/{id}.json and /authors/{id}.json
+// documents under BaseURL.
+type HTTPProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+const OpenLibraryURL = "https://openlibrary.org"
+
+func (p *HTTPProvider) Lookup(ctx context.Context, isbn string) (Metadata, error) {
+	var edition olEdition
+	if err := p.get(ctx, "/isbn/"+isbn+".json", &edition); err != nil {
+		return Metadata{}, err
+	}
+
+	var work *olWork
+	if len(edition.Works) > 0 {
+		var w olWork
+		if err := p.get(ctx, edition.Works[0].Key+".json", &w); err == nil {
+			work = &w
+		}
+	}
+
+	var names []string
+	for _, key := range authorKeys(edition, work) {
+		var author olAuthor
+		if err := p.get(ctx, key+".json", &author); err == nil && author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+	return metadata(edition, work, names), nil
+}
+
+func (p *HTTPProvider) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.BaseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s responded %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
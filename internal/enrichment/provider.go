@@ -0,0 +1,134 @@
+// Package enrichment looks up bibliographic metadata for an ISBN in Open
+// Library data, so books added with only a few fields can be completed.
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"open-library-explorer/configs"
+)
+
+// ErrNotFound is returned by a Provider that has nothing for an ISBN.
+var ErrNotFound = errors.New("no metadata for ISBN")
+
+// Metadata is what a provider knows about an edition, merged with its work.
+type Metadata struct {
+	Title       string
+	Authors     []string
+	Subjects    []string
+	Publishers  []string
+	PublishYear int
+	CoverID     int
+	PageCount   int
+}
+
+// Provider looks up metadata by ISBN-13.
+type Provider interface {
+	Lookup(ctx context.Context, isbn string) (Metadata, error)
+}
+
+// New returns the provider the server enriches new books from: an index of
+// the ENRICHMENT_DUMPS when they are set, else the API at ENRICHMENT_URL. It
+// returns nil when enrichment is not configured.
+func New(cfg configs.Config) (Provider, error) {
+	switch {
+	case len(cfg.EnrichmentDumps) > 0:
+		idx, err := IndexDumpFiles(cfg.EnrichmentDumps)
+		if err != nil {
+			return nil, err
+		}
+		return idx, nil
+	case cfg.EnrichmentURL != "":
+		return &HTTPProvider{BaseURL: cfg.EnrichmentURL}, nil
+	}
+	return nil, nil
+}
+
+// Open Library records as found in the dumps and returned by the API. Only
+// the fields we use are decoded.
+type olRef struct {
+	Key string `json:"key"`
+}
+
+type olEdition struct {
+	Key           string   `json:"key"`
+	Title         string   `json:"title"`
+	ISBN10        []string `json:"isbn_10"`
+	ISBN13        []string `json:"isbn_13"`
+	Authors       []olRef  `json:"authors"`
+	Works         []olRef  `json:"works"`
+	Publishers    []string `json:"publishers"`
+	PublishDate   string   `json:"publish_date"`
+	NumberOfPages int      `json:"number_of_pages"`
+	Covers        []int    `json:"covers"`
+	Subjects      []string `json:"subjects"`
+}
+
+type olWork struct {
+	Key      string   `json:"key"`
+	Title    string   `json:"title"`
+	Subjects []string `json:"subjects"`
+	Covers   []int    `json:"covers"`
+	Authors  []struct {
+		Author olRef `json:"author"`
+	} `json:"authors"`
+}
+
+type olAuthor struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// authorKeys returns the authors of the edition, or of its work when the
+// edition lists none.
+func authorKeys(edition olEdition, work *olWork) []string {
+	var keys []string
+	for _, a := range edition.Authors {
+		keys = append(keys, a.Key)
+	}
+	if len(keys) == 0 && work != nil {
+		for _, a := range work.Authors {
+			keys = append(keys, a.Author.Key)
+		}
+	}
+	return keys
+}
+
+// metadata combines an edition with its work and the names of its authors.
+func metadata(edition olEdition, work *olWork, authorNames []string) Metadata {
+	md := Metadata{
+		Title:       edition.Title,
+		Authors:     authorNames,
+		Subjects:    edition.Subjects,
+		Publishers:  edition.Publishers,
+		PublishYear: year(edition.PublishDate),
+		PageCount:   edition.NumberOfPages,
+	}
+	if len(edition.Covers) > 0 && edition.Covers[0] > 0 {
+		md.CoverID = edition.Covers[0]
+	}
+	if work != nil {
+		if md.Title == "" {
+			md.Title = work.Title
+		}
+		if len(md.Subjects) == 0 {
+			md.Subjects = work.Subjects
+		}
+		if md.CoverID == 0 && len(work.Covers) > 0 && work.Covers[0] > 0 {
+			md.CoverID = work.Covers[0]
+		}
+	}
+	return md
+}
+
+// year finds the four digit year in free-form dates such as "March 1997".
+func year(date string) int {
+	for i := 0; i+4 <= len(date); i++ {
+		if y, err := strconv.Atoi(date[i : i+4]); err == nil && y > 0 {
+			return y
+		}
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+// Package exporter ships audit log entries to external sinks. A sink only
+// returns nil from Export once every entry in the batch has been durably
+// accepted, which is what lets the exporter daemon mark them exported.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"open-library-explorer/configs"
+	"open-library-explorer/internal/models"
+)
+
+const (
+	SinkStdout  = "stdout"
+	SinkFile    = "file"
+	SinkWebhook = "webhook"
+	SinkSyslog  = "syslog"
+)
+
+type Exporter interface {
+	// Export delivers logs as one batch. A non-nil error means none of them
+	// may be considered delivered, so the batch will be sent again.
+	Export(ctx context.Context, logs []models.AuditLog) error
+	Close() error
+}
+
+// New builds the sink selected by cfg.ExportSink.
+func New(cfg configs.Config) (Exporter, error) {
+	switch cfg.ExportSink {
+	case "", SinkStdout:
+		return &StdoutExporter{}, nil
+	case SinkFile:
+		return NewFileExporter(cfg.ExportFilePath, cfg.ExportFileMaxBytes)
+	case SinkWebhook:
+		if cfg.ExportWebhookURL == "" {
+			return nil, fmt.Errorf("AUDIT_EXPORT_WEBHOOK_URL is required for the webhook sink")
+		}
+		return &WebhookExporter{
+			URL:        cfg.ExportWebhookURL,
+			MaxRetries: cfg.ExportWebhookRetries,
+			Backoff:    time.Second,
+		}, nil
+	case SinkSyslog:
+		return NewSyslogExporter(cfg.ExportSyslogNetwork, cfg.ExportSyslogAddr)
+	default:
+		return nil, fmt.Errorf("unknown audit export sink %q", cfg.ExportSink)
+	}
+}
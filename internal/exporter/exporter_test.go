@@ -0,0 +1,124 @@
+package exporter_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/exporter"
+	"open-library-explorer/internal/models"
+)
+
+func auditLogs(n int) []models.AuditLog {
+	logs := make([]models.AuditLog, n)
+	for i := range logs {
+		logs[i] = models.AuditLog{ID: primitive.NewObjectID(), Entity: "book", Action: "create", PerformedBy: "system"}
+	}
+	return logs
+}
+
+func TestFileExporter_WritesNDJSONAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.ndjson")
+
+	e, err := exporter.NewFileExporter(path, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := e.Export(context.Background(), auditLogs(2)); err != nil {
+			t.Fatalf("export %d: %v", i, err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry models.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %d is not JSON: %v", lines, err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("expected the current file to hold the last batch of 2, got %d lines", lines)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Errorf("expected 2 rotated files, got %d", len(rotated))
+	}
+}
+
+func TestWebhookExporter_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []models.AuditLog
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil || len(batch) != 3 {
+			t.Errorf("expected a JSON array of 3 entries, got %d (%v)", len(batch), err)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	e := &exporter.WebhookExporter{URL: srv.URL, MaxRetries: 3, Backoff: time.Millisecond}
+	if err := e.Export(context.Background(), auditLogs(3)); err != nil {
+		t.Fatalf("expected delivery after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestWebhookExporter_GivesUp(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	e := &exporter.WebhookExporter{URL: srv.URL, MaxRetries: 2, Backoff: time.Millisecond}
+	if err := e.Export(context.Background(), auditLogs(1)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 3 {
+		t.Errorf("expected 1 attempt plus 2 retries, got %d calls", calls)
+	}
+
+	// client errors are not worth retrying
+	calls = 0
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer bad.Close()
+
+	e.URL = bad.URL
+	if err := e.Export(context.Background(), auditLogs(1)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("expected no retries on 400, got %d calls", calls)
+	}
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"open-library-explorer/internal/models"
+)
+
+const DefaultFileMaxBytes = 10 << 20
+
+// FileExporter appends entries as NDJSON to a file. Before a batch would grow
+// the file past MaxBytes, the file is renamed with a timestamp suffix and a
+// fresh one is started.
+type FileExporter struct {
+	Path     string
+	MaxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewFileExporter(path string, maxBytes int64) (*FileExporter, error) {
+	if path == "" {
+		return nil, fmt.Errorf("a file path is required for the file sink")
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultFileMaxBytes
+	}
+	e := &FileExporter{Path: path, MaxBytes: maxBytes}
+	if err := e.open(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *FileExporter) open() error {
+	f, err := os.OpenFile(e.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	e.file = f
+	e.size = info.Size()
+	return nil
+}
+
+func (e *FileExporter) rotate() error {
+	if err := e.file.Close(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%s", e.Path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(e.Path, rotated); err != nil {
+		return err
+	}
+	return e.open()
+}
+
+// Export writes the whole batch and syncs it to disk before acknowledging.
+func (e *FileExporter) Export(_ context.Context, logs []models.AuditLog) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, log := range logs {
+		if err := enc.Encode(log); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.size > 0 && e.size+int64(buf.Len()) > e.MaxBytes {
+		if err := e.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := e.file.Write(buf.Bytes())
+	e.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return e.file.Sync()
+}
+
+func (e *FileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
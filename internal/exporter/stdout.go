@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"open-library-explorer/internal/models"
+)
+
+// StdoutExporter writes one JSON document per entry to Out, or to stdout
+// when Out is nil.
+type StdoutExporter struct {
+	Out io.Writer
+}
+
+func (e *StdoutExporter) Export(_ context.Context, logs []models.AuditLog) error {
+	out := e.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	enc := json.NewEncoder(out)
+	for _, log := range logs {
+		if err := enc.Encode(log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StdoutExporter) Close() error {
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"log/syslog"
+
+	"open-library-explorer/internal/models"
+)
+
+// SyslogExporter sends each entry as a JSON message tagged "library-audit".
+// An empty network and address use the local syslog daemon.
+type SyslogExporter struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogExporter(network, addr string) (*SyslogExporter, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTH, "library-audit")
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogExporter{writer: w}, nil
+}
+
+func (e *SyslogExporter) Export(_ context.Context, logs []models.AuditLog) error {
+	for _, log := range logs {
+		msg, err := json.Marshal(log)
+		if err != nil {
+			return err
+		}
+		if err := e.writer.Info(string(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *SyslogExporter) Close() error {
+	return e.writer.Close()
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package exporter
+
+import "errors"
+
+func NewSyslogExporter(network, addr string) (Exporter, error) {
+	return nil, errors.New("the syslog sink is not supported on this platform")
+}
@@ -0,0 +1,90 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"open-library-explorer/internal/models"
+)
+
+// WebhookExporter POSTs each batch as a JSON array to URL. Network errors,
+// 429 and 5xx responses are retried up to MaxRetries times, waiting Backoff
+// before the first retry and doubling it after each one. Any other non-2xx
+// response fails the batch straight away.
+type WebhookExporter struct {
+	URL        string
+	Client     *http.Client
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+type webhookError struct {
+	status    int
+	retryable bool
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("webhook responded %d", e.status)
+}
+
+func (e *WebhookExporter) Export(ctx context.Context, logs []models.AuditLog) error {
+	body, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+
+	delay := e.Backoff
+	for attempt := 0; ; attempt++ {
+		err = e.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if whErr, ok := err.(*webhookError); ok && !whErr.retryable {
+			return err
+		}
+		if attempt >= e.MaxRetries {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (e *WebhookExporter) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return &webhookError{
+		status:    resp.StatusCode,
+		retryable: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+	}
+}
+
+func (e *WebhookExporter) Close() error {
+	return nil
+}
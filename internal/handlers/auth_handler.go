@@ -2,16 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type AuthHandler struct {
-	ConfigCreds struct {
-		UserId       string
-		Username     string
-		UserPassword string
-	}
+	Users       store.UserStore
+	AuditLogger utils.Logger
 }
 
 type LoginRequest struct {
@@ -23,6 +27,13 @@ type LoginResponse struct {
 	Token string `json:"token"`
 }
 
+type CreateUserRequest struct {
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Role     models.Role `json:"role"`
+	MemberID string      `json:"member_id"`
+}
+
 func (a *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -30,14 +41,73 @@ func (a *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expectedPassword := a.ConfigCreds.UserPassword
-	expectedUsername := a.ConfigCreds.Username
-	if expectedPassword != req.Password || expectedUsername != req.Username {
+	user, err := a.Users.GetByUsername(r.Context(), req.Username)
+	if err != nil || !utils.CheckPassword(user.PasswordHash, req.Password) {
 		utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	token, _ := utils.GenerateJWT(a.ConfigCreds.UserId)
+	token, err := utils.GenerateJWT(user)
+	if err != nil {
+		utils.JSONError(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
 
 	json.NewEncoder(w).Encode(LoginResponse{Token: token})
 }
+
+// POST /users
+func (a *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSONError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Username == "" || req.Password == "" {
+		utils.JSONError(w, "Username and password are required", http.StatusBadRequest)
+		return
+	}
+	if !models.IsValidRole(string(req.Role)) {
+		utils.JSONError(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	user := models.User{
+		Username:  req.Username,
+		Role:      req.Role,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if req.Role == models.RolePatron {
+		memberID, err := primitive.ObjectIDFromHex(req.MemberID)
+		if err != nil {
+			utils.JSONError(w, "Patron accounts need a valid member_id", http.StatusBadRequest)
+			return
+		}
+		user.MemberID = memberID
+	}
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		utils.JSONError(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	user.PasswordHash = hash
+
+	err = a.Users.Insert(r.Context(), &user)
+	if errors.Is(err, store.ErrDuplicate) {
+		utils.JSONError(w, "Username already taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.JSONError(w, "Insert failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	a.AuditLogger.Log(r.Context(), models.UserEntity, constants.Create, user)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
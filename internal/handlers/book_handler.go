@@ -1,30 +1,77 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"open-library-explorer/internal/catalog"
 	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/enrichment"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"open-library-explorer/internal/models"
 )
 
+var bookFields = listFields{
+	"isbn":           "isbn",
+	"title":          "title",
+	"contributors":   "contributors",
+	"publisher":      "publisher",
+	"tags":           "tags",
+	"subjects":       "subjects",
+	"language":       "language",
+	"edition":        "edition",
+	"series":         "series",
+	"series_number":  "series_number",
+	"published_year": "published_year",
+	"page_count":     "page_count",
+	"cover_id":       "cover_id",
+	"description":    "description",
+	"score":          store.SortRelevance,
+}
+
+var bookSortable = []string{"isbn", "title", "publisher", "language", "series", "series_number", "published_year", "page_count", "score"}
+
+// defaultFacetLimit is how many values of each facet a search returns unless
+// facet_limit says otherwise.
+const defaultFacetLimit = 10
+
+// SearchResponse is a page of search results with the facets of all matches.
+type SearchResponse struct {
+	ListResponse
+	Facets store.BookFacets `json:"facets"`
+}
+
 type BookHandler struct {
-	BookCollection *mongo.Collection
-	CopyCollection *mongo.Collection
-	AuditLogger    utils.Logger
+	BookStore   store.BookStore
+	CopyStore   store.CopyStore
+	AuditLogger utils.Logger
+	// Enricher, when set, fills in missing metadata of books as they are added
+	Enricher *services.Enricher
+	// Suggester, when set, keeps the autocomplete index behind
+	// GET /books/suggest in step with the catalog
+	Suggester *services.Suggester
+	// Availability answers GET /books/{isbn}/availability
+	Availability *services.AvailabilityEstimator
+	// Withdrawal carries out DELETE /books/{isbn}
+	Withdrawal *services.Withdrawal
 }
 
-func NewBookHandler(bookColl, copyColl *mongo.Collection, logger utils.Logger) *BookHandler {
+func NewBookHandler(books store.BookStore, copies store.CopyStore, logger utils.Logger) *BookHandler {
 	return &BookHandler{
-		BookCollection: bookColl,
-		CopyCollection: copyColl,
-		AuditLogger:    logger,
+		BookStore:   books,
+		CopyStore:   copies,
+		AuditLogger: logger,
 	}
 }
 
@@ -36,10 +83,21 @@ func (h *BookHandler) AddBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	isbn, ok := normalizeISBN(w, book.ISBN)
+	if !ok {
+		return
+	}
+	book.ISBN = isbn
+
+	if err := book.Validate(); err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	_, err := h.BookCollection.InsertOne(ctx, book)
+	err := h.BookStore.Insert(ctx, &book)
 	if err != nil {
 		utils.JSONError(w, "Insert failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -47,45 +105,92 @@ func (h *BookHandler) AddBook(w http.ResponseWriter, r *http.Request) {
 
 	h.AuditLogger.Log(ctx, models.BookEntity, constants.Create, book)
 
+	if h.Enricher != nil {
+		book = h.enrich(ctx, book)
+	}
+	h.indexSuggestions(ctx, book.ISBN)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(book)
 }
 
-// GET /books
+// enrich returns book with its missing metadata filled in. The book is
+// already stored, so a failed lookup is logged and the book kept as it is.
+func (h *BookHandler) enrich(ctx context.Context, book models.Book) models.Book {
+	fields, err := h.Enricher.EnrichBook(ctx, book.ISBN)
+	if errors.Is(err, enrichment.ErrNotFound) {
+		return book
+	}
+	if err != nil {
+		log.Printf("Failed to enrich book %s: %v", book.ISBN, err)
+		return book
+	}
+	if len(fields) == 0 {
+		return book
+	}
+	if enriched, err := h.BookStore.Get(ctx, book.ISBN); err == nil {
+		return enriched
+	}
+	return book
+}
+
+// indexSuggestions refreshes the autocomplete entries of isbn, removing them
+// when the book no longer exists or was withdrawn. The catalog change has already been made,
+// so a failure is logged rather than reported.
+func (h *BookHandler) indexSuggestions(ctx context.Context, isbn string) {
+	if h.Suggester == nil {
+		return
+	}
+	if err := h.Suggester.Refresh(ctx, h.BookStore, isbn); err != nil {
+		log.Printf("Failed to index suggestions for book %s: %v", isbn, err)
+	}
+}
+
+// GET /books?limit=&cursor=&sort=&fields=
 func (h *BookHandler) GetBooks(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	lq, err := parseListQuery(r, bookFields, bookSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := h.BookCollection.Find(ctx, bson.M{})
+	total, err := h.BookStore.Count(ctx, store.BookFilter{})
 	if err != nil {
 		utils.JSONError(w, "Failed to fetch books", http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var books []models.Book
-	if err = cursor.All(ctx, &books); err != nil {
-		utils.JSONError(w, "Error decoding books", http.StatusInternalServerError)
+	if total == 0 {
+		utils.JSONError(w, "No books found", http.StatusNotFound)
 		return
 	}
 
-	if len(books) == 0 {
-		utils.JSONError(w, "No books found", http.StatusNotFound)
+	books, next, err := h.BookStore.FindPage(ctx, store.BookFilter{}, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch books")
 		return
 	}
+	if books == nil {
+		books = []models.Book{}
+	}
 
-	json.NewEncoder(w).Encode(books)
+	writeList(w, lq, books, total, next)
 }
 
 // GET /books/{isbn}
 func (h *BookHandler) GetBook(w http.ResponseWriter, r *http.Request) {
-	isbn := mux.Vars(r)["isbn"]
+	isbn, ok := normalizeISBN(w, mux.Vars(r)["isbn"])
+	if !ok {
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	var book models.Book
-	err := h.BookCollection.FindOne(ctx, bson.M{"isbn": isbn}).Decode(&book)
+	book, err := h.BookStore.Get(ctx, isbn)
 	if err != nil {
 		utils.JSONError(w, "Book not found", http.StatusNotFound)
 		return
@@ -94,9 +199,37 @@ func (h *BookHandler) GetBook(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(book)
 }
 
+// GET /books/{isbn}/availability
+// Copies are counted by status, with the soonest due date of those on loan,
+// the number of holds waiting and the estimated wait of a new hold.
+func (h *BookHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	isbn, ok := normalizeISBN(w, mux.Vars(r)["isbn"])
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.BookStore.Get(ctx, isbn); err != nil {
+		utils.JSONError(w, "Book not found", http.StatusNotFound)
+		return
+	}
+
+	availability, err := h.Availability.Availability(ctx, isbn, time.Now())
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch availability", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(availability)
+}
+
 // PUT /books/{isbn}
 func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
-	isbn := mux.Vars(r)["isbn"]
+	isbn, ok := normalizeISBN(w, mux.Vars(r)["isbn"])
+	if !ok {
+		return
+	}
 
 	var updateData map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
@@ -108,105 +241,377 @@ func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 		utils.JSONError(w, "No update fields provided", http.StatusBadRequest)
 		return
 	}
+	// copies, holds and loans refer to the book by its ISBN; renumbering goes
+	// through the isbn13 migration, which moves them along
+	for _, field := range []string{"_id", "id", "isbn"} {
+		if _, ok := updateData[field]; ok {
+			utils.JSONError(w, field+" cannot be changed", http.StatusBadRequest)
+			return
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	result, err := h.BookCollection.UpdateOne(
-		ctx,
-		bson.M{"isbn": isbn},
-		bson.M{"$set": updateData},
-	)
-
+	book, err := h.BookStore.Get(ctx, isbn)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.JSONError(w, "Book not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		utils.JSONError(w, "Update failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if result.MatchedCount == 0 {
+	if err := validateBookUpdate(book, updateData); err != nil {
+		utils.JSONError(w, "Invalid update: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	modified, err := h.BookStore.Update(ctx, isbn, updateData)
+	if errors.Is(err, store.ErrNotFound) {
 		utils.JSONError(w, "Book not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		utils.JSONError(w, "Update failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	h.AuditLogger.Log(ctx, models.BookEntity, constants.Update, updateData)
 
+	h.indexSuggestions(ctx, isbn)
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message":       "Book updated successfully",
-		"modifiedCount": result.ModifiedCount,
+		"modifiedCount": modified,
 	})
 }
 
-// DELETE /books/{isbn}
+// DELETE /books/{isbn}?cascade=true
+// The book is withdrawn rather than removed. With copies or open holds it is
+// refused with 409 unless cascade is set, which withdraws the copies and
+// cancels the holds too. Copies on loan always have to be checked in first.
 func (h *BookHandler) DeleteBook(w http.ResponseWriter, r *http.Request) {
-	isbn := mux.Vars(r)["isbn"]
+	isbn, ok := normalizeISBN(w, mux.Vars(r)["isbn"])
+	if !ok {
+		return
+	}
+	cascade := r.URL.Query().Get("cascade") == "true"
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	result, err := h.BookCollection.DeleteOne(ctx, bson.M{"isbn": isbn})
-	if err != nil {
-		utils.JSONError(w, "Delete failed", http.StatusInternalServerError)
-		return
-	}
-	if result.DeletedCount == 0 {
-		utils.JSONError(w, "Book not found", http.StatusNotFound)
+	err := h.Withdrawal.WithdrawBook(ctx, isbn, cascade)
+	if writeWithdrawalError(w, err, "Book not found") {
 		return
 	}
 
-	h.AuditLogger.Log(ctx, models.BookEntity, constants.Delete, isbn)
+	h.indexSuggestions(ctx, isbn)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GET /books/search?q=&author=&contributor=&subject=&tag=&publisher=&language=&series=
+// &year_from=&year_to=&available=true&status=&facet_limit=&limit=&cursor=&sort=&fields=
+// tag may be repeated to require several. With q the results are sorted by
+// relevance unless sort says otherwise.
 func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	statusFilter := r.URL.Query().Get("status")
+	params := r.URL.Query()
+	query := params.Get("q")
+	statusFilter := params.Get("status")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	lq, err := parseListQuery(r, bookFields, bookSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lq.Page.Sort == store.SortRelevance && query == "" {
+		utils.JSONError(w, "Sorting by score needs a q", http.StatusBadRequest)
+		return
+	}
+	if query != "" && params.Get("sort") == "" {
+		lq.Page.Sort, lq.Page.Desc = store.SortRelevance, true
+	}
 
-	filter := bson.M{}
+	filter := store.BookFilter{
+		Query:       query,
+		Author:      params.Get("author"),
+		Contributor: params.Get("contributor"),
+		Subject:     params.Get("subject"),
+		Tags:        params["tag"],
+		Publisher:   params.Get("publisher"),
+		Language:    params.Get("language"),
+		Series:      params.Get("series"),
+	}
 
-	if query != "" {
-		filter["$text"] = bson.M{"$search": query}
+	facetLimit := defaultFacetLimit
+	for name, dst := range map[string]*int{"year_from": &filter.YearFrom, "year_to": &filter.YearTo, "facet_limit": &facetLimit} {
+		if val := params.Get(name); val != "" {
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				utils.JSONError(w, name+" must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
 	if statusFilter != "" {
 		if !models.IsValidCopyStatus(statusFilter) {
 			utils.JSONError(w, "Invalid status", http.StatusInternalServerError)
 			return
 		}
-		copiesColl := h.CopyCollection
+		filter.CopyStatuses = append(filter.CopyStatuses, models.CopyStatus(statusFilter))
+	}
+	if params.Get("available") == "true" {
+		filter.CopyStatuses = append(filter.CopyStatuses, models.StatusAvailable)
+	}
+
+	total, err := h.BookStore.Count(ctx, filter)
+	if err != nil {
+		utils.JSONError(w, "Failed to search books: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if total == 0 {
+		utils.JSONError(w, "No record found", http.StatusNotFound)
+		return
+	}
+
+	results, next, err := h.BookStore.FindPage(ctx, filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to search books")
+		return
+	}
+	if results == nil {
+		results = []models.Book{}
+	}
+
+	facets, err := h.BookStore.Facets(ctx, filter, facetLimit)
+	if err != nil {
+		utils.JSONError(w, "Failed to count facets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list, err := listResponse(lq, results, total, next)
+	if err != nil {
+		utils.JSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(SearchResponse{
+		ListResponse: list,
+		Facets:       facets,
+	})
+}
+
+// maxSuggestions caps the limit of GET /books/suggest.
+const maxSuggestions = 25
 
-		isbnList, err := copiesColl.Distinct(ctx, "isbn", bson.M{"status": statusFilter})
-		if err != nil {
-			utils.JSONError(w, "Failed to query copies: "+err.Error(), http.StatusInternalServerError)
+// GET /books/suggest?q=&limit=
+// q may be the start of a title or author name and may contain typos.
+// Suggestions of books that are loaned more often come first.
+func (h *BookHandler) SuggestBooks(w http.ResponseWriter, r *http.Request) {
+	if h.Suggester == nil {
+		utils.JSONError(w, "Suggestions are not available", http.StatusNotImplemented)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(q)) < 2 {
+		utils.JSONError(w, "q must be at least 2 characters", http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 || n > maxSuggestions {
+			utils.JSONError(w, fmt.Sprintf("limit must be between 1 and %d", maxSuggestions), http.StatusBadRequest)
 			return
 		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
 
-		if len(isbnList) == 0 {
-			utils.JSONError(w, "No record found", http.StatusNotFound)
+	suggestions, err := h.Suggester.Suggest(ctx, q, limit)
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch suggestions", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// maxImportBytes caps the size of an import upload.
+const maxImportBytes = 64 << 20
+
+// POST /books/import?format=marc|marcxml|csv&copies=n
+// The format can also be given through Content-Type. Every record is
+// reported as created, updated or rejected.
+func (h *BookHandler) ImportBooks(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = catalog.FormatFromContentType(r.Header.Get("Content-Type"))
+	}
+	if format == "" {
+		utils.JSONError(w, "Specify format as marc, marcxml or csv", http.StatusBadRequest)
+		return
+	}
+
+	copies := 0
+	if val := r.URL.Query().Get("copies"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			utils.JSONError(w, "copies must be a non-negative number", http.StatusBadRequest)
 			return
 		}
-		filter["isbn"] = bson.M{"$in": isbnList}
+		copies = n
 	}
 
-	cursor, err := h.BookCollection.Find(ctx, filter)
+	records, err := catalog.Parse(format, http.MaxBytesReader(w, r.Body, maxImportBytes))
 	if err != nil {
-		utils.JSONError(w, "Failed to search books: "+err.Error(), http.StatusInternalServerError)
+		utils.JSONError(w, "Failed to read import: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var results []models.Book
-	if err = cursor.All(ctx, &results); err != nil {
-		utils.JSONError(w, "Failed to decode books", http.StatusInternalServerError)
+	importer := services.CatalogImporter{
+		Books:       h.BookStore,
+		Copies:      h.CopyStore,
+		AuditLogger: h.AuditLogger,
+	}
+	report := importer.Import(r.Context(), records, copies)
+	for _, result := range report.Records {
+		if result.Status != services.ImportRejected {
+			h.indexSuggestions(r.Context(), result.ISBN)
+		}
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// GET /books/export?format=marcxml|csv|ndjson&copies=true
+// The catalog is streamed, so a failure part way through can only cut the
+// response short.
+func (h *BookHandler) ExportBooks(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = catalog.FormatNDJSON
+	}
+	withCopies := r.URL.Query().Get("copies") == "true"
+
+	contentType := catalog.ContentType(format)
+	if contentType == "" {
+		utils.JSONError(w, "Specify format as marcxml, csv or ndjson", http.StatusBadRequest)
 		return
 	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=catalog."+format)
 
-	if len(results) == 0 {
-		utils.JSONError(w, "No record found", http.StatusNotFound)
+	writer, err := catalog.NewWriter(format, w, withCopies)
+	if err != nil {
+		log.Printf("Catalog export failed: %v", err)
+		return
+	}
+
+	exporter := services.CatalogExporter{Books: h.BookStore, Copies: h.CopyStore}
+	if _, err := exporter.Export(r.Context(), writer, withCopies); err != nil {
+		log.Printf("Catalog export failed: %v", err)
 		return
 	}
+	if err := writer.Close(); err != nil {
+		log.Printf("Catalog export failed: %v", err)
+	}
+}
 
-	json.NewEncoder(w).Encode(results)
+// writeWithdrawalError maps the errors of a withdrawal to a response and
+// reports whether it wrote one.
+func writeWithdrawalError(w http.ResponseWriter, err error, notFound string) bool {
+	var deps *services.DependentsError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, store.ErrNotFound):
+		utils.JSONError(w, notFound, http.StatusNotFound)
+	case errors.As(err, &deps):
+		utils.JSONError(w, "Cannot delete: "+deps.Error(), http.StatusConflict)
+	case errors.Is(err, store.ErrConflict):
+		utils.JSONError(w, "Copy changed status during the delete, try again", http.StatusConflict)
+	default:
+		utils.JSONError(w, "Delete failed", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// normalizeISBN converts isbn to the stored ISBN-13 form, answering 400 when
+// it is not a valid ISBN-10 or ISBN-13.
+func normalizeISBN(w http.ResponseWriter, isbn string) (string, bool) {
+	normalized, err := models.NormalizeISBN(isbn)
+	if err != nil {
+		utils.JSONError(w, "Invalid ISBN", http.StatusBadRequest)
+		return "", false
+	}
+	return normalized, true
+}
+
+// validateBookUpdate checks an update payload against the book model: every
+// field must be a known one of the right type, and stored with the update
+// applied must pass Book.Validate, so rules across fields also see the ones
+// the update leaves alone. Contributors are replaced by their typed form.
+func validateBookUpdate(stored models.Book, updateData map[string]interface{}) error {
+	if _, ok := updateData["withdrawn_at"]; ok {
+		return errors.New("withdrawn_at is set by deleting the book")
+	}
+	raw, err := json.Marshal(updateData)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	var update models.Book
+	if err := dec.Decode(&update); err != nil {
+		return err
+	}
+
+	// null clears a field, which decoding onto the stored book would ignore
+	merged := map[string]interface{}{}
+	if raw, err = json.Marshal(stored); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		return err
+	}
+	for field, value := range updateData {
+		merged[field] = value
+	}
+	if raw, err = json.Marshal(merged); err != nil {
+		return err
+	}
+	var book models.Book
+	if err := json.Unmarshal(raw, &book); err != nil {
+		return err
+	}
+	if err := book.Validate(); err != nil {
+		return err
+	}
+	if updateData["contributors"] != nil {
+		updateData["contributors"] = update.Contributors
+	}
+	return nil
+}
+
+// normalizeISBNField normalizes the isbn of an update payload, if it has one.
+func normalizeISBNField(w http.ResponseWriter, updateData map[string]interface{}) bool {
+	val, ok := updateData["isbn"]
+	if !ok {
+		return true
+	}
+	raw, _ := val.(string)
+	isbn, ok := normalizeISBN(w, raw)
+	if ok {
+		updateData["isbn"] = isbn
+	}
+	return ok
 }
@@ -4,27 +4,30 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
 
+	"open-library-explorer/configs"
+	"open-library-explorer/internal/enrichment"
 	"open-library-explorer/internal/handlers"
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
 )
 
 func TestBookHandler_AddBook(t *testing.T) {
-	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
-	if mt.Client != nil {
-		defer mt.Client.Disconnect(context.Background())
-	}
-
-	mt.Run("successful book addition", func(mt *mtest.T) {
+	t.Run("successful book addition", func(t *testing.T) {
 		handler := handlers.BookHandler{
-			BookCollection: mt.Coll,
+			BookStore: store.NewMemoryBookStore(),
 		}
 
 		router := mux.NewRouter()
@@ -44,21 +47,24 @@ func TestBookHandler_AddBook(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusInternalServerError {
+		if res.StatusCode != http.StatusCreated {
 			t.Errorf("expected status Created, got %v", res.Status)
 		}
 	})
 
-	mt.Run("invalid book data", func(mt *mtest.T) {
+	t.Run("duplicate isbn", func(t *testing.T) {
+		books := store.NewMemoryBookStore()
+		books.Insert(context.Background(), &models.Book{ISBN: "9783161484100"})
+
 		handler := handlers.BookHandler{
-			BookCollection: mt.Coll,
+			BookStore: books,
 		}
 
 		router := mux.NewRouter()
 		router.HandleFunc("/books", handler.AddBook).Methods("POST")
 
-		// Sending an empty JSON body
-		req := httptest.NewRequest(http.MethodPost, "/books", bytes.NewReader([]byte("{}")))
+		reqBytes, _ := json.Marshal(models.Book{ISBN: "978-3-16-148410-0"})
+		req := httptest.NewRequest(http.MethodPost, "/books", bytes.NewReader(reqBytes))
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
@@ -67,30 +73,156 @@ func TestBookHandler_AddBook(t *testing.T) {
 		defer res.Body.Close()
 
 		if res.StatusCode != http.StatusInternalServerError {
+			t.Errorf("expected status Internal server error, got %v", res.Status)
+		}
+	})
+
+	t.Run("invalid book data", func(t *testing.T) {
+		handler := handlers.BookHandler{
+			BookStore: store.NewMemoryBookStore(),
+		}
+
+		router := mux.NewRouter()
+		router.HandleFunc("/books", handler.AddBook).Methods("POST")
+
+		req := httptest.NewRequest(http.MethodPost, "/books", bytes.NewReader([]byte("{")))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusBadRequest {
 			t.Errorf("expected status BadRequest, got %v", res.Status)
 		}
 	})
 }
 
-func TestBookHandler_GetBooks(t *testing.T) {
-	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
-	if mt.Client != nil {
-		defer mt.Client.Disconnect(context.Background())
+type stubProvider map[string]enrichment.Metadata
+
+func (p stubProvider) Lookup(_ context.Context, isbn string) (enrichment.Metadata, error) {
+	md, ok := p[isbn]
+	if !ok {
+		return enrichment.Metadata{}, enrichment.ErrNotFound
+	}
+	return md, nil
+}
+
+func TestBookHandler_AddBookEnriched(t *testing.T) {
+	books := store.NewMemoryBookStore()
+	handler := handlers.NewBookHandler(books, nil, utils.Logger{})
+	handler.Enricher = &services.Enricher{
+		Books:    books,
+		Provider: stubProvider{"9780140449136": {Authors: []string{"Homer"}, CoverID: 12345, PageCount: 560}},
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/books", handler.AddBook).Methods("POST")
+
+	for isbn, wantPages := range map[string]int{"0140449132": 560, "9783161484100": 0} {
+		body := `{"isbn": "` + isbn + `", "title": "Given title"}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body)))
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: expected status 201, got %d", isbn, w.Code)
+		}
+		var got models.Book
+		json.NewDecoder(w.Body).Decode(&got)
+		if got.Title != "Given title" || got.PageCount != wantPages {
+			t.Errorf("%s: unexpected book %+v", isbn, got)
+		}
+	}
+
+	stored, _ := books.Get(context.Background(), "9780140449136")
+	if len(stored.Authors()) != 1 || stored.CoverID != 12345 {
+		t.Errorf("expected the enriched book to be stored, got %+v", stored)
+	}
+}
+
+func TestBookHandler_AddBookEnrichedFromDumps(t *testing.T) {
+	dir := t.TempDir()
+	dumps := map[string]string{
+		"editions.txt": `{"key": "/books/OL1M", "title": "The Odyssey", "isbn_10": ["0140449132"], "authors": [{"key": "/authors/OL1A"}], "works": [{"key": "/works/OL1W"}], "number_of_pages": 560}`,
+		"works.txt":    `{"key": "/works/OL1W", "title": "Odyssey", "subjects": ["Epic poetry"]}`,
+		"authors.txt":  `{"key": "/authors/OL1A", "name": "Homer"}`,
+	}
+	var paths []string
+	for _, name := range []string{"editions.txt", "works.txt", "authors.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(dumps[name]+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	t.Setenv("ENRICHMENT_DUMPS", strings.Join(paths, ","))
+	t.Setenv("ENRICHMENT_URL", "")
+
+	provider, err := enrichment.New(configs.LoadConfig())
+	if err != nil || provider == nil {
+		t.Fatalf("expected a provider for the dumps, got %v, %v", provider, err)
+	}
+	books := store.NewMemoryBookStore()
+	handler := handlers.NewBookHandler(books, nil, utils.Logger{})
+	handler.Enricher = &services.Enricher{Books: books, Provider: provider}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/books", handler.AddBook).Methods("POST")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"isbn": "9780140449136", "title": "The Odyssey"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
 	}
 
-	mt.Run("successful books retrieval", func(mt *mtest.T) {
+	var got models.Book
+	json.NewDecoder(w.Body).Decode(&got)
+	if authors := got.Authors(); len(authors) != 1 || authors[0] != "Homer" || got.PageCount != 560 || len(got.Subjects) != 1 {
+		t.Errorf("expected the book to be enriched from the dumps, got %+v", got)
+	}
+}
+
+func TestBookHandler_GetBooks(t *testing.T) {
+	t.Run("successful books retrieval", func(t *testing.T) {
+		books := store.NewMemoryBookStore()
+		books.Insert(context.Background(), &models.Book{Title: "Test Book", ISBN: "978-3-16-148410-0"})
+
 		handler := handlers.BookHandler{
-			BookCollection: mt.Coll,
+			BookStore: books,
 		}
 
 		router := mux.NewRouter()
 		router.HandleFunc("/books", handler.GetBooks).Methods("GET")
 
-		// Mock response for books
-		mt.AddMockResponses(mtest.CreateCursorResponse(1, "test.books", mtest.FirstBatch, bson.D{
-			{Key: "title", Value: "Test Book"},
-			{Key: "isbn", Value: "978-3-16-148410-0"},
-		}))
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		res := w.Result()
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status OK, got %v", res.Status)
+		}
+
+		var got struct {
+			Items []models.Book `json:"items"`
+			Total int64         `json:"total"`
+		}
+		json.NewDecoder(res.Body).Decode(&got)
+		if got.Total != 1 || len(got.Items) != 1 || got.Items[0].Title != "Test Book" {
+			t.Errorf("unexpected books %+v", got)
+		}
+	})
+
+	t.Run("no books", func(t *testing.T) {
+		handler := handlers.BookHandler{
+			BookStore: store.NewMemoryBookStore(),
+		}
+
+		router := mux.NewRouter()
+		router.HandleFunc("/books", handler.GetBooks).Methods("GET")
 
 		req := httptest.NewRequest(http.MethodGet, "/books", nil)
 		w := httptest.NewRecorder()
@@ -100,8 +232,301 @@ func TestBookHandler_GetBooks(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusInternalServerError {
-			t.Errorf("expected status Internal server error, got %v", res.Status)
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status NotFound, got %v", res.Status)
 		}
 	})
 }
+
+func TestBookHandler_GetBooksPagination(t *testing.T) {
+	books := store.NewMemoryBookStore()
+	for _, b := range []models.Book{
+		{ISBN: "1", Title: "Emma", PublishedYear: 1815},
+		{ISBN: "2", Title: "Dracula", PublishedYear: 1897},
+		{ISBN: "3", Title: "Beloved", PublishedYear: 1987},
+		{ISBN: "4", Title: "Carrie", PublishedYear: 1974},
+		{ISBN: "5", Title: "Atonement", PublishedYear: 2001},
+	} {
+		books.Insert(context.Background(), &b)
+	}
+
+	handler := handlers.BookHandler{BookStore: books}
+	router := mux.NewRouter()
+	router.HandleFunc("/books", handler.GetBooks).Methods("GET")
+
+	type page struct {
+		Items []map[string]interface{} `json:"items"`
+		Total int64                    `json:"total"`
+		Limit int                      `json:"limit"`
+		Next  string                   `json:"next"`
+	}
+	get := func(query string) (int, page) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books?"+query, nil))
+		var p page
+		json.NewDecoder(w.Body).Decode(&p)
+		return w.Code, p
+	}
+
+	var titles []string
+	query := "limit=2&sort=-title&fields=title"
+	for pages := 0; ; pages++ {
+		code, p := get(query)
+		if code != http.StatusOK {
+			t.Fatalf("expected OK, got %d", code)
+		}
+		if p.Total != 5 || p.Limit != 2 {
+			t.Errorf("unexpected envelope total=%d limit=%d", p.Total, p.Limit)
+		}
+		for _, item := range p.Items {
+			if len(item) != 1 {
+				t.Errorf("expected only the title field, got %v", item)
+			}
+			titles = append(titles, item["title"].(string))
+		}
+		if p.Next == "" {
+			break
+		}
+		if pages > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		query = "limit=2&sort=-title&fields=title&cursor=" + p.Next
+	}
+
+	want := []string{"Emma", "Dracula", "Carrie", "Beloved", "Atonement"}
+	if strings.Join(titles, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, titles)
+	}
+
+	for _, bad := range []string{"limit=0", "limit=abc", "sort=tags", "fields=nope", "cursor=garbage"} {
+		if code, _ := get(bad); code != http.StatusBadRequest {
+			t.Errorf("%s: expected BadRequest, got %d", bad, code)
+		}
+	}
+
+	// a cursor only resumes the sort order it was issued for
+	_, first := get("limit=2&sort=title")
+	if code, _ := get("limit=2&sort=published_year&cursor=" + first.Next); code != http.StatusBadRequest {
+		t.Errorf("cursor with a different sort: expected BadRequest, got %d", code)
+	}
+}
+
+func TestBookHandler_ImportBooks(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := handlers.NewBookHandler(stores.Books, stores.Copies, utils.Logger{})
+	router := mux.NewRouter()
+	router.HandleFunc("/books/import", handler.ImportBooks).Methods("POST")
+
+	body := "isbn,title,author\n9780140449136,Crime and Punishment,Fyodor Dostoyevsky\n,Missing ISBN,Nobody\n"
+	req := httptest.NewRequest(http.MethodPost, "/books/import?copies=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected OK, got %d: %s", w.Code, w.Body)
+	}
+	var report services.ImportReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Created != 1 || report.Rejected != 1 || report.CopiesCreated != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("without a format: expected BadRequest, got %d", w.Code)
+	}
+}
+
+func TestBookHandler_UpdateBook(t *testing.T) {
+	books := store.NewMemoryBookStore()
+	books.Insert(context.Background(), &models.Book{ISBN: "9780140449136", Title: "Crime and Punishment", Edition: "1st"})
+
+	handler := handlers.BookHandler{BookStore: books}
+	router := mux.NewRouter()
+	router.HandleFunc("/books/{isbn}", handler.UpdateBook).Methods("PUT")
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"legacy author field", `{"author": "Dostoyevsky"}`, http.StatusBadRequest},
+		{"unknown role", `{"contributors": [{"name": "Dostoyevsky", "role": "ghost"}]}`, http.StatusBadRequest},
+		{"wrong type", `{"page_count": "many"}`, http.StatusBadRequest},
+		{"valid", `{"contributors": [{"name": "Fyodor Dostoyevsky", "role": "author"}, {"name": "David McDuff", "role": "translator"}],
+			"subjects": ["Murder"], "language": "eng", "series": "Penguin Classics", "series_number": 12, "edition": null}`, http.StatusOK},
+		{"series number of the stored series", `{"series_number": 3}`, http.StatusOK},
+		{"series removed under its number", `{"series": null}`, http.StatusBadRequest},
+		{"isbn renumbered", `{"isbn": "9783161484100"}`, http.StatusBadRequest},
+		{"id replaced", `{"_id": "9783161484100"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/books/0140449132", strings.NewReader(tt.body)))
+			if w.Code != tt.code {
+				t.Errorf("expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	book, _ := books.Get(context.Background(), "9780140449136")
+	if len(book.Contributors) != 2 || book.Contributors[1].Role != models.ContributorTranslator ||
+		book.Series != "Penguin Classics" || book.SeriesNumber != 3 || book.Edition != "" {
+		t.Errorf("unexpected book after update %+v", book)
+	}
+}
+
+func TestBookHandler_SearchBooks(t *testing.T) {
+	ctx := context.Background()
+	books := store.NewMemoryBookStore()
+	for _, b := range []models.Book{
+		{ISBN: "1", Title: "The Odyssey", Description: "Wilson's Odyssey", Language: "eng", Subjects: []string{"Epic poetry"},
+			Tags: []string{"classic", "greek"}, PublishedYear: 2017, Publisher: "Norton",
+			Contributors: []models.Contributor{{Name: "Homer", Role: models.ContributorAuthor}, {Name: "Emily Wilson", Role: models.ContributorTranslator}}},
+		{ISBN: "2", Title: "Odyssee", Language: "ger", Subjects: []string{"Epic poetry"}, Tags: []string{"classic"}, PublishedYear: 1781,
+			Contributors: []models.Contributor{{Name: "Homer", Role: models.ContributorAuthor}}},
+		{ISBN: "3", Title: "Ulysses", Language: "eng", Description: "A modern odyssey", Tags: []string{"classic"}, PublishedYear: 1922,
+			Contributors: []models.Contributor{{Name: "James Joyce", Role: models.ContributorAuthor}}},
+	} {
+		books.Insert(ctx, &b)
+	}
+	copies := store.NewMemoryCopyStore()
+	copies.Insert(ctx, &models.Copy{ISBN: "1", Barcode: "1-1", Status: models.StatusOnLoan})
+	copies.Insert(ctx, &models.Copy{ISBN: "3", Barcode: "3-1", Status: models.StatusAvailable})
+	books.Copies = copies
+
+	handler := handlers.NewBookHandler(books, copies, utils.Logger{})
+	router := mux.NewRouter()
+	router.HandleFunc("/books/search", handler.SearchBooks).Methods("GET")
+
+	search := func(query string) (int, handlers.SearchResponse, []models.Book) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/search?"+query, nil))
+		var resp handlers.SearchResponse
+		var items struct {
+			Items []models.Book `json:"items"`
+		}
+		body := w.Body.Bytes()
+		json.Unmarshal(body, &resp)
+		json.Unmarshal(body, &items)
+		return w.Code, resp, items.Items
+	}
+
+	tests := []struct {
+		query string
+		isbns []string
+	}{
+		{"q=wilson", []string{"1"}},
+		{"q=odyssey", []string{"1", "3"}}, // by relevance
+		{"q=odyssey&sort=-isbn", []string{"3", "1"}},
+		{"contributor=Homer&language=eng", []string{"1"}},
+		{"author=Emily+Wilson", nil},
+		{"author=Homer&sort=published_year", []string{"2", "1"}},
+		{"subject=Epic+poetry&sort=-isbn", []string{"2", "1"}},
+		{"tag=classic&tag=greek", []string{"1"}},
+		{"publisher=Norton", []string{"1"}},
+		{"year_from=1800&year_to=1999", []string{"3"}},
+		{"available=true", []string{"3"}},
+		{"status=ON_LOAN", []string{"1"}},
+		{"status=ON_LOAN&available=true", nil},
+	}
+	for _, tt := range tests {
+		_, _, items := search(tt.query)
+		var isbns []string
+		for _, b := range items {
+			isbns = append(isbns, b.ISBN)
+		}
+		if strings.Join(isbns, ",") != strings.Join(tt.isbns, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.isbns, isbns)
+		}
+	}
+
+	_, _, items := search("q=odyssey")
+	if items[0].Score <= items[1].Score {
+		t.Errorf("expected relevance scores in the results, got %+v", items)
+	}
+
+	code, resp, _ := search("tag=classic&facet_limit=1")
+	if code != http.StatusOK {
+		t.Fatalf("expected OK, got %d", code)
+	}
+	facets := resp.Facets
+	if len(facets.Authors) != 1 || facets.Authors[0] != (store.FacetCount{Value: "Homer", Count: 2}) {
+		t.Errorf("unexpected author facet %+v", facets.Authors)
+	}
+	if len(facets.Tags) != 1 || facets.Tags[0] != (store.FacetCount{Value: "classic", Count: 3}) {
+		t.Errorf("unexpected tag facet %+v", facets.Tags)
+	}
+	decades := []store.FacetCount{{Value: "1780", Count: 1}, {Value: "1920", Count: 1}, {Value: "2010", Count: 1}}
+	if fmt.Sprint(facets.Decades) != fmt.Sprint(decades) {
+		t.Errorf("unexpected decade facet %+v", facets.Decades)
+	}
+	availability := []store.FacetCount{{Value: "available", Count: 1}, {Value: "unavailable", Count: 2}}
+	if fmt.Sprint(facets.Availability) != fmt.Sprint(availability) {
+		t.Errorf("unexpected availability facet %+v", facets.Availability)
+	}
+
+	if code, _, _ := search("sort=score"); code != http.StatusBadRequest {
+		t.Errorf("expected sorting by score without q to be refused, got %d", code)
+	}
+}
+
+func TestBookHandler_GetAvailability(t *testing.T) {
+	ctx := context.Background()
+	books, copies := store.NewMemoryBookStore(), store.NewMemoryCopyStore()
+	loans, holds := store.NewMemoryLoanStore(), store.NewMemoryHoldStore()
+	books.Insert(ctx, &models.Book{ISBN: "9783161484100", Title: "Test Book"})
+
+	now := time.Now()
+	soon, later := now.AddDate(0, 0, 3), now.AddDate(0, 0, 5)
+	copies.Insert(ctx, &models.Copy{Barcode: "C-1", ISBN: "9783161484100", Status: models.StatusOnLoan})
+	copies.Insert(ctx, &models.Copy{Barcode: "C-2", ISBN: "9783161484100", Status: models.StatusOnLoan})
+	copies.Insert(ctx, &models.Copy{Barcode: "C-3", ISBN: "9783161484100", Status: models.StatusLost})
+	loans.Insert(ctx, &models.Loan{CopyBarcode: "C-1", DueDate: later})
+	loans.Insert(ctx, &models.Loan{CopyBarcode: "C-2", DueDate: soon})
+	for i := 0; i < 2; i++ {
+		holds.Insert(ctx, &models.Hold{ISBN: "9783161484100", Timestamp: now})
+	}
+
+	handler := handlers.BookHandler{
+		BookStore: books,
+		Availability: &services.AvailabilityEstimator{
+			Copies: copies, Loans: loans, Holds: holds, LoanDays: 7,
+		},
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/books/{isbn}/availability", handler.GetAvailability).Methods("GET")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/3-16-148410-X/availability", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body)
+	}
+	var got services.Availability
+	json.NewDecoder(w.Body).Decode(&got)
+
+	if got.Copies != 3 || got.ByStatus[models.StatusOnLoan] != 2 || got.ByStatus[models.StatusLost] != 1 {
+		t.Errorf("unexpected counts %+v", got)
+	}
+	if got.NextDue == nil || !got.NextDue.Equal(soon) {
+		t.Errorf("expected the soonest due date %v, got %v", soon, got.NextDue)
+	}
+	if got.HoldQueue != 2 {
+		t.Errorf("expected 2 holds waiting, got %d", got.HoldQueue)
+	}
+	// The two holds ahead get C-2 in 3 days and C-1 in 5; C-2 comes back 7
+	// days after that
+	if got.EstimatedWaitDays == nil || *got.EstimatedWaitDays != 10 {
+		t.Errorf("expected a wait of 10 days, got %v", got.EstimatedWaitDays)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/9780140449136/availability", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status Not Found for an unknown book, got %d", w.Code)
+	}
+}
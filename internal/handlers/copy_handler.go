@@ -3,22 +3,38 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"errors"
 	"net/http"
 	"open-library-explorer/internal/constants"
 	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
 )
 
+var copyFields = listFields{
+	"id":         "_id",
+	"isbn":       "isbn",
+	"barcode":    "barcode",
+	"status":     "status",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+var copySortable = []string{"barcode", "isbn", "status", "created_at", "updated_at"}
+
 type CopyHandler struct {
-	Collection  *mongo.Collection
+	Store       store.CopyStore
 	AuditLogger utils.Logger
+	// Withdrawal carries out DELETE /copies/{barcode}
+	Withdrawal *services.Withdrawal
+	// LostItems, when set, settles the loan and charges of a lost copy that
+	// is found
+	LostItems *services.LostItems
 }
 
 // POST /copies
@@ -29,59 +45,86 @@ func (h *CopyHandler) AddCopy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isbn, ok := normalizeISBN(w, copyObj.ISBN)
+	if !ok {
+		return
+	}
+	copyObj.ISBN = isbn
+
+	// Copies enter circulation on the shelf; other statuses are reached
+	// through the state machine
+	if copyObj.Status == "" {
+		copyObj.Status = models.StatusAvailable
+	}
+	if copyObj.Status != models.StatusAvailable {
+		utils.JSONError(w, "New copies must be AVAILABLE", http.StatusBadRequest)
+		return
+	}
+	copyObj.WithdrawnAt = nil
+
 	copyObj.CreatedAt = time.Now()
 	copyObj.UpdatedAt = time.Now()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	res, err := h.Collection.InsertOne(ctx, copyObj)
+	err := h.Store.Insert(ctx, &copyObj)
 	if err != nil {
 		utils.JSONError(w, "Insert failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	copyObj.ID = res.InsertedID.(primitive.ObjectID)
-
 	h.AuditLogger.Log(ctx, models.CopyEntity, constants.Create, copyObj)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(copyObj)
 }
 
-// GET /copies?isbn=xxx
+// GET /copies?isbn=xxx&limit=&cursor=&sort=&fields=
 func (h *CopyHandler) GetCopies(w http.ResponseWriter, r *http.Request) {
-	isbn := r.URL.Query().Get("isbn")
-	filter := bson.M{}
-	if isbn != "" {
-		filter["isbn"] = isbn
+	var filter store.CopyFilter
+	if isbn := r.URL.Query().Get("isbn"); isbn != "" {
+		var ok bool
+		if filter.ISBN, ok = normalizeISBN(w, isbn); !ok {
+			return
+		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	lq, err := parseListQuery(r, copyFields, copySortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := h.Collection.Find(ctx, filter)
+	total, err := h.Store.Count(ctx, filter)
 	if err != nil {
 		utils.JSONError(w, "Failed to fetch copies", http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var copies []models.Copy
-	if err = cursor.All(ctx, &copies); err != nil {
-		utils.JSONError(w, "Error decoding result", http.StatusInternalServerError)
+	if total == 0 {
+		utils.JSONError(w, "No copies found", http.StatusNotFound)
 		return
 	}
 
-	if len(copies) == 0 {
-		utils.JSONError(w, "No copies found", http.StatusNotFound)
+	copies, next, err := h.Store.FindPage(ctx, filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch copies")
 		return
 	}
+	if copies == nil {
+		copies = []models.Copy{}
+	}
 
-	json.NewEncoder(w).Encode(copies)
+	writeList(w, lq, copies, total, next)
 }
 
 // PUT /copies/{barcode}
+// status can only be set to LOST, with a reason, or from LOST back to
+// AVAILABLE when the copy is found. Loans and holds move it otherwise.
 func (h *CopyHandler) UpdateCopy(w http.ResponseWriter, r *http.Request) {
 	barcode := mux.Vars(r)["barcode"]
 
@@ -91,59 +134,165 @@ func (h *CopyHandler) UpdateCopy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var status models.CopyStatus
 	if statusVal, ok := updateData["status"]; ok {
 		statusStr, ok := statusVal.(string)
 		if !ok || !models.IsValidCopyStatus(statusStr) {
 			utils.JSONError(w, "Invalid status value", http.StatusBadRequest)
 			return
 		}
+		status = models.CopyStatus(statusStr)
+	}
+	reason, _ := updateData["reason"].(string)
+	delete(updateData, "status")
+	delete(updateData, "reason")
+	if _, ok := updateData["withdrawn_at"]; ok {
+		utils.JSONError(w, "withdrawn_at is set by deleting the copy", http.StatusBadRequest)
+		return
+	}
+	// loans, holds and fines refer to the copy by its barcode
+	for _, field := range []string{"_id", "id", "barcode", "created_at"} {
+		if _, ok := updateData[field]; ok {
+			utils.JSONError(w, field+" cannot be changed", http.StatusBadRequest)
+			return
+		}
+	}
+	if !normalizeISBNField(w, updateData) {
+		return
 	}
 
-	updateData["updated_at"] = time.Now()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	result, err := h.Collection.UpdateOne(
-		ctx,
-		bson.M{"barcode": barcode},
-		bson.M{"$set": updateData},
-	)
-
-	if err != nil {
-		utils.JSONError(w, "Update failed", http.StatusInternalServerError)
-		return
-	}
-	if result.MatchedCount == 0 {
-		utils.JSONError(w, "Copy not found", http.StatusNotFound)
+	if status != "" && !h.changeStatus(ctx, w, barcode, status, reason) {
 		return
 	}
 
-	h.AuditLogger.Log(ctx, models.CopyEntity, constants.Create, updateData)
+	if len(updateData) > 0 {
+		updateData["updated_at"] = time.Now()
+		err := h.Store.Update(ctx, barcode, updateData)
+		if errors.Is(err, store.ErrNotFound) {
+			utils.JSONError(w, "Copy not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrInvalidTransition) {
+			utils.JSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.JSONError(w, "Update failed", http.StatusInternalServerError)
+			return
+		}
+
+		h.AuditLogger.Log(ctx, models.CopyEntity, constants.Update, updateData)
+	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "Copy updated",
 	})
 }
 
-// DELETE /copies/{barcode}
-func (h *CopyHandler) DeleteCopy(w http.ResponseWriter, r *http.Request) {
-	barcode := mux.Vars(r)["barcode"]
+// changeStatus applies a manual status change: marking a copy lost or found.
+// It reports whether it succeeded, having written the error response if not.
+func (h *CopyHandler) changeStatus(ctx context.Context, w http.ResponseWriter, barcode string, to models.CopyStatus, reason string) bool {
+	copyObj, err := h.Store.Get(ctx, barcode)
+	if err != nil {
+		utils.JSONError(w, "Copy not found", http.StatusNotFound)
+		return false
+	}
+	if copyObj.Status == to {
+		return true
+	}
+
+	change := models.StatusChange{From: copyObj.Status, To: to, Reason: reason}
+	action := constants.Lost
+	switch {
+	case to == models.StatusLost:
+		change.Trigger = models.TriggerLost
+		if reason == "" {
+			utils.JSONError(w, "A reason is required to mark a copy lost", http.StatusBadRequest)
+			return false
+		}
+	case copyObj.Status == models.StatusLost && to == models.StatusAvailable:
+		if h.LostItems != nil {
+			return h.found(ctx, w, barcode, reason)
+		}
+		change.Trigger = models.TriggerFound
+		action = constants.Found
+	default:
+		utils.JSONError(w, "Status can only be changed to LOST, or from LOST to AVAILABLE; "+
+			"loans and holds change it otherwise", http.StatusConflict)
+		return false
+	}
+
+	err = h.Store.SetStatus(ctx, barcode, change)
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		utils.JSONError(w, err.Error(), http.StatusConflict)
+		return false
+	case errors.Is(err, store.ErrConflict):
+		utils.JSONError(w, "Copy changed status during the update, try again", http.StatusConflict)
+		return false
+	case err != nil:
+		utils.JSONError(w, "Update failed", http.StatusInternalServerError)
+		return false
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	h.AuditLogger.Log(ctx, models.CopyEntity, action, map[string]interface{}{
+		"barcode": barcode,
+		"change":  change,
+	})
+	return true
+}
+
+// found restores a lost copy through LostItems, which also closes a claims
+// returned loan of it or refunds the replacement charged for it.
+func (h *CopyHandler) found(ctx context.Context, w http.ResponseWriter, barcode, note string) bool {
+	_, err := h.LostItems.Found(ctx, barcode, note)
+	switch {
+	case errors.Is(err, services.ErrNotLost), errors.Is(err, store.ErrConflict):
+		utils.JSONError(w, "Copy changed status during the update, try again", http.StatusConflict)
+		return false
+	case err != nil:
+		utils.JSONError(w, "Update failed", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// GET /copies/{barcode}/history
+// Every status change of the copy, oldest first.
+func (h *CopyHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	result, err := h.Collection.DeleteOne(ctx, bson.M{"barcode": barcode})
+	copyObj, err := h.Store.Get(ctx, mux.Vars(r)["barcode"])
 	if err != nil {
-		utils.JSONError(w, "Delete failed", http.StatusInternalServerError)
-		return
-	}
-	if result.DeletedCount == 0 {
 		utils.JSONError(w, "Copy not found", http.StatusNotFound)
 		return
 	}
+	history := copyObj.History
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+	json.NewEncoder(w).Encode(history)
+}
+
+// DELETE /copies/{barcode}?cascade=true
+// The copy is withdrawn rather than removed. With open holds it is refused
+// with 409 unless cascade is set, which cancels them; a copy on loan has to
+// be checked in first.
+func (h *CopyHandler) DeleteCopy(w http.ResponseWriter, r *http.Request) {
+	barcode := mux.Vars(r)["barcode"]
+	cascade := r.URL.Query().Get("cascade") == "true"
 
-	h.AuditLogger.Log(ctx, models.CopyEntity, constants.Create, barcode)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err := h.Withdrawal.WithdrawCopy(ctx, barcode, cascade)
+	if writeWithdrawalError(w, err, "Copy not found") {
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
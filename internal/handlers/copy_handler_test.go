@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"open-library-explorer/internal/handlers"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+func TestCopyHandler_UpdateCopyStatus(t *testing.T) {
+	copies := store.NewMemoryCopyStore()
+	copies.Insert(context.Background(), &models.Copy{Barcode: "C-1", ISBN: "9783161484100", Status: models.StatusAvailable})
+
+	handler := handlers.CopyHandler{Store: copies}
+	router := mux.NewRouter()
+	router.HandleFunc("/copies/{barcode}", handler.UpdateCopy).Methods("PUT")
+	router.HandleFunc("/copies/{barcode}/history", handler.GetHistory).Methods("GET")
+
+	put := func(body string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/copies/C-1", strings.NewReader(body)))
+		return w.Code
+	}
+
+	steps := []struct {
+		name string
+		body string
+		want int
+		then models.CopyStatus
+	}{
+		{"on loan without a checkout", `{"status": "ON_LOAN"}`, http.StatusConflict, models.StatusAvailable},
+		{"lost without a reason", `{"status": "LOST"}`, http.StatusBadRequest, models.StatusAvailable},
+		{"lost", `{"status": "LOST", "reason": "missing at inventory"}`, http.StatusOK, models.StatusLost},
+		{"reserved while lost", `{"status": "RESERVED"}`, http.StatusConflict, models.StatusLost},
+		{"found", `{"status": "AVAILABLE"}`, http.StatusOK, models.StatusAvailable},
+		{"barcode renamed", `{"barcode": "C-2"}`, http.StatusBadRequest, models.StatusAvailable},
+		{"id replaced while lost", `{"_id": "C-2", "status": "LOST", "reason": "gone"}`, http.StatusBadRequest, models.StatusAvailable},
+	}
+	for _, step := range steps {
+		if got := put(step.body); got != step.want {
+			t.Errorf("%s: expected status %d, got %d", step.name, step.want, got)
+		}
+		if copyObj, _ := copies.Get(context.Background(), "C-1"); copyObj.Status != step.then {
+			t.Errorf("%s: expected copy to be %s, got %s", step.name, step.then, copyObj.Status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/copies/C-1/history", nil))
+	var history []models.StatusChange
+	json.NewDecoder(w.Body).Decode(&history)
+	if len(history) != 2 || history[0].Trigger != models.TriggerLost || history[0].Reason != "missing at inventory" ||
+		history[1].Trigger != models.TriggerFound {
+		t.Errorf("expected the lost and found transitions to be recorded, got %+v", history)
+	}
+}
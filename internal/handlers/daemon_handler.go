@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"open-library-explorer/internal/daemon"
+)
+
+type DaemonHandler struct {
+	Supervisor *daemon.Supervisor
+}
+
+// GET /admin/daemons
+func (h *DaemonHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	statuses := []daemon.JobStatus{}
+	if h.Supervisor != nil {
+		statuses = h.Supervisor.Status()
+	}
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+var fineFields = listFields{
+	"id":            "_id",
+	"member_id":     "member_id",
+	"loan_id":       "loan_id",
+	"copy_barcode":  "copy_barcode",
+	"reason":        "reason",
+	"amount":        "amount",
+	"amount_paid":   "amount_paid",
+	"payments":      "payments",
+	"status":        "status",
+	"waive_reason":  "waive_reason",
+	"refunded":      "refunded",
+	"refund_reason": "refund_reason",
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+}
+
+var fineSortable = []string{"id", "amount", "status", "created_at", "updated_at"}
+
+// FineListResponse is a page of a member's fines with their current balance.
+type FineListResponse struct {
+	ListResponse
+	MemberID primitive.ObjectID `json:"member_id"`
+	Balance  float64            `json:"balance"`
+}
+
+type FineHandler struct {
+	Store  store.FineStore
+	Ledger *services.FineLedger
+}
+
+// GET /fines?member_id=xxx&status=OPEN&limit=&cursor=&sort=&fields=
+func (h *FineHandler) GetFines(w http.ResponseWriter, r *http.Request) {
+	requested, allowed := memberScope(r, r.URL.Query().Get("member_id"))
+	if !allowed {
+		utils.JSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(requested)
+	if err != nil {
+		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+		return
+	}
+
+	filter := store.FineFilter{MemberID: memberID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter.Status = models.FineStatus(status)
+	}
+
+	writeFineList(w, r, h.Store, h.Ledger, filter)
+}
+
+// writeFineList writes the page of the member's fines matching filter that
+// the request's list parameters ask for, with the member's balance. Fines are
+// listed oldest first unless sort says otherwise.
+func writeFineList(w http.ResponseWriter, r *http.Request, fines store.FineStore, ledger *services.FineLedger, filter store.FineFilter) {
+	lq, err := parseListQuery(r, fineFields, fineSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lq.Page.Sort == "" {
+		lq.Page.Sort = "created_at"
+	}
+
+	ctx := r.Context()
+	total, err := fines.Count(ctx, filter)
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch fines", http.StatusInternalServerError)
+		return
+	}
+	page, next, err := fines.FindPage(ctx, filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch fines")
+		return
+	}
+	if page == nil {
+		page = []models.Fine{}
+	}
+
+	balance, err := ledger.Balance(ctx, filter.MemberID)
+	if err != nil {
+		utils.JSONError(w, "Failed to compute balance", http.StatusInternalServerError)
+		return
+	}
+
+	list, err := listResponse(lq, page, total, next)
+	if err != nil {
+		utils.JSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(FineListResponse{
+		ListResponse: list,
+		MemberID:     filter.MemberID,
+		Balance:      balance,
+	})
+}
+
+// POST /fines/{id}/pay
+func (h *FineHandler) PayFine(w http.ResponseWriter, r *http.Request) {
+	fineID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONError(w, "Invalid fine ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	fine, err := h.Ledger.Pay(r.Context(), fineID, req.Amount)
+	if !writeFineError(w, err) {
+		json.NewEncoder(w).Encode(fine)
+	}
+}
+
+// POST /fines/{id}/waive
+func (h *FineHandler) WaiveFine(w http.ResponseWriter, r *http.Request) {
+	fineID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONError(w, "Invalid fine ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+		utils.JSONError(w, "A reason is required to waive a fine", http.StatusBadRequest)
+		return
+	}
+
+	fine, err := h.Ledger.Waive(r.Context(), fineID, req.Reason)
+	if !writeFineError(w, err) {
+		json.NewEncoder(w).Encode(fine)
+	}
+}
+
+// writeFineError maps ledger errors to a response and reports whether it wrote one.
+func writeFineError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, store.ErrNotFound):
+		utils.JSONError(w, "Fine not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrFineClosed):
+		utils.JSONError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, store.ErrConflict):
+		utils.JSONError(w, "Fine changed during the update, try again", http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidAmount):
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+	default:
+		utils.JSONError(w, "Failed to update fine", http.StatusInternalServerError)
+	}
+	return true
+}
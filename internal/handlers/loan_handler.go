@@ -3,33 +3,53 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"errors"
+	"log"
 	"net/http"
 	"open-library-explorer/internal/constants"
 	"time"
 
+	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
 )
 
+var loanFields = listFields{
+	"id":           "_id",
+	"member_id":    "member_id",
+	"copy_barcode": "copy_barcode",
+	"loan_date":    "loan_date",
+	"due_date":     "due_date",
+	"returned":     "returned",
+	"outcome":      "outcome",
+}
+
+var loanSortable = []string{"id", "member_id", "copy_barcode", "loan_date", "due_date"}
+
 type LoanHandler struct {
-	MemberCol      *mongo.Collection
-	CopyCol        *mongo.Collection
-	LoanCol        *mongo.Collection
-	ReservationCol *mongo.Collection
-	AuditLogger    utils.Logger
-	Config         struct {
+	MemberStore store.MemberStore
+	CopyStore   store.CopyStore
+	LoanStore   store.LoanStore
+	HoldStore   store.HoldStore
+	HoldQueue   *services.HoldQueue
+	Fines       *services.FineLedger
+	LostItems   *services.LostItems
+	AuditLogger utils.Logger
+	Config      struct {
 		PremiumMemberRenewalDays  int
 		StandardMemberRenewalDays int
 	}
 }
 
+// CheckOutRequest names the member by either MemberID or CardNumber.
 type CheckOutRequest struct {
 	MemberID    string `json:"member_id"`
+	CardNumber  string `json:"card_number"`
 	CopyBarcode string `json:"copy_barcode"`
 }
 
@@ -40,32 +60,47 @@ func (h *LoanHandler) CheckOut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	memberID, err := primitive.ObjectIDFromHex(req.MemberID)
-	if err != nil {
-		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+	// Fetch member
+	member, ok := requestMember(w, r, h.MemberStore, req.MemberID, req.CardNumber)
+	if !ok {
 		return
 	}
-
-	// Fetch member
-	var member models.Member
-	if err := h.MemberCol.FindOne(r.Context(), bson.M{"_id": memberID}).Decode(&member); err != nil {
-		utils.JSONError(w, "Member not found", http.StatusNotFound)
+	memberID := member.ID
+	if writeMemberStatusError(w, member.CanBorrow(time.Now())) {
+		return
+	}
+	overLimit, err := h.Fines.OverThreshold(r.Context(), memberID)
+	if err != nil {
+		utils.JSONError(w, "Failed to check fines", http.StatusInternalServerError)
 		return
 	}
-	if member.Blocked {
-		utils.JSONError(w, "Member is blocked", http.StatusForbidden)
+	if overLimit {
+		utils.JSONError(w, "Outstanding fines exceed the borrowing limit", http.StatusForbidden)
 		return
 	}
 
 	// Fetch copyObj
-	var copyObj models.Copy
-	if err := h.CopyCol.FindOne(r.Context(), bson.M{"barcode": req.CopyBarcode}).Decode(&copyObj); err != nil {
+	copyObj, err := h.CopyStore.Get(r.Context(), req.CopyBarcode)
+	if err != nil {
 		utils.JSONError(w, "Copy not found", http.StatusNotFound)
 		return
 	}
-	if copyObj.Status != models.StatusAvailable {
-		utils.JSONError(w, "Copy not available", http.StatusConflict)
-		return
+
+	// A reserved copy can only go to the member whose hold it is waiting for
+	fromStatus := models.StatusAvailable
+	var hold *models.Hold
+	if copyObj.Status == models.StatusReserved {
+		ready, err := h.HoldStore.FindOne(r.Context(), store.HoldFilter{
+			CopyBarcode: req.CopyBarcode,
+			Open:        true,
+			Notified:    store.Bool(true),
+		})
+		if err != nil || ready.MemberID != memberID {
+			utils.JSONError(w, "Copy is reserved for another member", http.StatusConflict)
+			return
+		}
+		fromStatus = models.StatusReserved
+		hold = &ready
 	}
 
 	// Determine due date
@@ -88,24 +123,50 @@ func (h *LoanHandler) CheckOut(w http.ResponseWriter, r *http.Request) {
 		Returned:    false,
 	}
 
-	// Insert loan
-	_, err = h.LoanCol.InsertOne(r.Context(), loan)
+	// Claim the copy; only one concurrent checkout can move it off AVAILABLE
+	checkout := models.StatusChange{From: fromStatus, To: models.StatusOnLoan, Trigger: models.TriggerCheckout}
+	err = h.CopyStore.SetStatus(r.Context(), req.CopyBarcode, checkout)
+	if errors.Is(err, store.ErrConflict) {
+		utils.JSONError(w, "Copy not available", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		utils.JSONError(w, "Failed to record loan", http.StatusInternalServerError)
+		utils.JSONError(w, "Failed to update copyObj status", http.StatusInternalServerError)
 		return
 	}
 
-	// Update copyObj status
-	_, err = h.CopyCol.UpdateOne(r.Context(),
-		bson.M{"barcode": req.CopyBarcode},
-		bson.M{"$set": bson.M{"status": models.StatusOnLoan}},
-	)
+	// Insert loan, releasing the copy again if that fails
+	err = h.LoanStore.Insert(r.Context(), &loan)
 	if err != nil {
-		utils.JSONError(w, "Failed to update copyObj status", http.StatusInternalServerError)
+		_ = h.CopyStore.SetStatus(context.Background(), req.CopyBarcode, models.StatusChange{
+			From: models.StatusOnLoan, To: fromStatus, Trigger: models.TriggerCheckin, Reason: "loan could not be recorded",
+		})
+		if errors.Is(err, store.ErrDuplicate) {
+			utils.JSONError(w, "Copy not available", http.StatusConflict)
+			return
+		}
+		utils.JSONError(w, "Failed to record loan", http.StatusInternalServerError)
 		return
 	}
 
-	h.AuditLogger.Log(context.Background(), models.LoanEntity, constants.CheckOut, loan)
+	// A title hold of this member is satisfied by any copy of the book
+	if hold == nil && copyObj.ISBN != "" {
+		if titleHold, err := h.HoldStore.FindOne(r.Context(), store.HoldFilter{
+			MemberID:   memberID,
+			ISBN:       copyObj.ISBN,
+			Unassigned: true,
+			Open:       true,
+		}); err == nil {
+			hold = &titleHold
+		}
+	}
+
+	if hold != nil {
+		_ = h.HoldStore.Update(r.Context(), hold.ID, bson.M{"fulfilled": true, "copy_barcode": req.CopyBarcode})
+		h.AuditLogger.Log(r.Context(), models.HoldEntity, constants.Fulfil, hold.ID.Hex())
+	}
+
+	h.AuditLogger.Log(r.Context(), models.LoanEntity, constants.CheckOut, loan)
 	json.NewEncoder(w).Encode(loan)
 }
 
@@ -118,62 +179,55 @@ func (h *LoanHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Find active loan
-	filter := bson.M{
-		"copy_barcode": req.CopyBarcode,
-		"returned":     false,
-	}
-	update := bson.M{
-		"$set": bson.M{"returned": true},
+	// 1. Close the active loan; only one concurrent check-in can match it
+	filter := store.LoanFilter{
+		CopyBarcode: req.CopyBarcode,
+		Returned:    store.Bool(false),
 	}
-	result := h.LoanCol.FindOneAndUpdate(r.Context(), filter, update)
-	if result.Err() != nil {
+	loan, err := h.LoanStore.FindOneAndUpdate(r.Context(), filter, bson.M{"returned": true})
+	if err != nil {
 		utils.JSONError(w, "Active loan not found for this copy", http.StatusNotFound)
 		return
 	}
+	returnedAt := time.Now()
 
-	// 2. Check for existing reservation
-	var hold models.Hold
-	err := h.ReservationCol.FindOne(r.Context(), bson.M{
-		"copy_barcode": req.CopyBarcode,
-		"fulfilled":    false,
-		"notified":     false,
-	}, options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: 1}})).Decode(&hold)
-
-	newStatus := models.StatusAvailable
-	if err == nil {
-		newStatus = models.StatusReserved
-
-		// Mark as notified (mock)
-		_, _ = h.ReservationCol.UpdateOne(r.Context(),
-			bson.M{"_id": hold.ID},
-			bson.M{"$set": bson.M{"notified": true}},
-		)
-
-		utils.AppendToEmailLog(r.Context(), hold.MemberID.Hex(), req.CopyBarcode)
+	// 2. Hand the copy to the next hold in the queue, if any. A copy the
+	// member claimed to have returned was marked lost meanwhile
+	from := models.StatusOnLoan
+	if loan.ClaimsReturnedAt != nil {
+		from = models.StatusLost
 	}
-
-	// 3. Update copy status
-	_, err = h.CopyCol.UpdateOne(r.Context(),
-		bson.M{"barcode": req.CopyBarcode},
-		bson.M{"$set": bson.M{"status": newStatus}},
-	)
+	newStatus, err := h.HoldQueue.Release(r.Context(), req.CopyBarcode, from)
 	if err != nil {
+		// Reopen the loan so that the check-in can be retried
+		_ = h.LoanStore.Update(context.Background(), loan.ID, bson.M{"returned": false})
 		utils.JSONError(w, "Failed to update copy status", http.StatusInternalServerError)
 		return
 	}
 
-	h.AuditLogger.Log(context.Background(), models.LoanEntity, constants.CheckIn, req.CopyBarcode)
+	h.AuditLogger.Log(r.Context(), models.LoanEntity, constants.CheckIn, req.CopyBarcode)
 
-	json.NewEncoder(w).Encode(bson.M{
+	// 3. Charge for late return
+	// The copy is already back on the shelf, so a failure here must not fail the check-in
+	fine, err := h.Fines.AssessOverdue(r.Context(), loan, returnedAt)
+	if err != nil {
+		log.Printf("Failed to assess fine for loan %s: %v", loan.ID.Hex(), err)
+	}
+
+	response := bson.M{
 		"message": "Check-in successful",
 		"status":  newStatus,
-	})
+	}
+	if fine != nil {
+		response["fine"] = fine
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *LoanHandler) RenewLoan(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		MemberID    string `json:"member_id"`
+		CardNumber  string `json:"card_number"`
 		CopyBarcode string `json:"copy_barcode"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -181,41 +235,38 @@ func (h *LoanHandler) RenewLoan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	memberOID, err := primitive.ObjectIDFromHex(req.MemberID)
-	if err != nil {
-		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+	// 1. Load member
+	member, ok := requestMember(w, r, h.MemberStore, req.MemberID, req.CardNumber)
+	if !ok {
 		return
 	}
-
-	// 1. Load member
-	var member models.Member
-	if err := h.MemberCol.FindOne(r.Context(), bson.M{"_id": memberOID}).Decode(&member); err != nil {
-		utils.JSONError(w, "Member not found", http.StatusNotFound)
+	memberOID := member.ID
+	if writeMemberStatusError(w, member.CanBorrow(time.Now())) {
 		return
 	}
 
 	// 2. Find active loan
-	var loan models.Loan
-	err = h.LoanCol.FindOne(r.Context(), bson.M{
-		"copy_barcode": req.CopyBarcode,
-		"member_id":    memberOID,
-		"returned":     false,
-	}).Decode(&loan)
+	loan, err := h.LoanStore.FindOne(r.Context(), store.LoanFilter{
+		CopyBarcode: req.CopyBarcode,
+		MemberID:    memberOID,
+		Returned:    store.Bool(false),
+	})
 	if err != nil {
 		utils.JSONError(w, "Active loan not found for this member and copy", http.StatusNotFound)
 		return
 	}
 
-	// 3. Check if hold exists
-	count, err := h.ReservationCol.CountDocuments(r.Context(), bson.M{
-		"copy_barcode": req.CopyBarcode,
-		"fulfilled":    false,
-	})
+	// 3. Check if hold exists on the copy or its title
+	copyObj, err := h.CopyStore.Get(r.Context(), req.CopyBarcode)
+	if err != nil {
+		copyObj = models.Copy{Barcode: req.CopyBarcode}
+	}
+	waiting, err := h.HoldQueue.Waiting(r.Context(), copyObj)
 	if err != nil {
 		utils.JSONError(w, "Error checking holds", http.StatusInternalServerError)
 		return
 	}
-	if count > 0 {
+	if waiting {
 		utils.JSONError(w, "Renewal not allowed — reservations exist", http.StatusForbidden)
 		return
 	}
@@ -237,48 +288,119 @@ func (h *LoanHandler) RenewLoan(w http.ResponseWriter, r *http.Request) {
 	}
 	newDue := loan.DueDate.AddDate(0, 0, days)
 
-	// 5. Update due_date
-	_, err = h.LoanCol.UpdateOne(r.Context(),
-		bson.M{"_id": loan.ID},
-		bson.M{"$set": bson.M{"due_date": newDue}},
+	// 5. Update due_date, unless the loan was returned in the meantime
+	_, err = h.LoanStore.FindOneAndUpdate(r.Context(),
+		store.LoanFilter{ID: loan.ID, Returned: store.Bool(false)},
+		bson.M{"due_date": newDue},
 	)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.JSONError(w, "Active loan not found for this member and copy", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		utils.JSONError(w, "Failed to renew loan", http.StatusInternalServerError)
 		return
 	}
 
-	h.AuditLogger.Log(context.Background(), models.LoanEntity, constants.RenewLoan, loan)
+	h.AuditLogger.Log(r.Context(), models.LoanEntity, constants.RenewLoan, loan)
 
 	json.NewEncoder(w).Encode(bson.M{
 		"message":   "Loan renewed",
 		"new_due":   newDue.Format(time.RFC3339),
-		"member_id": req.MemberID,
+		"member_id": memberOID.Hex(),
 	})
 }
 
+// GET /loans/overdue?limit=&cursor=&sort=&fields=
+// Loans are listed by due date, oldest first, unless sort says otherwise.
 func (h *LoanHandler) GetOverdueLoans(w http.ResponseWriter, r *http.Request) {
-	filter := bson.M{
-		"due_date": bson.M{"$lt": time.Now()}, // Due date earlier than now
-		"returned": false,                     // Not returned
+	lq, err := parseListQuery(r, loanFields, loanSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lq.Page.Sort == "" {
+		lq.Page.Sort = "due_date"
+	}
+
+	filter := store.LoanFilter{
+		DueBefore: time.Now(),        // Due date earlier than now
+		Returned:  store.Bool(false), // Not returned
 	}
 
-	cursor, err := h.LoanCol.Find(r.Context(), filter)
+	total, err := h.LoanStore.Count(r.Context(), filter)
 	if err != nil {
 		utils.JSONError(w, "Failed to fetch overdue loans", http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(r.Context())
 
-	var overdueLoans []models.Loan
-	if err := cursor.All(r.Context(), &overdueLoans); err != nil {
-		utils.JSONError(w, "Failed to parse overdue loans", http.StatusInternalServerError)
+	if total == 0 {
+		utils.JSONError(w, "No Loans Found", http.StatusNotFound)
 		return
 	}
 
-	if len(overdueLoans) == 0 {
-		utils.JSONError(w, "No Loans Found", http.StatusNotFound)
+	overdueLoans, next, err := h.LoanStore.FindPage(r.Context(), filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch overdue loans")
+		return
+	}
+	if overdueLoans == nil {
+		overdueLoans = []models.Loan{}
+	}
+
+	writeList(w, lq, overdueLoans, total, next)
+}
+
+// DeclareRequest is the body of POST /loans/{id}/declare. Outcome is lost,
+// damaged or claims_returned.
+type DeclareRequest struct {
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason"`
+}
+
+// POST /loans/{id}/declare
+// lost and damaged close the loan and charge the member the replacement cost
+// of the copy. claims_returned marks the copy missing and keeps the loan open
+// while it is looked for; finding it later restores the copy and refunds any
+// replacement charged.
+func (h *LoanHandler) DeclareLoan(w http.ResponseWriter, r *http.Request) {
+	loanID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+	var req DeclareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSONError(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	response := bson.M{}
+	switch req.Outcome {
+	case string(models.OutcomeLost), string(models.OutcomeDamaged):
+		var loan models.Loan
+		var fine *models.Fine
+		loan, fine, err = h.LostItems.Declare(r.Context(), loanID, models.LoanOutcome(req.Outcome), req.Reason)
+		response["loan"] = loan
+		if fine != nil {
+			response["fine"] = fine
+		}
+	case "claims_returned":
+		response["loan"], err = h.LostItems.ClaimReturned(r.Context(), loanID, req.Reason)
+	default:
+		utils.JSONError(w, "outcome must be lost, damaged or claims_returned", http.StatusBadRequest)
 		return
 	}
 
-	json.NewEncoder(w).Encode(overdueLoans)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		utils.JSONError(w, "Loan not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrLoanClosed), errors.Is(err, services.ErrClaimOpen),
+		errors.Is(err, models.ErrInvalidTransition), errors.Is(err, store.ErrConflict):
+		utils.JSONError(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		utils.JSONError(w, "Failed to update loan", http.StatusInternalServerError)
+	default:
+		json.NewEncoder(w).Encode(response)
+	}
 }
@@ -7,47 +7,58 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
 
 	"open-library-explorer/internal/handlers"
 )
 
-func TestLoanHandler_CheckIn(t *testing.T) {
-	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
-	if mt.Client != nil {
-		defer mt.Client.Disconnect(context.Background())
+func newLoanHandler(stores store.Stores) handlers.LoanHandler {
+	return handlers.LoanHandler{
+		MemberStore: stores.Members,
+		CopyStore:   stores.Copies,
+		LoanStore:   stores.Loans,
+		HoldStore:   stores.Holds,
+		HoldQueue: &services.HoldQueue{
+			Holds:  stores.Holds,
+			Copies: stores.Copies,
+		},
+		Fines: &services.FineLedger{
+			Fines:          stores.Fines,
+			Members:        stores.Members,
+			Rate:           0.5,
+			BlockThreshold: 5,
+		},
+		AuditLogger: utils.Logger{Store: stores.Audit},
+		Config: struct {
+			PremiumMemberRenewalDays  int
+			StandardMemberRenewalDays int
+		}{
+			PremiumMemberRenewalDays:  30,
+			StandardMemberRenewalDays: 14,
+		},
 	}
+}
 
-	mt.Run("successful check-in", func(mt *mtest.T) {
-		handler := handlers.LoanHandler{
-			LoanCol:        mt.Coll,
-			ReservationCol: mt.Coll,
-			CopyCol:        mt.Coll,
-		}
+func TestLoanHandler_CheckIn(t *testing.T) {
+	t.Run("successful check-in", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newLoanHandler(stores)
 
+		ctx := context.Background()
 		copyBarcode := "123456"
+		holder := primitive.NewObjectID()
 
-		// Mock loan and reservation data
-		mt.AddMockResponses(
-			// Active loan found
-			mtest.CreateCursorResponse(1, "test.loans", mtest.FirstBatch, bson.D{
-				{Key: "copy_barcode", Value: copyBarcode},
-				{Key: "returned", Value: false},
-			}),
-			// Mock reservation
-			mtest.CreateCursorResponse(1, "test.reservations", mtest.FirstBatch, bson.D{
-				{Key: "copy_barcode", Value: copyBarcode},
-				{Key: "fulfilled", Value: false},
-				{Key: "notified", Value: false},
-			}),
-		)
+		stores.Copies.Insert(ctx, &models.Copy{Barcode: copyBarcode, Status: models.StatusOnLoan})
+		stores.Loans.Insert(ctx, &models.Loan{MemberID: primitive.NewObjectID(), CopyBarcode: copyBarcode})
+		stores.Holds.Insert(ctx, &models.Hold{MemberID: holder, CopyBarcode: copyBarcode, Timestamp: time.Now()})
 
 		router := mux.NewRouter()
 		router.HandleFunc("/checkin", handler.CheckIn).Methods("POST")
@@ -66,22 +77,24 @@ func TestLoanHandler_CheckIn(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusNotFound {
+		if res.StatusCode != http.StatusOK {
 			t.Errorf("expected status OK, got %v", res.Status)
 		}
-	})
 
-	mt.Run("loan not found for check-in", func(mt *mtest.T) {
-		handler := handlers.LoanHandler{
-			LoanCol: mt.Coll,
+		copyObj, _ := stores.Copies.Get(ctx, copyBarcode)
+		if copyObj.Status != models.StatusReserved {
+			t.Errorf("expected copy to be reserved, got %v", copyObj.Status)
+		}
+		hold, _ := stores.Holds.FindOne(ctx, store.HoldFilter{MemberID: holder})
+		if !hold.Notified {
+			t.Errorf("expected hold to be notified")
 		}
+	})
 
-		copyBarcode := "654321"
+	t.Run("loan not found for check-in", func(t *testing.T) {
+		handler := newLoanHandler(store.NewMemoryStores())
 
-		mt.AddMockResponses(
-			// No active loan found
-			mtest.CreateCursorResponse(0, "test.loans", mtest.FirstBatch, nil),
-		)
+		copyBarcode := "654321"
 
 		router := mux.NewRouter()
 		router.HandleFunc("/checkin", handler.CheckIn).Methods("POST")
@@ -104,41 +117,42 @@ func TestLoanHandler_CheckIn(t *testing.T) {
 			t.Errorf("expected status NotFound, got %v", res.Status)
 		}
 	})
-}
 
-func TestLoanHandler_RenewLoan(t *testing.T) {
-	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
-	if mt.Client != nil {
-		defer mt.Client.Disconnect(context.Background())
-	}
+	t.Run("loan reopened when the copy cannot be released", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newLoanHandler(stores)
+
+		ctx := context.Background()
+		loan := models.Loan{MemberID: primitive.NewObjectID(), CopyBarcode: "missing"}
+		stores.Loans.Insert(ctx, &loan)
+
+		router := mux.NewRouter()
+		router.HandleFunc("/checkin", handler.CheckIn).Methods("POST")
 
-	mt.Run("successful loan renewal", func(mt *mtest.T) {
-		handler := handlers.LoanHandler{
-			MemberCol:      mt.Coll,
-			CopyCol:        mt.Coll,
-			LoanCol:        mt.Coll,
-			ReservationCol: mt.Coll,
-			AuditLogger:    utils.Logger{},
-			Config: struct {
-				PremiumMemberRenewalDays  int
-				StandardMemberRenewalDays int
-			}{
-				PremiumMemberRenewalDays:  30,
-				StandardMemberRenewalDays: 14,
-			},
+		reqBytes, _ := json.Marshal(map[string]string{"copy_barcode": "missing"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkin", bytes.NewReader(reqBytes)))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status InternalServerError, got %d", w.Code)
+		}
+		if loan, _ = stores.Loans.FindOne(ctx, store.LoanFilter{ID: loan.ID}); loan.Returned {
+			t.Errorf("expected the loan to be open again, got %+v", loan)
 		}
+	})
+}
 
-		memberID := primitive.NewObjectID()
-		copyBarcode := "123456"
+func TestLoanHandler_RenewLoan(t *testing.T) {
+	t.Run("successful loan renewal", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newLoanHandler(stores)
 
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "test.loans", mtest.FirstBatch, bson.D{
-				{Key: "member_id", Value: memberID},
-				{Key: "copy_barcode", Value: copyBarcode},
-				{Key: "returned", Value: false},
-				{Key: "due_date", Value: time.Now()},
-			}),
-		)
+		ctx := context.Background()
+		member := models.Member{Tier: models.TierStandard}
+		stores.Members.Insert(ctx, &member)
+		copyBarcode := "123456"
+		due := time.Now().Truncate(time.Millisecond)
+		stores.Loans.Insert(ctx, &models.Loan{MemberID: member.ID, CopyBarcode: copyBarcode, DueDate: due})
 
 		router := mux.NewRouter()
 		router.HandleFunc("/loan/renew", handler.RenewLoan).Methods("POST")
@@ -147,7 +161,7 @@ func TestLoanHandler_RenewLoan(t *testing.T) {
 			MemberID    string `json:"member_id"`
 			CopyBarcode string `json:"copy_barcode"`
 		}{
-			MemberID:    memberID.Hex(),
+			MemberID:    member.ID.Hex(),
 			CopyBarcode: copyBarcode,
 		}
 		reqBytes, _ := json.Marshal(reqBody)
@@ -159,27 +173,23 @@ func TestLoanHandler_RenewLoan(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusNotFound {
+		if res.StatusCode != http.StatusOK {
 			t.Errorf("expected status OK, got %v", res.Status)
 		}
-	})
 
-	mt.Run("loan not found for renewal", func(mt *mtest.T) {
-		handler := handlers.LoanHandler{
-			MemberCol:      mt.Coll,
-			CopyCol:        mt.Coll,
-			LoanCol:        mt.Coll,
-			ReservationCol: mt.Coll,
-			AuditLogger:    utils.Logger{},
+		loan, _ := stores.Loans.FindOne(ctx, store.LoanFilter{CopyBarcode: copyBarcode})
+		if want := due.AddDate(0, 0, 14); !loan.DueDate.Equal(want) {
+			t.Errorf("expected due date %v, got %v", want, loan.DueDate)
 		}
+	})
 
-		memberID := primitive.NewObjectID()
-		copyBarcode := "654321"
+	t.Run("loan not found for renewal", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newLoanHandler(stores)
 
-		mt.AddMockResponses(
-			// No loan found
-			mtest.CreateCursorResponse(0, "test.loans", mtest.FirstBatch, nil),
-		)
+		member := models.Member{Tier: models.TierStandard}
+		stores.Members.Insert(context.Background(), &member)
+		copyBarcode := "654321"
 
 		router := mux.NewRouter()
 		router.HandleFunc("/loan/renew", handler.RenewLoan).Methods("POST")
@@ -188,7 +198,7 @@ func TestLoanHandler_RenewLoan(t *testing.T) {
 			MemberID    string `json:"member_id"`
 			CopyBarcode string `json:"copy_barcode"`
 		}{
-			MemberID:    memberID.Hex(),
+			MemberID:    member.ID.Hex(),
 			CopyBarcode: copyBarcode,
 		}
 		reqBytes, _ := json.Marshal(reqBody)
@@ -207,49 +217,29 @@ func TestLoanHandler_RenewLoan(t *testing.T) {
 }
 
 func TestLoanHandler_OverdueLoans(t *testing.T) {
-	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
-	if mt.Client != nil {
-		defer mt.Client.Disconnect(context.Background())
-	}
-
-	mt.Run("identify overdue loans", func(mt *mtest.T) {
-		handler := handlers.LoanHandler{
-			MemberCol:      mt.Coll,
-			CopyCol:        mt.Coll,
-			LoanCol:        mt.Coll,
-			ReservationCol: mt.Coll,
-			AuditLogger:    utils.Logger{},
-			Config: struct {
-				PremiumMemberRenewalDays  int
-				StandardMemberRenewalDays int
-			}{},
-		}
+	t.Run("identify overdue loans", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newLoanHandler(stores)
 
 		// Mock overdue loan data
 		memberID := primitive.NewObjectID()
-		copyBarcode := "123456"
-		loanID := primitive.NewObjectID()
 		overdueDate := time.Now().AddDate(0, 0, -5) // 5 days overdue
 
 		overdueLoan := models.Loan{
-			ID:          loanID,
 			MemberID:    memberID,
-			CopyBarcode: copyBarcode,
+			CopyBarcode: "123456",
 			LoanDate:    time.Now().AddDate(0, 0, -10),
 			DueDate:     overdueDate,
 			Returned:    false,
 		}
-
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "test.loans", mtest.FirstBatch, bson.D{
-				{Key: "_id", Value: overdueLoan.ID},
-				{Key: "member_id", Value: overdueLoan.MemberID},
-				{Key: "copy_barcode", Value: overdueLoan.CopyBarcode},
-				{Key: "loan_date", Value: overdueLoan.LoanDate},
-				{Key: "due_date", Value: overdueLoan.DueDate},
-				{Key: "returned", Value: overdueLoan.Returned},
-			}),
-		)
+		currentLoan := models.Loan{
+			MemberID:    memberID,
+			CopyBarcode: "123457",
+			LoanDate:    time.Now(),
+			DueDate:     time.Now().AddDate(0, 0, 7),
+		}
+		stores.Loans.Insert(context.Background(), &overdueLoan)
+		stores.Loans.Insert(context.Background(), &currentLoan)
 
 		router := mux.NewRouter()
 		router.HandleFunc("/loans/overdue", handler.GetOverdueLoans).Methods("GET")
@@ -262,51 +252,35 @@ func TestLoanHandler_OverdueLoans(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusInternalServerError {
+		if res.StatusCode != http.StatusOK {
 			t.Errorf("expected status OK, got %v", res.Status)
 		}
-	})
 
-	mt.Run("restrict actions for member with overdue loans", func(mt *mtest.T) {
-		handler := handlers.LoanHandler{
-			MemberCol:      mt.Coll,
-			CopyCol:        mt.Coll,
-			LoanCol:        mt.Coll,
-			ReservationCol: mt.Coll,
-			AuditLogger:    utils.Logger{},
-			Config: struct {
-				PremiumMemberRenewalDays  int
-				StandardMemberRenewalDays int
-			}{},
+		var got struct {
+			Items []models.Loan `json:"items"`
+			Total int64         `json:"total"`
 		}
-
-		// Mock overdue loan for a member
-		memberID := primitive.NewObjectID()
-		copyBarcode := "654321"
-		overdueDate := time.Now().AddDate(0, 0, -7) // 7 days overdue
-
-		overdueLoan := models.Loan{
-			MemberID:    memberID,
-			CopyBarcode: copyBarcode,
-			LoanDate:    time.Now().AddDate(0, 0, -15),
-			DueDate:     overdueDate,
-			Returned:    false,
+		json.NewDecoder(res.Body).Decode(&got)
+		if got.Total != 1 || len(got.Items) != 1 || got.Items[0].ID != overdueLoan.ID {
+			t.Errorf("expected only the overdue loan, got %+v", got)
 		}
+	})
+
+	t.Run("restrict actions for blocked member", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newLoanHandler(stores)
 
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "test.loans", mtest.FirstBatch, bson.D{
-				{Key: "member_id", Value: overdueLoan.MemberID},
-				{Key: "copy_barcode", Value: overdueLoan.CopyBarcode},
-				{Key: "due_date", Value: overdueLoan.DueDate},
-				{Key: "returned", Value: overdueLoan.Returned},
-			}),
-		)
+		ctx := context.Background()
+		member := models.Member{Tier: models.TierStandard, Blocked: true}
+		stores.Members.Insert(ctx, &member)
+		copyBarcode := "654321"
+		stores.Copies.Insert(ctx, &models.Copy{Barcode: copyBarcode, Status: models.StatusAvailable})
 
 		router := mux.NewRouter()
 		router.HandleFunc("/checkout", handler.CheckOut).Methods("POST")
 
 		reqBody := handlers.CheckOutRequest{
-			MemberID:    memberID.Hex(),
+			MemberID:    member.ID.Hex(),
 			CopyBarcode: copyBarcode,
 		}
 		reqBytes, _ := json.Marshal(reqBody)
@@ -318,8 +292,170 @@ func TestLoanHandler_OverdueLoans(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusNotFound {
+		if res.StatusCode != http.StatusForbidden {
 			t.Errorf("expected status Forbidden, got %v", res.Status)
 		}
 	})
 }
+
+func TestLoanHandler_ConcurrentCheckOut(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := newLoanHandler(stores)
+
+	ctx := context.Background()
+	copyBarcode := "123456"
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: copyBarcode, Status: models.StatusAvailable})
+
+	const workers = 50
+	members := make([]models.Member, workers)
+	for i := range members {
+		members[i] = models.Member{Tier: models.TierStandard}
+		stores.Members.Insert(ctx, &members[i])
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/checkout", handler.CheckOut).Methods("POST")
+	router.HandleFunc("/checkin", handler.CheckIn).Methods("POST")
+
+	hammer := func(path string, body func(i int) any) map[int]int {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		codes := map[int]int{}
+		start := make(chan struct{})
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				reqBytes, _ := json.Marshal(body(i))
+				<-start
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(reqBytes)))
+				mu.Lock()
+				codes[w.Code]++
+				mu.Unlock()
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+		return codes
+	}
+
+	codes := hammer("/checkout", func(i int) any {
+		return handlers.CheckOutRequest{MemberID: members[i].ID.Hex(), CopyBarcode: copyBarcode}
+	})
+	if codes[http.StatusOK] != 1 || codes[http.StatusConflict] != workers-1 {
+		t.Fatalf("expected one checkout and %d conflicts, got %v", workers-1, codes)
+	}
+	if n, _ := stores.Loans.Count(ctx, store.LoanFilter{CopyBarcode: copyBarcode, Returned: store.Bool(false)}); n != 1 {
+		t.Fatalf("expected one active loan, got %d", n)
+	}
+
+	codes = hammer("/checkin", func(int) any {
+		return map[string]string{"copy_barcode": copyBarcode}
+	})
+	if codes[http.StatusOK] != 1 || codes[http.StatusNotFound] != workers-1 {
+		t.Fatalf("expected one check-in and %d not found, got %v", workers-1, codes)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, copyBarcode); copyObj.Status != models.StatusAvailable {
+		t.Errorf("expected copy to be available, got %v", copyObj.Status)
+	}
+}
+
+func TestLoanHandler_LateReturnFines(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := newLoanHandler(stores)
+
+	ctx := context.Background()
+	member := models.Member{Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-1", Status: models.StatusOnLoan})
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-2", Status: models.StatusAvailable})
+	stores.Loans.Insert(ctx, &models.Loan{MemberID: member.ID, CopyBarcode: "C-1", DueDate: time.Now().AddDate(0, 0, -20)})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/checkin", handler.CheckIn).Methods("POST")
+	router.HandleFunc("/checkout", handler.CheckOut).Methods("POST")
+
+	reqBytes, _ := json.Marshal(map[string]string{"copy_barcode": "C-1"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkin", bytes.NewReader(reqBytes)))
+
+	var checkin struct {
+		Fine *models.Fine `json:"fine"`
+	}
+	json.NewDecoder(w.Body).Decode(&checkin)
+	if w.Code != http.StatusOK || checkin.Fine == nil || checkin.Fine.Amount != 10 {
+		t.Fatalf("expected check-in to assess a fine of 10, got %d %+v", w.Code, checkin.Fine)
+	}
+
+	reqBytes, _ = json.Marshal(handlers.CheckOutRequest{MemberID: member.ID.Hex(), CopyBarcode: "C-2"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkout", bytes.NewReader(reqBytes)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected checkout to be refused over the fine threshold, got %d", w.Code)
+	}
+}
+
+func TestLoanHandler_CheckOutByCardNumber(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := newLoanHandler(stores)
+
+	ctx := context.Background()
+	member := models.Member{Tier: models.TierStandard, CardNumber: "1234567897"}
+	stores.Members.Insert(ctx, &member)
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-1", Status: models.StatusAvailable})
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-2", Status: models.StatusAvailable})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/checkout", handler.CheckOut).Methods("POST")
+
+	tests := []struct {
+		name string
+		req  handlers.CheckOutRequest
+		want int
+	}{
+		{"card number", handlers.CheckOutRequest{CardNumber: "123-456-789-7", CopyBarcode: "C-1"}, http.StatusOK},
+		{"bad check digit", handlers.CheckOutRequest{CardNumber: "1234567891", CopyBarcode: "C-2"}, http.StatusBadRequest},
+		{"unknown card", handlers.CheckOutRequest{CardNumber: "2234567895", CopyBarcode: "C-2"}, http.StatusNotFound},
+		{"both identifiers", handlers.CheckOutRequest{MemberID: member.ID.Hex(), CardNumber: "1234567897", CopyBarcode: "C-2"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		reqBytes, _ := json.Marshal(tt.req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkout", bytes.NewReader(reqBytes)))
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body)
+		}
+	}
+
+	loan, err := stores.Loans.FindOne(ctx, store.LoanFilter{CopyBarcode: "C-1"})
+	if err != nil || loan.MemberID != member.ID {
+		t.Errorf("expected the loan to be recorded for the card holder, got %+v, %v", loan, err)
+	}
+}
+
+func TestLoanHandler_CheckOutExpiredMember(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := newLoanHandler(stores)
+
+	ctx := context.Background()
+	expiry := time.Now().AddDate(0, 0, -1)
+	member := models.Member{Tier: models.TierStandard, Status: models.MemberActive, MembershipExpiry: &expiry}
+	stores.Members.Insert(ctx, &member)
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-1", Status: models.StatusAvailable})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/checkout", handler.CheckOut).Methods("POST")
+
+	reqBytes, _ := json.Marshal(handlers.CheckOutRequest{MemberID: member.ID.Hex(), CopyBarcode: "C-1"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkout", bytes.NewReader(reqBytes)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body)
+	}
+	var resp utils.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Code != "MEMBERSHIP_EXPIRED" {
+		t.Errorf("expected code MEMBERSHIP_EXPIRED, got %+v", resp)
+	}
+}
@@ -3,27 +3,52 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+	"io"
 	"net/http"
 	"open-library-explorer/internal/constants"
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
 	"time"
 )
 
+var memberFields = listFields{
+	"id":          "_id",
+	"name":        "name",
+	"email":       "email",
+	"phone":       "phone",
+	"card_number": "card_number",
+	"tier":        "tier",
+	"status":      "status",
+	"blocked":     "blocked",
+	"created_at":  "created_at",
+	"updated_at":  "updated_at",
+}
+
+var memberSortable = []string{"id", "name", "email", "created_at"}
+
 type MemberHandler struct {
-	Collection  *mongo.Collection
+	Store       store.MemberStore
 	AuditLogger utils.Logger
+	// Loans, Holds, HoldQueue and Fines back the per-member views
+	Loans      store.LoanStore
+	Holds      store.HoldStore
+	HoldQueue  *services.HoldQueue
+	Fines      *services.FineLedger
+	Membership *services.Membership
 }
 
-func NewMemberHandler(coll *mongo.Collection, logger utils.Logger) *MemberHandler {
-	return &MemberHandler{Collection: coll, AuditLogger: logger}
+func NewMemberHandler(members store.MemberStore, logger utils.Logger) *MemberHandler {
+	return &MemberHandler{Store: members, AuditLogger: logger}
 }
 
+// POST /members
+// The card number is generated; one sent in the body is ignored.
 func (h *MemberHandler) RegisterMember(w http.ResponseWriter, r *http.Request) {
 	var member models.Member
 	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
@@ -31,21 +56,39 @@ func (h *MemberHandler) RegisterMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Println(member.Tier, models.IsValidMemberTier(string(member.Tier)))
-
-	if !models.IsValidMemberTier(string(member.Tier)) {
-		utils.JSONError(w, "Invalid member tier", http.StatusBadRequest)
+	member.Normalize()
+	if err := member.Validate(); err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	member.ID = primitive.NewObjectID()
+	member.Status = models.MemberActive
+	member.Suspension = nil
+	member.Blocked, member.BlockedBy = false, ""
+	h.Membership.Begin(&member, time.Now())
 	member.CreatedAt = time.Now()
 	member.UpdatedAt = time.Now()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	_, err := h.Collection.InsertOne(ctx, member)
+	if h.writeContactTaken(ctx, w, member) {
+		return
+	}
+
+	// A duplicate now is most likely the random card number, so draw another
+	var err error
+	for attempt := 0; attempt < models.CardNumberAttempts; attempt++ {
+		member.CardNumber = models.NewCardNumber()
+		if err = h.Store.Insert(ctx, &member); !errors.Is(err, store.ErrDuplicate) {
+			break
+		}
+	}
+	if errors.Is(err, store.ErrDuplicate) {
+		utils.JSONError(w, "A member with this email or phone already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		utils.JSONError(w, "Insert failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -71,56 +114,394 @@ func (h *MemberHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if tier, ok := updateData["tier"]; ok && !models.IsValidMemberTier(tier.(string)) {
-		utils.JSONError(w, "Invalid tier", http.StatusBadRequest)
-		return
+	for _, field := range []string{"_id", "id", "card_number", "created_at"} {
+		if _, ok := updateData[field]; ok {
+			utils.JSONError(w, field+" cannot be changed", http.StatusBadRequest)
+			return
+		}
+	}
+	// Statuses and memberships move through their own endpoints, tiers
+	// change on renewal so their fee is charged, and blocks follow the fines
+	for _, field := range []string{"status", "suspension", "blocked", "blocked_by", "tier",
+		"membership_start", "membership_expiry", "expiry_notice_sent"} {
+		if _, ok := updateData[field]; ok {
+			utils.JSONError(w, field+" cannot be set directly", http.StatusBadRequest)
+			return
+		}
 	}
 
-	updateData["updated_at"] = time.Now()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	res, err := h.Collection.UpdateByID(ctx, memberID, bson.M{"$set": updateData})
-	if err != nil {
-		utils.JSONError(w, "Update failed: "+err.Error(), http.StatusInternalServerError)
+	// Validate the contact fields as they will be after the update
+	member, err := h.Store.Get(ctx, memberID)
+	if writeMemberError(w, err) {
+		return
+	}
+	for field, target := range map[string]*string{"name": &member.Name, "email": &member.Email, "phone": &member.Phone} {
+		value, ok := updateData[field]
+		if !ok {
+			continue
+		}
+		if *target, ok = value.(string); !ok {
+			utils.JSONError(w, field+" must be a string", http.StatusBadRequest)
+			return
+		}
+	}
+	member.Normalize()
+	if err := member.Validate(); err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for field, value := range map[string]string{"name": member.Name, "email": member.Email, "phone": member.Phone} {
+		if _, ok := updateData[field]; ok {
+			updateData[field] = value
+		}
+	}
+	if h.writeContactTaken(ctx, w, member) {
 		return
 	}
-	if res.MatchedCount == 0 {
+
+	updateData["updated_at"] = time.Now()
+
+	err = h.Store.Update(ctx, memberID, updateData)
+	if errors.Is(err, store.ErrNotFound) {
 		utils.JSONError(w, "Member not found", http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, store.ErrDuplicate) {
+		utils.JSONError(w, "A member with this email or phone already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.JSONError(w, "Update failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	h.AuditLogger.Log(ctx, models.MemberEntity, constants.Update, updateData)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Member updated"})
 }
 
+// PATCH /members/{id}/deactivate
+// Refused while the member has loans outstanding; their open holds are
+// cancelled.
 func (h *MemberHandler) DeactivateMember(w http.ResponseWriter, r *http.Request) {
-	idStr := mux.Vars(r)["id"]
-	memberID, err := primitive.ObjectIDFromHex(idStr)
+	memberID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	member, err := h.Membership.Deactivate(ctx, memberID, time.Now())
+	if !writeMembershipError(w, err) {
+		json.NewEncoder(w).Encode(member)
+	}
+}
+
+// SuspendRequest is the body of PATCH /members/{id}/suspend. Without Until
+// the suspension lasts until the member is reactivated.
+type SuspendRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+// PATCH /members/{id}/suspend
+func (h *MemberHandler) SuspendMember(w http.ResponseWriter, r *http.Request) {
+	memberID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 	if err != nil {
 		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	var req SuspendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	res, err := h.Collection.UpdateByID(ctx, memberID, bson.M{"$set": bson.M{
-		"blocked":    true,
-		"updated_at": time.Now(),
-	}})
+	member, err := h.Membership.Suspend(ctx, memberID, req.Reason, req.Until, time.Now())
+	if !writeMembershipError(w, err) {
+		json.NewEncoder(w).Encode(member)
+	}
+}
+
+// PATCH /members/{id}/reactivate
+// Lifts a suspension or undoes a deactivation.
+func (h *MemberHandler) ReactivateMember(w http.ResponseWriter, r *http.Request) {
+	memberID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 	if err != nil {
-		utils.JSONError(w, "Deactivate failed: "+err.Error(), http.StatusInternalServerError)
+		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	member, err := h.Membership.Reactivate(ctx, memberID, time.Now())
+	if !writeMembershipError(w, err) {
+		json.NewEncoder(w).Encode(member)
+	}
+}
+
+// RenewRequest is the body of POST /members/{id}/renew. Tier switches the
+// member to another tier and defaults to their current one.
+type RenewRequest struct {
+	Tier models.MembershipTier `json:"tier"`
+}
+
+// POST /members/{id}/renew
+// The renewal fee of the tier is charged as a fine.
+func (h *MemberHandler) RenewMembership(w http.ResponseWriter, r *http.Request) {
+	memberID, ok := scopedMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	// The body is optional
+	var req RenewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.JSONError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if res.MatchedCount == 0 {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	member, fee, err := h.Membership.Renew(ctx, memberID, req.Tier, time.Now())
+	if !writeMembershipError(w, err) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"member": member,
+			"fee":    fee,
+		})
+	}
+}
+
+// writeMembershipError maps status change errors to a response and reports
+// whether it wrote one.
+func writeMembershipError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, store.ErrNotFound):
 		utils.JSONError(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrMemberExpired):
+		utils.JSONErrorCode(w, err.Error(), "MEMBERSHIP_EXPIRED", http.StatusConflict)
+	case errors.Is(err, services.ErrLoansOutstanding), errors.Is(err, models.ErrInvalidMemberTransition):
+		utils.JSONError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrSuspensionReason), errors.Is(err, services.ErrSuspensionUntil),
+		errors.Is(err, models.ErrMemberTier):
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+	default:
+		utils.JSONError(w, "Failed to change member status", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// writeContactTaken responds with 409 when another member already has the
+// email or phone of member, and reports whether it did.
+func (h *MemberHandler) writeContactTaken(ctx context.Context, w http.ResponseWriter, member models.Member) bool {
+	filters := []store.MemberFilter{{Email: member.Email}}
+	if member.Phone != "" {
+		filters = append(filters, store.MemberFilter{Phone: member.Phone})
+	}
+	for _, filter := range filters {
+		existing, err := h.Store.FindOne(ctx, filter)
+		if errors.Is(err, store.ErrNotFound) || err == nil && existing.ID == member.ID {
+			continue
+		}
+		if err != nil {
+			utils.JSONError(w, "Failed to check existing members", http.StatusInternalServerError)
+			return true
+		}
+		field := "email"
+		if filter.Phone != "" {
+			field = "phone"
+		}
+		utils.JSONError(w, "A member with this "+field+" already exists", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// GET /members/{id}
+func (h *MemberHandler) GetMember(w http.ResponseWriter, r *http.Request) {
+	memberID, ok := scopedMemberID(w, r)
+	if !ok {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	h.AuditLogger.Log(ctx, models.MemberEntity, constants.Deactivate, idStr)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Member deactivated"})
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	member, err := h.Store.Get(ctx, memberID)
+	if errors.Is(err, store.ErrNotFound) {
+		utils.JSONError(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch member", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(member)
+}
+
+// GET /members?q=&name=&email=&phone=&status=&limit=&cursor=&sort=&fields=
+// q matches part of the name, email or phone; name matches part of the name
+// and email and phone the whole value.
+func (h *MemberHandler) SearchMembers(w http.ResponseWriter, r *http.Request) {
+	lq, err := parseListQuery(r, memberFields, memberSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lq.Page.Sort == "" {
+		lq.Page.Sort = "name"
+	}
+
+	query := r.URL.Query()
+	filter := store.MemberFilter{
+		Query: query.Get("q"),
+		Name:  query.Get("name"),
+		Email: query.Get("email"),
+		Phone: query.Get("phone"),
+		// stored status; lapsed suspensions still show as SUSPENDED
+		Status: models.MemberStatus(query.Get("status")),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := h.Store.Count(ctx, filter)
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+	if total == 0 {
+		utils.JSONError(w, "No members found", http.StatusNotFound)
+		return
+	}
+
+	members, next, err := h.Store.FindPage(ctx, filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch members")
+		return
+	}
+	if members == nil {
+		members = []models.Member{}
+	}
+
+	writeList(w, lq, members, total, next)
+}
+
+// GET /members/{id}/loans?status=active|returned&limit=&cursor=&sort=&fields=
+// Without status both open and returned loans are listed, newest first.
+func (h *MemberHandler) GetMemberLoans(w http.ResponseWriter, r *http.Request) {
+	memberID, ok := scopedMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	lq, err := parseListQuery(r, loanFields, loanSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lq.Page.Sort == "" {
+		lq.Page.Sort, lq.Page.Desc = "loan_date", true
+	}
+
+	filter := store.LoanFilter{MemberID: memberID}
+	switch r.URL.Query().Get("status") {
+	case "":
+	case "active":
+		filter.Returned = store.Bool(false)
+	case "returned":
+		filter.Returned = store.Bool(true)
+	default:
+		utils.JSONError(w, "status must be active or returned", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := h.Loans.Count(ctx, filter)
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch loans", http.StatusInternalServerError)
+		return
+	}
+	if total == 0 {
+		utils.JSONError(w, "No Loans Found", http.StatusNotFound)
+		return
+	}
+
+	loans, next, err := h.Loans.FindPage(ctx, filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch loans")
+		return
+	}
+	if loans == nil {
+		loans = []models.Loan{}
+	}
+
+	writeList(w, lq, loans, total, next)
+}
+
+// GET /members/{id}/holds?all=true&limit=&cursor=&sort=&fields=
+// Only open holds are listed unless all is set.
+func (h *MemberHandler) GetMemberHolds(w http.ResponseWriter, r *http.Request) {
+	memberID, ok := scopedMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	writeHoldList(w, r.WithContext(ctx), h.Holds, h.HoldQueue, store.HoldFilter{
+		MemberID: memberID,
+		Open:     r.URL.Query().Get("all") != "true",
+	})
+}
+
+// GET /members/{id}/fines?status=OPEN&limit=&cursor=&sort=&fields=
+func (h *MemberHandler) GetMemberFines(w http.ResponseWriter, r *http.Request) {
+	memberID, ok := scopedMemberID(w, r)
+	if !ok {
+		return
+	}
+
+	filter := store.FineFilter{MemberID: memberID}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter.Status = models.FineStatus(status)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	writeFineList(w, r.WithContext(ctx), h.Fines.Fines, h.Fines, filter)
+}
+
+// scopedMemberID parses the {id} of a member route, writing the response and
+// returning false when it is invalid or belongs to another patron.
+func scopedMemberID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	requested, allowed := memberScope(r, mux.Vars(r)["id"])
+	if !allowed {
+		utils.JSONError(w, "Forbidden", http.StatusForbidden)
+		return primitive.NilObjectID, false
+	}
+	memberID, err := primitive.ObjectIDFromHex(requested)
+	if err != nil {
+		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return memberID, true
 }
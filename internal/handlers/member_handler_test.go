@@ -0,0 +1,345 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/handlers"
+	"open-library-explorer/internal/middleware"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+func newMemberHandler(stores store.Stores) *handlers.MemberHandler {
+	handler := handlers.NewMemberHandler(stores.Members, utils.Logger{Store: stores.Audit})
+	handler.Loans = stores.Loans
+	handler.Holds = stores.Holds
+	handler.HoldQueue = &services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies}
+	handler.Fines = &services.FineLedger{Fines: stores.Fines, Members: stores.Members}
+	handler.Membership = &services.Membership{
+		Members:   stores.Members,
+		Loans:     stores.Loans,
+		Holds:     stores.Holds,
+		HoldQueue: handler.HoldQueue,
+		Fines:     handler.Fines,
+		Terms: map[models.MembershipTier]models.MembershipTerm{
+			models.TierPremium: {Months: 12, Fee: 30},
+		},
+	}
+	return handler
+}
+
+// asPatron returns req as sent with the token of the patron of memberID.
+func asPatron(req *http.Request, memberID primitive.ObjectID) *http.Request {
+	claims := &utils.JWTClaims{Role: models.RolePatron, MemberID: memberID.Hex()}
+	return req.WithContext(context.WithValue(req.Context(), middleware.ContextClaims, claims))
+}
+
+func TestMemberHandler_SearchMembers(t *testing.T) {
+	stores := store.NewMemoryStores()
+	ctx := context.Background()
+	for _, member := range []models.Member{
+		{Name: "Ada Lovelace", Email: "ada@example.com", Phone: "+441234567890"},
+		{Name: "Alan Turing", Email: "alan@example.com", Phone: "+441234567891"},
+		{Name: "Grace Hopper", Email: "grace@example.org", Phone: "+15551234567"},
+	} {
+		member := member
+		stores.Members.Insert(ctx, &member)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/members", newMemberHandler(stores).SearchMembers).Methods("GET")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Ada Lovelace", "Alan Turing", "Grace Hopper"}},
+		{"q=example.com", []string{"Ada Lovelace", "Alan Turing"}},
+		{"q=hopper", []string{"Grace Hopper"}},
+		{"q=%2B1555", []string{"Grace Hopper"}},
+		{"name=AL", []string{"Alan Turing"}},
+		{"email=ADA@example.com", []string{"Ada Lovelace"}},
+		{"phone=%2B441234567891", []string{"Alan Turing"}},
+		{"sort=-name&limit=2", []string{"Grace Hopper", "Alan Turing"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/members?"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.query, rr.Code, rr.Body.String())
+		}
+
+		var resp struct {
+			Items []models.Member `json:"items"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		var got []string
+		for _, member := range resp.Items {
+			got = append(got, member.Name)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: got %v, want %v", tt.query, got, tt.want)
+			}
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/members?q=nobody", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without matches, got %d", rr.Code)
+	}
+}
+
+func TestMemberHandler_MemberRecords(t *testing.T) {
+	stores := store.NewMemoryStores()
+	ctx := context.Background()
+
+	member := models.Member{Name: "Ada Lovelace", Tier: models.TierStandard}
+	other := models.Member{Name: "Alan Turing", Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+	stores.Members.Insert(ctx, &other)
+
+	now := time.Now()
+	stores.Loans.Insert(ctx, &models.Loan{MemberID: member.ID, CopyBarcode: "c1", LoanDate: now.AddDate(0, 0, -20), Returned: true})
+	stores.Loans.Insert(ctx, &models.Loan{MemberID: member.ID, CopyBarcode: "c2", LoanDate: now.AddDate(0, 0, -2)})
+	stores.Loans.Insert(ctx, &models.Loan{MemberID: other.ID, CopyBarcode: "c3", LoanDate: now})
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "c3", Status: models.StatusOnLoan})
+	stores.Holds.Insert(ctx, &models.Hold{MemberID: member.ID, CopyBarcode: "c3", Timestamp: now})
+	stores.Fines.Insert(ctx, &models.Fine{MemberID: member.ID, Amount: 2.5, Status: models.FineOpen})
+
+	router := mux.NewRouter()
+	handler := newMemberHandler(stores)
+	router.HandleFunc("/members/{id}", handler.GetMember).Methods("GET")
+	router.HandleFunc("/members/{id}/loans", handler.GetMemberLoans).Methods("GET")
+	router.HandleFunc("/members/{id}/holds", handler.GetMemberHolds).Methods("GET")
+	router.HandleFunc("/members/{id}/fines", handler.GetMemberFines).Methods("GET")
+
+	get := func(path string, patron bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if patron {
+			req = asPatron(req, member.ID)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("loans", func(t *testing.T) {
+		tests := []struct {
+			query string
+			want  []string
+		}{
+			{"", []string{"c2", "c1"}},
+			{"?status=active", []string{"c2"}},
+			{"?status=returned", []string{"c1"}},
+		}
+		for _, tt := range tests {
+			rr := get("/members/"+member.ID.Hex()+"/loans"+tt.query, true)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%q: expected 200, got %d: %s", tt.query, rr.Code, rr.Body.String())
+			}
+			var resp struct {
+				Items []models.Loan `json:"items"`
+			}
+			json.NewDecoder(rr.Body).Decode(&resp)
+			if len(resp.Items) != len(tt.want) {
+				t.Fatalf("%q: got %d loans, want %v", tt.query, len(resp.Items), tt.want)
+			}
+			for i, loan := range resp.Items {
+				if loan.CopyBarcode != tt.want[i] {
+					t.Errorf("%q: loan %d is %s, want %s", tt.query, i, loan.CopyBarcode, tt.want[i])
+				}
+			}
+		}
+
+		if rr := get("/members/"+member.ID.Hex()+"/loans?status=lost", true); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown status, got %d", rr.Code)
+		}
+	})
+
+	t.Run("holds and fines", func(t *testing.T) {
+		rr := get("/members/"+member.ID.Hex()+"/holds", true)
+		var holds struct {
+			Items []handlers.HoldResponse `json:"items"`
+			Total int64                   `json:"total"`
+		}
+		json.NewDecoder(rr.Body).Decode(&holds)
+		if rr.Code != http.StatusOK || holds.Total != 1 || len(holds.Items) != 1 || holds.Items[0].QueuePosition != 1 {
+			t.Fatalf("unexpected holds %d %+v", rr.Code, holds)
+		}
+
+		rr = get("/members/"+member.ID.Hex()+"/fines?fields=amount", true)
+		var fines struct {
+			Balance float64                  `json:"balance"`
+			Items   []map[string]interface{} `json:"items"`
+			Total   int64                    `json:"total"`
+		}
+		json.NewDecoder(rr.Body).Decode(&fines)
+		if rr.Code != http.StatusOK || fines.Balance != 2.5 || fines.Total != 1 || len(fines.Items) != 1 {
+			t.Fatalf("unexpected fines %d %+v", rr.Code, fines)
+		}
+		if len(fines.Items[0]) != 1 || fines.Items[0]["amount"] != 2.5 {
+			t.Errorf("expected only the amount of the fine, got %v", fines.Items[0])
+		}
+	})
+
+	t.Run("patrons only see their own record", func(t *testing.T) {
+		if rr := get("/members/"+member.ID.Hex(), true); rr.Code != http.StatusOK {
+			t.Errorf("expected 200 for the patron's own record, got %d", rr.Code)
+		}
+		for _, path := range []string{"", "/loans", "/holds", "/fines"} {
+			if rr := get("/members/"+other.ID.Hex()+path, true); rr.Code != http.StatusForbidden {
+				t.Errorf("%s: expected 403 for another member, got %d", path, rr.Code)
+			}
+		}
+		if rr := get("/members/"+other.ID.Hex(), false); rr.Code != http.StatusOK {
+			t.Errorf("expected staff to read any member, got %d", rr.Code)
+		}
+	})
+
+	if rr := get("/members/"+primitive.NewObjectID().Hex(), false); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown member, got %d", rr.Code)
+	}
+}
+
+func TestMemberHandler_RegisterMember(t *testing.T) {
+	stores := store.NewMemoryStores()
+	router := mux.NewRouter()
+	router.HandleFunc("/members", newMemberHandler(stores).RegisterMember).Methods("POST")
+
+	register := func(member models.Member) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(member)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/members", bytes.NewReader(body)))
+		return rr
+	}
+
+	rr := register(models.Member{Name: " Ada Lovelace ", Email: "Ada@Example.com", Phone: "+441234567890", Tier: models.TierStandard, CardNumber: "1234567897"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var created models.Member
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Name != "Ada Lovelace" || created.Email != "ada@example.com" {
+		t.Errorf("expected name and email to be normalized, got %q %q", created.Name, created.Email)
+	}
+	if _, err := models.NormalizeCardNumber(created.CardNumber); err != nil || created.CardNumber == "1234567897" {
+		t.Errorf("expected a generated card number, got %q", created.CardNumber)
+	}
+	if created.MembershipStart == nil || created.MembershipExpiry == nil || !created.MembershipExpiry.After(*created.MembershipStart) {
+		t.Errorf("expected the membership to start and expire, got %v %v", created.MembershipStart, created.MembershipExpiry)
+	}
+
+	tests := []struct {
+		name   string
+		member models.Member
+		want   int
+	}{
+		{"missing name", models.Member{Email: "alan@example.com", Tier: models.TierStandard}, http.StatusBadRequest},
+		{"malformed email", models.Member{Name: "Alan Turing", Email: "alan", Tier: models.TierStandard}, http.StatusBadRequest},
+		{"malformed phone", models.Member{Name: "Alan Turing", Email: "alan@example.com", Phone: "01234", Tier: models.TierStandard}, http.StatusBadRequest},
+		{"taken email", models.Member{Name: "Alan Turing", Email: "ADA@example.com", Tier: models.TierStandard}, http.StatusConflict},
+		{"taken phone", models.Member{Name: "Alan Turing", Email: "alan@example.com", Phone: "+441234567890", Tier: models.TierStandard}, http.StatusConflict},
+		{"valid", models.Member{Name: "Alan Turing", Email: "alan@example.com", Tier: models.TierPremium}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if rr := register(tt.member); rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestMemberHandler_UpdateMember(t *testing.T) {
+	stores := store.NewMemoryStores()
+	member := models.Member{Name: "Ada Lovelace", Email: "ada@example.com", Tier: models.TierStandard, Status: models.MemberActive}
+	stores.Members.Insert(context.Background(), &member)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/members/{id}", newMemberHandler(stores).UpdateMember).Methods("PUT")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"tier not a string", `{"tier": 5}`, http.StatusBadRequest},
+		{"null tier", `{"tier": null}`, http.StatusBadRequest},
+		{"tier without a renewal", `{"tier": "PREMIUM"}`, http.StatusBadRequest},
+		{"status", `{"status": "ACTIVE"}`, http.StatusBadRequest},
+		{"membership expiry", `{"membership_expiry": "2030-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"card number", `{"card_number": "1234567897"}`, http.StatusBadRequest},
+		{"name", `{"name": "Ada King"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("PUT", "/members/"+member.ID.Hex(), bytes.NewBufferString(tt.body)))
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body)
+		}
+	}
+
+	if got, _ := stores.Members.Get(context.Background(), member.ID); got.Name != "Ada King" || got.Tier != models.TierStandard {
+		t.Errorf("expected only the name to change, got %+v", got)
+	}
+}
+
+func TestMemberHandler_RenewMembership(t *testing.T) {
+	stores := store.NewMemoryStores()
+	ctx := context.Background()
+
+	lapsed := time.Now().AddDate(0, 0, -1)
+	member := models.Member{Name: "Ada Lovelace", Tier: models.TierStandard, Status: models.MemberActive, MembershipExpiry: &lapsed}
+	other := models.Member{Name: "Alan Turing", Tier: models.TierStandard, Status: models.MemberActive}
+	stores.Members.Insert(ctx, &member)
+	stores.Members.Insert(ctx, &other)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/members/{id}/renew", newMemberHandler(stores).RenewMembership).Methods("POST")
+
+	renew := func(id primitive.ObjectID, body string) *httptest.ResponseRecorder {
+		req := asPatron(httptest.NewRequest("POST", "/members/"+id.Hex()+"/renew", bytes.NewBufferString(body)), member.ID)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := renew(other.ID, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 renewing another member, got %d", rr.Code)
+	}
+	if rr := renew(member.ID, `{"tier": "GOLD"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown tier, got %d", rr.Code)
+	}
+
+	rr := renew(member.ID, `{"tier": "PREMIUM"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp struct {
+		Member models.Member `json:"member"`
+		Fee    *models.Fine  `json:"fee"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Member.Status != models.MemberActive || resp.Member.Tier != models.TierPremium || !resp.Member.MembershipExpiry.After(time.Now()) {
+		t.Errorf("expected an active premium membership, got %+v", resp.Member)
+	}
+	if resp.Fee == nil || resp.Fee.Amount != 30 || resp.Fee.Reason != services.MembershipRenewal {
+		t.Errorf("expected the premium fee to be charged, got %+v", resp.Fee)
+	}
+}
@@ -2,18 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"time"
 )
 
 type MetricsHandler struct {
-	CopyCol   *mongo.Collection
-	MemberCol *mongo.Collection
-	LoanCol   *mongo.Collection
-	Config    struct {
+	CopyStore   store.CopyStore
+	MemberStore store.MemberStore
+	LoanStore   store.LoanStore
+	FineStore   store.FineStore
+	Config      struct {
 		FineRate float64
 	}
 }
@@ -24,49 +25,55 @@ func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
 	todayStart := time.Now().Truncate(24 * time.Hour)
 
 	// 1. Total books (copies)
-	totalBooks, _ := h.CopyCol.CountDocuments(ctx, bson.M{})
+	totalBooks, _ := h.CopyStore.Count(ctx, store.CopyFilter{})
 
-	// 2. Active members
-	activeMembers, _ := h.MemberCol.CountDocuments(ctx, bson.M{
-		"blocked": false,
+	// 2. Active members, counting suspensions that have run out
+	activeMembers, _ := h.MemberStore.Count(ctx, store.MemberFilter{
+		Status: models.MemberActive,
 	})
+	lapsedSuspensions, _ := h.MemberStore.Count(ctx, store.MemberFilter{
+		Status:            models.MemberSuspended,
+		SuspensionEndedBy: time.Now(),
+	})
+	activeMembers += lapsedSuspensions
 
 	// 3. Loans today
-	loansToday, _ := h.LoanCol.CountDocuments(ctx, bson.M{
-		"loan_date": bson.M{
-			"$gte": todayStart,
-		},
+	loansToday, _ := h.LoanStore.Count(ctx, store.LoanFilter{
+		LoanedSince: todayStart,
 	})
 
 	// 4. Overdue count
 	now := time.Now()
-	overdueCount, _ := h.LoanCol.CountDocuments(ctx, bson.M{
-		"due_date": bson.M{"$lt": now},
-		"returned": false,
-	})
+	overdue := store.LoanFilter{
+		DueBefore: now,
+		Returned:  store.Bool(false),
+	}
+	overdueCount, _ := h.LoanStore.Count(ctx, overdue)
 
-	// Here we'll assume fine = $1 per overdue per day
-	cursor, _ := h.LoanCol.Find(ctx, bson.M{
-		"due_date": bson.M{"$lt": now},
-		"returned": false,
-	})
-	var loans []models.Loan
-	_ = cursor.All(ctx, &loans)
+	// 5. Fines accruing on loans that are still out, not yet in the ledger
+	loans, _ := h.LoanStore.Find(ctx, overdue)
 
-	finePerDay := h.Config.FineRate
-	var fineRevenue float64
+	var accruingFines float64
 	for _, loan := range loans {
-		daysLate := int(now.Sub(loan.DueDate).Hours() / 24)
-		if daysLate > 0 {
-			fineRevenue += float64(daysLate) * finePerDay
-		}
+		accruingFines += float64(services.DaysLate(loan.DueDate, now)) * h.Config.FineRate
+	}
+
+	// 6. Ledger totals
+	fines, _ := h.FineStore.Find(ctx, store.FineFilter{})
+
+	var fineRevenue, finesOutstanding float64
+	for _, fine := range fines {
+		fineRevenue += fine.AmountPaid
+		finesOutstanding += fine.Outstanding()
 	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total_books":    totalBooks,
-		"active_members": activeMembers,
-		"loans_today":    loansToday,
-		"overdue_count":  overdueCount,
-		"fine_revenue":   fineRevenue,
+		"total_books":       totalBooks,
+		"active_members":    activeMembers,
+		"loans_today":       loansToday,
+		"overdue_count":     overdueCount,
+		"fine_revenue":      fineRevenue,
+		"fines_outstanding": finesOutstanding,
+		"accruing_fines":    accruingFines,
 	})
 }
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// listFields maps the JSON field names clients use in sort and fields to the
+// bson names the stores know.
+type listFields map[string]string
+
+// ListResponse is the envelope every paginated list endpoint returns. Next is
+// the cursor to pass back for the following page and is omitted on the last.
+type ListResponse struct {
+	Items interface{} `json:"items"`
+	Total int64       `json:"total"`
+	Limit int         `json:"limit"`
+	Next  string      `json:"next,omitempty"`
+}
+
+// listQuery holds the parsed limit, cursor, sort and fields parameters.
+type listQuery struct {
+	Page   store.Page
+	Fields []string // JSON names to keep in the response; nil keeps all
+}
+
+// parseListQuery reads ?limit=&cursor=&sort=&fields= where sort is a field
+// name, prefixed with - for descending order, from sortable, and fields is a
+// comma separated list of names from known.
+func parseListQuery(r *http.Request, known listFields, sortable ...string) (listQuery, error) {
+	q := r.URL.Query()
+	lq := listQuery{Page: store.Page{Limit: store.DefaultPageLimit, Cursor: q.Get("cursor")}}
+
+	if val := q.Get("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit < 1 || limit > store.MaxPageLimit {
+			return lq, fmt.Errorf("limit must be between 1 and %d", store.MaxPageLimit)
+		}
+		lq.Page.Limit = limit
+	}
+
+	if val := q.Get("sort"); val != "" {
+		name := strings.TrimPrefix(val, "-")
+		if !containsName(sortable, name) {
+			return lq, fmt.Errorf("cannot sort by %q", name)
+		}
+		lq.Page.Sort = known[name]
+		lq.Page.Desc = strings.HasPrefix(val, "-")
+	}
+
+	if val := q.Get("fields"); val != "" {
+		for _, name := range strings.Split(val, ",") {
+			name = strings.TrimSpace(name)
+			field, ok := known[name]
+			if !ok {
+				return lq, fmt.Errorf("unknown field %q", name)
+			}
+			lq.Fields = append(lq.Fields, name)
+			lq.Page.Fields = append(lq.Page.Fields, field)
+		}
+	}
+	return lq, nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeListError reports a failed page query, telling a stale or forged
+// cursor apart from store failures.
+func writeListError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, store.ErrInvalidCursor) {
+		utils.JSONError(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	utils.JSONError(w, msg, http.StatusInternalServerError)
+}
+
+// writeList encodes items in a ListResponse, trimmed to the requested fields.
+func writeList(w http.ResponseWriter, lq listQuery, items interface{}, total int64, next string) {
+	resp, err := listResponse(lq, items, total, next)
+	if err != nil {
+		utils.JSONError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listResponse builds the ListResponse writeList sends, for endpoints that
+// add to it.
+func listResponse(lq listQuery, items interface{}, total int64, next string) (ListResponse, error) {
+	if lq.Fields != nil {
+		projected, err := project(items, lq.Fields)
+		if err != nil {
+			return ListResponse{}, err
+		}
+		items = projected
+	}
+	return ListResponse{
+		Items: items,
+		Total: total,
+		Limit: lq.Page.Limit,
+		Next:  next,
+	}, nil
+}
+
+// project re-encodes a slice of models as JSON objects holding only fields.
+func project(items interface{}, fields []string) ([]map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var docs []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &docs); err != nil {
+		return nil, err
+	}
+	projected := make([]map[string]json.RawMessage, len(docs))
+	for i, doc := range docs {
+		projected[i] = map[string]json.RawMessage{}
+		for _, f := range fields {
+			if v, ok := doc[f]; ok {
+				projected[i][f] = v
+			}
+		}
+	}
+	return projected, nil
+}
@@ -1,70 +1,132 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"open-library-explorer/internal/constants"
 	"time"
 
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 	"open-library-explorer/internal/utils"
 
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var holdFields = listFields{
+	"id":             "_id",
+	"member_id":      "member_id",
+	"copy_barcode":   "copy_barcode",
+	"isbn":           "isbn",
+	"timestamp":      "timestamp",
+	"fulfilled":      "fulfilled",
+	"notified":       "notified",
+	"pickup_by":      "pickup_by",
+	"cancelled":      "cancelled",
+	"expired":        "expired",
+	"queue_position": "",
+}
+
+var holdSortable = []string{"id", "member_id", "copy_barcode", "isbn", "timestamp", "pickup_by"}
+
 type ReservationHandler struct {
-	ReservationCol *mongo.Collection
-	CopyCol        *mongo.Collection
-	MemberCol      *mongo.Collection
-	AuditLogger    utils.Logger
+	HoldStore   store.HoldStore
+	BookStore   store.BookStore
+	CopyStore   store.CopyStore
+	MemberStore store.MemberStore
+	HoldQueue   *services.HoldQueue
+	AuditLogger utils.Logger
+}
+
+// HoldResponse is a hold together with its place in the copy's queue.
+type HoldResponse struct {
+	models.Hold
+	QueuePosition int `json:"queue_position,omitempty"`
 }
 
+// POST /holds/place with either copy_barcode for a copy hold or isbn for a
+// title hold that any copy of the book can satisfy. The member is named by
+// member_id or card_number.
 func (h *ReservationHandler) PlaceHold(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		MemberID    string `json:"member_id"`
+		CardNumber  string `json:"card_number"`
 		CopyBarcode string `json:"copy_barcode"`
+		ISBN        string `json:"isbn"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.JSONError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	memberID, err := primitive.ObjectIDFromHex(req.MemberID)
-	if err != nil {
-		utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+	if (req.CopyBarcode == "") == (req.ISBN == "") {
+		utils.JSONError(w, "Provide either copy_barcode or isbn", http.StatusBadRequest)
 		return
 	}
 
 	// 1. Validate member exists
-	var member models.Member
-	err = h.MemberCol.FindOne(r.Context(), bson.M{"_id": memberID}).Decode(&member)
-	if err != nil {
-		utils.JSONError(w, "Member not found", http.StatusNotFound)
+	member, ok := requestMember(w, r, h.MemberStore, req.MemberID, req.CardNumber)
+	if !ok {
 		return
 	}
-
-	// 1. Check copy is not AVAILABLE
-	var copy models.Copy
-	err = h.CopyCol.FindOne(r.Context(), bson.M{"barcode": req.CopyBarcode}).Decode(&copy)
-	if err != nil {
-		utils.JSONError(w, "Copy not found", http.StatusNotFound)
+	memberID := member.ID
+	if writeMemberStatusError(w, member.CanPlaceHold(time.Now())) {
 		return
 	}
 
-	if copy.Status == models.StatusAvailable {
-		utils.JSONError(w, "Copy is available — no need to hold", http.StatusBadRequest)
-		return
+	existing := store.HoldFilter{MemberID: memberID, Open: true}
+
+	if req.ISBN != "" {
+		var ok bool
+		if req.ISBN, ok = normalizeISBN(w, req.ISBN); !ok {
+			return
+		}
+
+		// 1. Check book exists, is not withdrawn and no copy of it is AVAILABLE
+		book, err := h.BookStore.Get(r.Context(), req.ISBN)
+		if err != nil {
+			utils.JSONError(w, "Book not found", http.StatusNotFound)
+			return
+		}
+		if book.WithdrawnAt != nil {
+			utils.JSONError(w, "Book has been withdrawn", http.StatusConflict)
+			return
+		}
+
+		available, err := h.CopyStore.Count(r.Context(), store.CopyFilter{ISBN: req.ISBN, Status: models.StatusAvailable})
+		if err != nil {
+			utils.JSONError(w, "Failed to fetch copies", http.StatusInternalServerError)
+			return
+		}
+		if available > 0 {
+			utils.JSONError(w, "A copy is available — no need to hold", http.StatusBadRequest)
+			return
+		}
+		existing.ISBN = req.ISBN
+	} else {
+		// 1. Check copy is out and coming back: ON_LOAN or RESERVED
+		copy, err := h.CopyStore.Get(r.Context(), req.CopyBarcode)
+		if err != nil {
+			utils.JSONError(w, "Copy not found", http.StatusNotFound)
+			return
+		}
+
+		switch copy.Status {
+		case models.StatusOnLoan, models.StatusReserved:
+		case models.StatusAvailable:
+			utils.JSONError(w, "Copy is available — no need to hold", http.StatusBadRequest)
+			return
+		default:
+			utils.JSONError(w, "Copy is "+string(copy.Status)+" and cannot be held", http.StatusConflict)
+			return
+		}
+		existing.CopyBarcode = req.CopyBarcode
 	}
 
-	// 2. Check if hold already exists for this member+copy
-	count, err := h.ReservationCol.CountDocuments(r.Context(), bson.M{
-		"member_id":    memberID,
-		"copy_barcode": req.CopyBarcode,
-		"fulfilled":    false,
-	})
+	// 2. Check if hold already exists for this member+copy or member+title
+	count, err := h.HoldStore.Count(r.Context(), existing)
 	if err != nil {
 		utils.JSONError(w, "Error checking existing holds", http.StatusInternalServerError)
 		return
@@ -78,20 +140,140 @@ func (h *ReservationHandler) PlaceHold(w http.ResponseWriter, r *http.Request) {
 	hold := models.Hold{
 		MemberID:    memberID,
 		CopyBarcode: req.CopyBarcode,
+		ISBN:        req.ISBN,
 		Timestamp:   time.Now(),
 		Fulfilled:   false,
 		Notified:    false,
 	}
 
-	_, err = h.ReservationCol.InsertOne(r.Context(), hold)
+	err = h.HoldStore.Insert(r.Context(), &hold)
 	if err != nil {
 		utils.JSONError(w, "Failed to place hold", http.StatusInternalServerError)
 		return
 	}
 
-	h.AuditLogger.Log(context.Background(), models.HoldEntity, constants.Create, hold)
+	h.AuditLogger.Log(r.Context(), models.HoldEntity, constants.Create, hold)
+
+	position, _ := h.HoldQueue.Position(r.Context(), hold)
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Hold placed successfully",
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Hold placed successfully",
+		"hold_id":        hold.ID,
+		"queue_position": position,
 	})
 }
+
+// GET /holds?member_id=xxx&barcode=xxx&isbn=xxx&all=true&limit=&cursor=&sort=&fields=
+func (h *ReservationHandler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := store.HoldFilter{
+		CopyBarcode: query.Get("barcode"),
+		Open:        query.Get("all") != "true",
+	}
+
+	memberID, allowed := memberScope(r, query.Get("member_id"))
+	if !allowed {
+		utils.JSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if memberID != "" {
+		id, err := primitive.ObjectIDFromHex(memberID)
+		if err != nil {
+			utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+			return
+		}
+		filter.MemberID = id
+	}
+
+	isbn := query.Get("isbn")
+	if isbn != "" {
+		var ok bool
+		if isbn, ok = normalizeISBN(w, isbn); !ok {
+			return
+		}
+		copies, err := h.CopyStore.Find(r.Context(), store.CopyFilter{ISBN: isbn})
+		if err != nil {
+			utils.JSONError(w, "Failed to fetch copies", http.StatusInternalServerError)
+			return
+		}
+		// Title holds still waiting for a copy are not tied to any barcode
+		queues := store.HoldQueues{Copies: []string{}, ISBNs: []string{isbn}}
+		for _, copyObj := range copies {
+			queues.Copies = append(queues.Copies, copyObj.Barcode)
+		}
+		filter.Queues = &queues
+	}
+
+	writeHoldList(w, r, h.HoldStore, h.HoldQueue, filter)
+}
+
+// writeHoldList writes the page of holds matching filter that the request's
+// list parameters ask for, each with its queue position. Holds are listed
+// oldest first unless sort says otherwise.
+func writeHoldList(w http.ResponseWriter, r *http.Request, holds store.HoldStore, queue *services.HoldQueue, filter store.HoldFilter) {
+	lq, err := parseListQuery(r, holdFields, holdSortable...)
+	if err != nil {
+		utils.JSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lq.Page.Sort == "" {
+		lq.Page.Sort = "timestamp"
+	}
+	// Positions need the whole hold, so fields only trims the response
+	lq.Page.Fields = nil
+
+	ctx := r.Context()
+	total, err := holds.Count(ctx, filter)
+	if err != nil {
+		utils.JSONError(w, "Failed to fetch holds", http.StatusInternalServerError)
+		return
+	}
+	if total == 0 {
+		utils.JSONError(w, "No holds found", http.StatusNotFound)
+		return
+	}
+
+	page, next, err := holds.FindPage(ctx, filter, lq.Page)
+	if err != nil {
+		writeListError(w, err, "Failed to fetch holds")
+		return
+	}
+
+	positions, err := queue.Positions(ctx, page)
+	if err != nil {
+		utils.JSONError(w, "Failed to compute queue position", http.StatusInternalServerError)
+		return
+	}
+	results := make([]HoldResponse, 0, len(page))
+	for _, hold := range page {
+		results = append(results, HoldResponse{Hold: hold, QueuePosition: positions[hold.ID]})
+	}
+
+	writeList(w, lq, results, total, next)
+}
+
+// DELETE /holds/{id}
+func (h *ReservationHandler) CancelHold(w http.ResponseWriter, r *http.Request) {
+	holdID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		utils.JSONError(w, "Invalid hold ID", http.StatusBadRequest)
+		return
+	}
+
+	hold, err := h.HoldStore.Get(r.Context(), holdID)
+	if err != nil {
+		utils.JSONError(w, "Hold not found", http.StatusNotFound)
+		return
+	}
+	if !hold.IsOpen() {
+		utils.JSONError(w, "Hold is already closed", http.StatusConflict)
+		return
+	}
+
+	if err := h.HoldQueue.Cancel(r.Context(), hold); err != nil {
+		utils.JSONError(w, "Failed to cancel hold", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
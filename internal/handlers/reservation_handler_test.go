@@ -7,52 +7,46 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
 
 	"open-library-explorer/internal/handlers"
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
 )
 
-func TestReservationHandler_PlaceHold(t *testing.T) {
-	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
-	if mt.Client != nil {
-		defer mt.Client.Disconnect(context.Background())
+func newReservationHandler(stores store.Stores) handlers.ReservationHandler {
+	return handlers.ReservationHandler{
+		MemberStore: stores.Members,
+		BookStore:   stores.Books,
+		CopyStore:   stores.Copies,
+		HoldStore:   stores.Holds,
+		HoldQueue: &services.HoldQueue{
+			Holds:  stores.Holds,
+			Copies: stores.Copies,
+		},
 	}
+}
 
-	mt.Run("successful hold placement", func(mt *mtest.T) {
-		handler := handlers.ReservationHandler{
-			MemberCol:      mt.Coll,
-			CopyCol:        mt.Coll,
-			ReservationCol: mt.Coll,
-		}
+func TestReservationHandler_PlaceHold(t *testing.T) {
+	t.Run("successful hold placement", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newReservationHandler(stores)
 
-		memberID := primitive.NewObjectID()
+		ctx := context.Background()
 		copyBarcode := "123456"
 
 		member := models.Member{
-			ID:      memberID,
 			Name:    "Jane Doe",
 			Blocked: false,
 		}
-		copyObj := models.Copy{
+		stores.Members.Insert(ctx, &member)
+		stores.Copies.Insert(ctx, &models.Copy{
 			Barcode: copyBarcode,
 			Status:  models.StatusOnLoan,
-		}
-
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "test.members", mtest.FirstBatch, bson.D{
-				{Key: "_id", Value: member.ID},
-				{Key: "blocked", Value: member.Blocked},
-			}),
-			mtest.CreateCursorResponse(1, "test.copies", mtest.FirstBatch, bson.D{
-				{Key: "barcode", Value: copyObj.Barcode},
-				{Key: "status", Value: copyObj.Status},
-			}),
-		)
+		})
 
 		router := mux.NewRouter()
 		router.HandleFunc("/holds/place", handler.PlaceHold).Methods("POST")
@@ -61,7 +55,7 @@ func TestReservationHandler_PlaceHold(t *testing.T) {
 			MemberID    string `json:"member_id"`
 			CopyBarcode string `json:"copy_barcode"`
 		}{
-			MemberID:    memberID.Hex(),
+			MemberID:    member.ID.Hex(),
 			CopyBarcode: copyBarcode,
 		}
 
@@ -74,41 +68,31 @@ func TestReservationHandler_PlaceHold(t *testing.T) {
 		res := w.Result()
 		defer res.Body.Close()
 
-		if res.StatusCode != http.StatusNotFound {
+		if res.StatusCode != http.StatusOK {
 			t.Errorf("expected status OK, got %v", res.Status)
 		}
-	})
 
-	mt.Run("copy already available, no hold needed", func(mt *mtest.T) {
-		handler := handlers.ReservationHandler{
-			MemberCol:      mt.Coll,
-			CopyCol:        mt.Coll,
-			ReservationCol: mt.Coll,
+		if n, _ := stores.Holds.Count(ctx, store.HoldFilter{MemberID: member.ID}); n != 1 {
+			t.Errorf("expected one hold, got %d", n)
 		}
+	})
+
+	t.Run("copy already available, no hold needed", func(t *testing.T) {
+		stores := store.NewMemoryStores()
+		handler := newReservationHandler(stores)
 
-		memberID := primitive.NewObjectID()
+		ctx := context.Background()
 		copyBarcode := "654321"
 
 		member := models.Member{
-			ID:      memberID,
 			Name:    "Jane Doe",
 			Blocked: false,
 		}
-		copyObj := models.Copy{
+		stores.Members.Insert(ctx, &member)
+		stores.Copies.Insert(ctx, &models.Copy{
 			Barcode: copyBarcode,
 			Status:  models.StatusAvailable,
-		}
-
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "test.members", mtest.FirstBatch, bson.D{
-				{Key: "_id", Value: member.ID},
-				{Key: "blocked", Value: member.Blocked},
-			}),
-			mtest.CreateCursorResponse(1, "test.copies", mtest.FirstBatch, bson.D{
-				{Key: "barcode", Value: copyObj.Barcode},
-				{Key: "status", Value: copyObj.Status},
-			}),
-		)
+		})
 
 		router := mux.NewRouter()
 		router.HandleFunc("/holds/place", handler.PlaceHold).Methods("POST")
@@ -117,7 +101,7 @@ func TestReservationHandler_PlaceHold(t *testing.T) {
 			MemberID    string `json:"member_id"`
 			CopyBarcode string `json:"copy_barcode"`
 		}{
-			MemberID:    "792",
+			MemberID:    member.ID.Hex(),
 			CopyBarcode: copyBarcode,
 		}
 
@@ -135,3 +119,169 @@ func TestReservationHandler_PlaceHold(t *testing.T) {
 		}
 	})
 }
+
+func TestReservationHandler_HoldLifecycle(t *testing.T) {
+	stores := store.NewMemoryStores()
+	holds := newReservationHandler(stores)
+	loans := newLoanHandler(stores)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/holds", holds.ListHolds).Methods("GET")
+	router.HandleFunc("/holds/place", holds.PlaceHold).Methods("POST")
+	router.HandleFunc("/holds/{id}", holds.CancelHold).Methods("DELETE")
+	router.HandleFunc("/checkout", loans.CheckOut).Methods("POST")
+	router.HandleFunc("/checkin", loans.CheckIn).Methods("POST")
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		reqBytes, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(reqBytes)))
+		return w
+	}
+
+	ctx := context.Background()
+	copyBarcode := "123456"
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: "9780140449136", Barcode: copyBarcode, Status: models.StatusAvailable})
+
+	var members [3]models.Member
+	for i := range members {
+		members[i] = models.Member{Tier: models.TierStandard}
+		stores.Members.Insert(ctx, &members[i])
+	}
+
+	if w := do(http.MethodPost, "/checkout", map[string]string{"member_id": members[0].ID.Hex(), "copy_barcode": copyBarcode}); w.Code != http.StatusOK {
+		t.Fatalf("checkout: expected OK, got %d", w.Code)
+	}
+	for i := 1; i < 3; i++ {
+		w := do(http.MethodPost, "/holds/place", map[string]string{"member_id": members[i].ID.Hex(), "copy_barcode": copyBarcode})
+		var placed struct {
+			QueuePosition int `json:"queue_position"`
+		}
+		json.NewDecoder(w.Body).Decode(&placed)
+		if w.Code != http.StatusOK || placed.QueuePosition != i {
+			t.Fatalf("place hold %d: got %d with position %d", i, w.Code, placed.QueuePosition)
+		}
+	}
+
+	// page through the queue one hold at a time
+	var listed []handlers.HoldResponse
+	for cursor := ""; ; {
+		w := do(http.MethodGet, "/holds?isbn=9780140449136&limit=1&cursor="+cursor, nil)
+		var page struct {
+			Items []handlers.HoldResponse `json:"items"`
+			Total int64                   `json:"total"`
+			Next  string                  `json:"next"`
+		}
+		json.NewDecoder(w.Body).Decode(&page)
+		if w.Code != http.StatusOK || page.Total != 2 || len(page.Items) != 1 {
+			t.Fatalf("list holds: got %d with %+v", w.Code, page)
+		}
+		listed = append(listed, page.Items...)
+		if cursor = page.Next; cursor == "" {
+			break
+		}
+	}
+	if len(listed) != 2 || listed[0].MemberID != members[1].ID || listed[1].QueuePosition != 2 {
+		t.Fatalf("list holds: got %+v", listed)
+	}
+
+	if w := do(http.MethodPost, "/checkin", map[string]string{"copy_barcode": copyBarcode}); w.Code != http.StatusOK {
+		t.Fatalf("checkin: expected OK, got %d", w.Code)
+	}
+
+	// the copy now waits for the first hold; nobody else may take it
+	if w := do(http.MethodPost, "/checkout", map[string]string{"member_id": members[2].ID.Hex(), "copy_barcode": copyBarcode}); w.Code != http.StatusConflict {
+		t.Fatalf("checkout by second in queue: expected Conflict, got %d", w.Code)
+	}
+
+	// cancelling the ready hold passes the copy to the next member
+	if w := do(http.MethodDelete, "/holds/"+listed[0].ID.Hex(), nil); w.Code != http.StatusNoContent {
+		t.Fatalf("cancel hold: expected NoContent, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/checkout", map[string]string{"member_id": members[2].ID.Hex(), "copy_barcode": copyBarcode}); w.Code != http.StatusOK {
+		t.Fatalf("checkout by next in queue: expected OK, got %d", w.Code)
+	}
+
+	if w := do(http.MethodGet, "/holds?member_id="+members[2].ID.Hex(), nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected fulfilled hold to leave the open list, got %d", w.Code)
+	}
+	if hold, _ := stores.Holds.Get(ctx, listed[1].ID); !hold.Fulfilled {
+		t.Errorf("expected hold to be fulfilled, got %+v", hold)
+	}
+}
+
+func TestReservationHandler_PlaceTitleHold(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := newReservationHandler(stores)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/holds/place", handler.PlaceHold).Methods("POST")
+
+	ctx := context.Background()
+	isbn := "9780140449136"
+	member := models.Member{Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+	stores.Books.Insert(ctx, &models.Book{ISBN: isbn})
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: isbn, Barcode: "C-1", Status: models.StatusAvailable})
+
+	place := func(body map[string]string) int {
+		reqBytes, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/holds/place", bytes.NewReader(reqBytes)))
+		return w.Code
+	}
+
+	if code := place(map[string]string{"member_id": member.ID.Hex(), "isbn": isbn}); code != http.StatusBadRequest {
+		t.Errorf("expected BadRequest while a copy is available, got %d", code)
+	}
+
+	stores.Copies.SetStatus(ctx, "C-1", models.StatusChange{From: models.StatusAvailable, To: models.StatusOnLoan, Trigger: models.TriggerCheckout})
+
+	if code := place(map[string]string{"member_id": member.ID.Hex(), "isbn": isbn}); code != http.StatusOK {
+		t.Errorf("expected OK, got %d", code)
+	}
+	if code := place(map[string]string{"member_id": member.ID.Hex(), "isbn": isbn}); code != http.StatusConflict {
+		t.Errorf("expected Conflict for a second title hold, got %d", code)
+	}
+	if code := place(map[string]string{"member_id": member.ID.Hex(), "isbn": isbn, "copy_barcode": "C-1"}); code != http.StatusBadRequest {
+		t.Errorf("expected BadRequest when both isbn and copy_barcode are given, got %d", code)
+	}
+}
+
+func TestReservationHandler_PlaceHoldOutOfCirculation(t *testing.T) {
+	stores := store.NewMemoryStores()
+	handler := newReservationHandler(stores)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/holds/place", handler.PlaceHold).Methods("POST")
+
+	ctx := context.Background()
+	member := models.Member{Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+	withdrawnAt := time.Now()
+	stores.Books.Insert(ctx, &models.Book{ISBN: "9780140449136", WithdrawnAt: &withdrawnAt})
+	for _, status := range []models.CopyStatus{models.StatusWithdrawn, models.StatusLost, models.StatusDamaged, models.StatusReserved} {
+		stores.Copies.Insert(ctx, &models.Copy{Barcode: string(status), Status: status})
+	}
+
+	tests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"withdrawn title", map[string]string{"isbn": "9780140449136"}, http.StatusConflict},
+		{"withdrawn copy", map[string]string{"copy_barcode": string(models.StatusWithdrawn)}, http.StatusConflict},
+		{"lost copy", map[string]string{"copy_barcode": string(models.StatusLost)}, http.StatusConflict},
+		{"damaged copy", map[string]string{"copy_barcode": string(models.StatusDamaged)}, http.StatusConflict},
+		{"reserved copy", map[string]string{"copy_barcode": string(models.StatusReserved)}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		tt.body["member_id"] = member.ID.Hex()
+		reqBytes, _ := json.Marshal(tt.body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/holds/place", bytes.NewReader(reqBytes)))
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/middleware"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// memberScope resolves which member a read request may look at. Patrons are
+// limited to their own record, so an empty requested ID means theirs and any
+// other ID is refused; staff get requested back unchanged.
+func memberScope(r *http.Request, requested string) (string, bool) {
+	own, isPatron := middleware.PatronMemberID(r.Context())
+	if !isPatron {
+		return requested, true
+	}
+	if requested != "" && requested != own {
+		return "", false
+	}
+	return own, true
+}
+
+// requestMember loads the member a request names by member_id or by
+// card_number, within memberScope. It writes the response and returns false
+// when the member cannot be used.
+func requestMember(w http.ResponseWriter, r *http.Request, members store.MemberStore, memberID, cardNumber string) (models.Member, bool) {
+	if memberID != "" && cardNumber != "" {
+		utils.JSONError(w, "Provide either member_id or card_number", http.StatusBadRequest)
+		return models.Member{}, false
+	}
+
+	var member models.Member
+	if cardNumber != "" {
+		number, err := models.NormalizeCardNumber(cardNumber)
+		if err != nil {
+			utils.JSONError(w, "Invalid card number", http.StatusBadRequest)
+			return models.Member{}, false
+		}
+		member, err = members.FindOne(r.Context(), store.MemberFilter{CardNumber: number})
+		if writeMemberError(w, err) {
+			return models.Member{}, false
+		}
+	} else {
+		requested, allowed := memberScope(r, memberID)
+		if !allowed {
+			utils.JSONError(w, "Forbidden", http.StatusForbidden)
+			return models.Member{}, false
+		}
+		id, err := primitive.ObjectIDFromHex(requested)
+		if err != nil {
+			utils.JSONError(w, "Invalid member ID", http.StatusBadRequest)
+			return models.Member{}, false
+		}
+		member, err = members.Get(r.Context(), id)
+		if writeMemberError(w, err) {
+			return models.Member{}, false
+		}
+	}
+
+	// A patron may not use somebody else's card either
+	if _, allowed := memberScope(r, member.ID.Hex()); !allowed {
+		utils.JSONError(w, "Forbidden", http.StatusForbidden)
+		return models.Member{}, false
+	}
+	return member, true
+}
+
+// writeMemberError maps member lookup errors to a response and reports whether
+// it wrote one.
+func writeMemberError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, store.ErrNotFound):
+		utils.JSONError(w, "Member not found", http.StatusNotFound)
+	default:
+		utils.JSONError(w, "Failed to fetch member", http.StatusInternalServerError)
+	}
+	return true
+}
+
+// memberStatusCodes are the error codes of the reasons a member may not
+// borrow or place holds.
+var memberStatusCodes = []struct {
+	err  error
+	code string
+}{
+	{models.ErrMemberExpired, "MEMBERSHIP_EXPIRED"},
+	{models.ErrMemberSuspended, "MEMBER_SUSPENDED"},
+	{models.ErrMemberDeactivated, "MEMBER_DEACTIVATED"},
+	{models.ErrMemberBlocked, "MEMBER_BLOCKED"},
+}
+
+// writeMemberStatusError refuses a request with 403 and the code of err, one
+// of the errors of models.Member.CanBorrow or CanPlaceHold, and reports
+// whether it wrote a response.
+func writeMemberStatusError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, status := range memberStatusCodes {
+		if errors.Is(err, status.err) {
+			utils.JSONErrorCode(w, err.Error(), status.code, http.StatusForbidden)
+			return true
+		}
+	}
+	utils.JSONError(w, err.Error(), http.StatusForbidden)
+	return true
+}
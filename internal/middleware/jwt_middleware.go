@@ -3,13 +3,17 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"open-library-explorer/internal/models"
 	"open-library-explorer/internal/utils"
 	"strings"
 )
 
 type contextKey string
 
-const ContextUserID contextKey = "user_id"
+const (
+	ContextUserID contextKey = "user_id"
+	ContextClaims contextKey = "claims"
+)
 
 func JWTAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -27,6 +31,48 @@ func JWTAuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), ContextUserID, claims.UserID)
+		ctx = context.WithValue(ctx, ContextClaims, claims)
+
+		info := utils.RequestInfoFrom(ctx)
+		info.UserID = claims.UserID
+		ctx = utils.WithRequestInfo(ctx, info)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireRole only lets through requests whose token carries one of roles.
+// It must run after JWTAuthMiddleware.
+func RequireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := Claims(r.Context())
+			if claims == nil {
+				utils.JSONError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			for _, role := range roles {
+				if claims.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			utils.JSONError(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// Claims returns the token claims JWTAuthMiddleware stored on ctx, if any.
+func Claims(ctx context.Context) *utils.JWTClaims {
+	claims, _ := ctx.Value(ContextClaims).(*utils.JWTClaims)
+	return claims
+}
+
+// PatronMemberID returns the member record a patron token is limited to. ok is
+// false for staff and unauthenticated requests, which are not limited.
+func PatronMemberID(ctx context.Context) (memberID string, ok bool) {
+	claims := Claims(ctx)
+	if claims == nil || claims.Role != models.RolePatron {
+		return "", false
+	}
+	return claims.MemberID, true
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+
+	"open-library-explorer/internal/utils"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestInfoMiddleware tags every request with a request ID and the client
+// IP so audit entries can be traced back to it. An incoming X-Request-ID is
+// kept, otherwise one is generated; either way it is echoed in the response.
+func RequestInfoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		info := utils.RequestInfoFrom(r.Context())
+		info.RequestID = requestID
+		info.ClientIP = clientIP(r)
+		next.ServeHTTP(w, r.WithContext(utils.WithRequestInfo(r.Context(), info)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// clientIP prefers the first address of X-Forwarded-For, as set by a proxy in
+// front of the server, and falls back to the connection's remote address.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -11,6 +11,11 @@ type AuditLog struct {
 	Entity      string             `bson:"entity" json:"entity"`
 	Action      string             `bson:"action" json:"action"`
 	PerformedBy string             `bson:"performed_by" json:"performed_by"` // could be user ID or system
-	Data        any                `bson:"data" json:"data"`                 // raw payload
+	RequestID   string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	ClientIP    string             `bson:"client_ip,omitempty" json:"client_ip,omitempty"`
+	Data        any                `bson:"data" json:"data"` // raw payload
 	Exported    bool               `bson:"exported" json:"exported"`
+	Attempts    int                `bson:"attempts,omitempty" json:"attempts,omitempty"` // failed export attempts
+	LastError   string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	DeadLetter  bool               `bson:"dead_letter,omitempty" json:"dead_letter,omitempty"` // gave up exporting after too many failures
 }
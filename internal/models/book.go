@@ -1,15 +1,125 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type ContributorRole string
+
+const (
+	ContributorAuthor      ContributorRole = "author"
+	ContributorEditor      ContributorRole = "editor"
+	ContributorTranslator  ContributorRole = "translator"
+	ContributorIllustrator ContributorRole = "illustrator"
+	ContributorOther       ContributorRole = "contributor"
+)
+
+var ContributorRoleMap = map[string]bool{
+	string(ContributorAuthor):      true,
+	string(ContributorEditor):      true,
+	string(ContributorTranslator):  true,
+	string(ContributorIllustrator): true,
+	string(ContributorOther):       true,
+}
+
+func IsValidContributorRole(role string) bool {
+	return ContributorRoleMap[role]
+}
+
+type Contributor struct {
+	Name string          `json:"name" bson:"name"`
+	Role ContributorRole `json:"role" bson:"role"`
+}
+
 type Book struct {
-	ISBN          string   `json:"isbn" bson:"isbn"`
-	Title         string   `json:"title" bson:"title"`
-	Author        string   `json:"author" bson:"author"`
-	Publisher     string   `json:"publisher" bson:"publisher"`
-	Tags          []string `json:"tags" bson:"tags"`
-	Subject       string   `json:"subject" bson:"subject"`
-	PublishedYear int      `json:"published_year" bson:"published_year"`
+	ISBN          string        `json:"isbn" bson:"isbn"`
+	Title         string        `json:"title" bson:"title"`
+	Contributors  []Contributor `json:"contributors" bson:"contributors"`
+	Publisher     string        `json:"publisher" bson:"publisher"`
+	Tags          []string      `json:"tags" bson:"tags"`
+	Subjects      []string      `json:"subjects" bson:"subjects"`
+	Language      string        `json:"language,omitempty" bson:"language,omitempty"` // ISO 639 code, e.g. en or eng
+	Edition       string        `json:"edition,omitempty" bson:"edition,omitempty"`
+	Series        string        `json:"series,omitempty" bson:"series,omitempty"`
+	SeriesNumber  float64       `json:"series_number,omitempty" bson:"series_number,omitempty"`
+	PublishedYear int           `json:"published_year" bson:"published_year"`
+	PageCount     int           `json:"page_count,omitempty" bson:"page_count,omitempty"`
+	CoverID       int           `json:"cover_id,omitempty" bson:"cover_id,omitempty"` // Open Library cover
+	Description   string        `json:"description,omitempty" bson:"description,omitempty"`
+
+	// WithdrawnAt is set when the book is deleted. It is kept so that the
+	// copies and loans referring to it still resolve.
+	WithdrawnAt *time.Time `json:"withdrawn_at,omitempty" bson:"withdrawn_at,omitempty"`
+
+	// Score is the text search relevance, only set on search results
+	Score float64 `json:"score,omitempty" bson:"score,omitempty"`
+
+	// Fields of the old single-author model, only read by the migration that
+	// turns them into Contributors and Subjects
+	LegacyAuthor  string   `json:"-" bson:"author,omitempty"`
+	LegacyAuthors []string `json:"-" bson:"authors,omitempty"`
+	LegacySubject string   `json:"-" bson:"subject,omitempty"`
 }
 
 const (
 	BookEntity = "book"
 )
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// Authors returns the names of the contributors with the author role.
+func (b Book) Authors() []string {
+	var names []string
+	for _, c := range b.Contributors {
+		if c.Role == ContributorAuthor {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// ContributorNames returns the names of all contributors, whatever their role.
+func (b Book) ContributorNames() []string {
+	names := make([]string, 0, len(b.Contributors))
+	for _, c := range b.Contributors {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// Validate checks the fields that have a value. It does not require any, so
+// it also suits the partial books of an update.
+func (b Book) Validate() error {
+	for _, c := range b.Contributors {
+		if c.Name == "" {
+			return errors.New("contributor name is required")
+		}
+		if !IsValidContributorRole(string(c.Role)) {
+			return fmt.Errorf("invalid contributor role %q", c.Role)
+		}
+	}
+	for _, subject := range b.Subjects {
+		if subject == "" {
+			return errors.New("subjects must not be empty")
+		}
+	}
+	if b.Language != "" && !languagePattern.MatchString(b.Language) {
+		return fmt.Errorf("invalid language %q, expected an ISO 639 code", b.Language)
+	}
+	if b.SeriesNumber < 0 {
+		return errors.New("series_number must not be negative")
+	}
+	if b.SeriesNumber != 0 && b.Series == "" {
+		return errors.New("series_number needs a series")
+	}
+	if b.PageCount < 0 {
+		return errors.New("page_count must not be negative")
+	}
+	if b.PublishedYear < 0 {
+		return errors.New("published_year must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package models_test
+
+import (
+	"testing"
+
+	"open-library-explorer/internal/models"
+)
+
+func TestBook_Validate(t *testing.T) {
+	translator := models.Contributor{Name: "David McDuff", Role: models.ContributorTranslator}
+
+	tests := []struct {
+		name    string
+		book    models.Book
+		isValid bool
+	}{
+		{"Empty Book", models.Book{}, true},
+		{"Full Book", models.Book{Contributors: []models.Contributor{translator}, Subjects: []string{"Murder"},
+			Language: "eng", Series: "Penguin Classics", SeriesNumber: 2.5, PageCount: 656}, true},
+		{"Unnamed Contributor", models.Book{Contributors: []models.Contributor{{Role: models.ContributorAuthor}}}, false},
+		{"Unknown Role", models.Book{Contributors: []models.Contributor{{Name: "X", Role: "ghostwriter"}}}, false},
+		{"Empty Subject", models.Book{Subjects: []string{""}}, false},
+		{"Language Name", models.Book{Language: "English"}, false},
+		{"Series Number Without Series", models.Book{SeriesNumber: 1}, false},
+		{"Negative Page Count", models.Book{PageCount: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.book.Validate(); (err == nil) != tt.isValid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.isValid)
+			}
+		})
+	}
+}
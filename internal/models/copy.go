@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,6 +15,10 @@ const (
 	StatusOnLoan    CopyStatus = "ON_LOAN"
 	StatusReserved  CopyStatus = "RESERVED"
 	StatusLost      CopyStatus = "LOST"
+	StatusDamaged   CopyStatus = "DAMAGED"
+	// StatusWithdrawn copies have been deleted from the collection. They are
+	// kept so that past loans of them still resolve.
+	StatusWithdrawn CopyStatus = "WITHDRAWN"
 
 	CopyEntity = "Copy"
 )
@@ -24,6 +30,12 @@ type Copy struct {
 	Status    CopyStatus         `bson:"status" json:"status"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	// ReplacementCost is charged to a member who loses or damages the copy
+	ReplacementCost float64 `bson:"replacement_cost,omitempty" json:"replacement_cost,omitempty"`
+	// WithdrawnAt is set along with StatusWithdrawn
+	WithdrawnAt *time.Time `bson:"withdrawn_at,omitempty" json:"withdrawn_at,omitempty"`
+	// History records every status change, oldest first
+	History []StatusChange `bson:"history,omitempty" json:"-"`
 }
 
 var ValidCopyStatuses = map[string]bool{
@@ -31,8 +43,88 @@ var ValidCopyStatuses = map[string]bool{
 	string(StatusOnLoan):    true,
 	string(StatusReserved):  true,
 	string(StatusLost):      true,
+	string(StatusDamaged):   true,
+	string(StatusWithdrawn): true,
 }
 
 func IsValidCopyStatus(status string) bool {
 	return ValidCopyStatuses[status]
 }
+
+// StatusTrigger is what moves a copy from one status to another.
+type StatusTrigger string
+
+const (
+	TriggerCheckout StatusTrigger = "checkout"
+	TriggerCheckin  StatusTrigger = "checkin"
+	TriggerHold     StatusTrigger = "hold" // a hold was cancelled or expired, passing the copy on
+	TriggerLost     StatusTrigger = "lost"
+	TriggerDamaged  StatusTrigger = "damaged"
+	TriggerFound    StatusTrigger = "found"
+	TriggerWithdraw StatusTrigger = "withdraw"
+)
+
+// ErrInvalidTransition is returned for a status change the state machine
+// does not allow.
+var ErrInvalidTransition = errors.New("invalid copy status transition")
+
+// copyTransitions lists for each trigger the statuses it may move a copy to
+// from each status.
+var copyTransitions = map[StatusTrigger]map[CopyStatus][]CopyStatus{
+	TriggerCheckout: {
+		StatusAvailable: {StatusOnLoan},
+		StatusReserved:  {StatusOnLoan},
+	},
+	TriggerCheckin: {
+		StatusOnLoan: {StatusAvailable, StatusReserved},
+		// a copy its borrower claimed to have returned turns up at the desk
+		StatusLost: {StatusAvailable, StatusReserved},
+	},
+	TriggerHold: {
+		StatusReserved: {StatusAvailable, StatusReserved},
+	},
+	TriggerLost: {
+		StatusAvailable: {StatusLost},
+		StatusOnLoan:    {StatusLost},
+		StatusReserved:  {StatusLost},
+	},
+	TriggerDamaged: {
+		StatusAvailable: {StatusDamaged},
+		StatusOnLoan:    {StatusDamaged},
+		StatusReserved:  {StatusDamaged},
+	},
+	TriggerFound: {
+		StatusLost: {StatusAvailable, StatusReserved},
+	},
+	TriggerWithdraw: {
+		StatusAvailable: {StatusWithdrawn},
+		StatusOnLoan:    {StatusWithdrawn},
+		StatusReserved:  {StatusWithdrawn},
+		StatusLost:      {StatusWithdrawn},
+		StatusDamaged:   {StatusWithdrawn},
+	},
+}
+
+// StatusChange is one transition of a copy's status.
+type StatusChange struct {
+	From    CopyStatus    `bson:"from" json:"from"`
+	To      CopyStatus    `bson:"to" json:"to"`
+	Trigger StatusTrigger `bson:"trigger" json:"trigger"`
+	Reason  string        `bson:"reason,omitempty" json:"reason,omitempty"`
+	At      time.Time     `bson:"at" json:"at"`
+}
+
+// Validate checks that Trigger may move a copy from From to To. Marking a
+// copy lost needs a reason.
+func (c StatusChange) Validate() error {
+	for _, to := range copyTransitions[c.Trigger][c.From] {
+		if to != c.To {
+			continue
+		}
+		if c.Trigger == TriggerLost && c.Reason == "" {
+			return fmt.Errorf("%w: a reason is required to mark a copy lost", ErrInvalidTransition)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s cannot move a copy from %s to %s", ErrInvalidTransition, c.Trigger, c.From, c.To)
+}
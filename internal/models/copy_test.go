@@ -0,0 +1,45 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+
+	"open-library-explorer/internal/models"
+)
+
+func TestStatusChange_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  models.StatusChange
+		isValid bool
+	}{
+		{"Checkout", models.StatusChange{From: models.StatusAvailable, To: models.StatusOnLoan, Trigger: models.TriggerCheckout}, true},
+		{"Checkout of reserved copy", models.StatusChange{From: models.StatusReserved, To: models.StatusOnLoan, Trigger: models.TriggerCheckout}, true},
+		{"On loan without checkout", models.StatusChange{From: models.StatusAvailable, To: models.StatusOnLoan, Trigger: models.TriggerFound}, false},
+		{"Checkin to shelf", models.StatusChange{From: models.StatusOnLoan, To: models.StatusAvailable, Trigger: models.TriggerCheckin}, true},
+		{"Checkin to hold", models.StatusChange{From: models.StatusOnLoan, To: models.StatusReserved, Trigger: models.TriggerCheckin}, true},
+		{"Checkin of available copy", models.StatusChange{From: models.StatusAvailable, To: models.StatusAvailable, Trigger: models.TriggerCheckin}, false},
+		{"Hold passed on", models.StatusChange{From: models.StatusReserved, To: models.StatusReserved, Trigger: models.TriggerHold}, true},
+		{"Lost with reason", models.StatusChange{From: models.StatusOnLoan, To: models.StatusLost, Trigger: models.TriggerLost, Reason: "never returned"}, true},
+		{"Lost without reason", models.StatusChange{From: models.StatusAvailable, To: models.StatusLost, Trigger: models.TriggerLost}, false},
+		{"Damaged on loan", models.StatusChange{From: models.StatusOnLoan, To: models.StatusDamaged, Trigger: models.TriggerDamaged}, true},
+		{"Claimed copy checked in", models.StatusChange{From: models.StatusLost, To: models.StatusAvailable, Trigger: models.TriggerCheckin}, true},
+		{"Found", models.StatusChange{From: models.StatusLost, To: models.StatusAvailable, Trigger: models.TriggerFound}, true},
+		{"Found for a hold", models.StatusChange{From: models.StatusLost, To: models.StatusReserved, Trigger: models.TriggerFound}, true},
+		{"Found straight onto loan", models.StatusChange{From: models.StatusLost, To: models.StatusOnLoan, Trigger: models.TriggerFound}, false},
+		{"Withdraw", models.StatusChange{From: models.StatusLost, To: models.StatusWithdrawn, Trigger: models.TriggerWithdraw}, true},
+		{"Back from withdrawn", models.StatusChange{From: models.StatusWithdrawn, To: models.StatusAvailable, Trigger: models.TriggerFound}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.change.Validate()
+			if (err == nil) != tt.isValid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.isValid)
+			}
+			if err != nil && !errors.Is(err, models.ErrInvalidTransition) {
+				t.Errorf("expected ErrInvalidTransition, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FineStatus string
+
+const (
+	FineOpen   FineStatus = "OPEN"
+	FinePaid   FineStatus = "PAID"
+	FineWaived FineStatus = "WAIVED"
+	// FineRefunded fines were charged in error; what was paid is returned
+	FineRefunded FineStatus = "REFUNDED"
+
+	FineEntity = "fine"
+)
+
+type FinePayment struct {
+	Amount float64   `bson:"amount" json:"amount"`
+	PaidAt time.Time `bson:"paid_at" json:"paid_at"`
+}
+
+type Fine struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MemberID     primitive.ObjectID `bson:"member_id" json:"member_id"`
+	LoanID       primitive.ObjectID `bson:"loan_id,omitempty" json:"loan_id,omitempty"`
+	CopyBarcode  string             `bson:"copy_barcode,omitempty" json:"copy_barcode,omitempty"`
+	Reason       string             `bson:"reason" json:"reason"`
+	Amount       float64            `bson:"amount" json:"amount"`
+	AmountPaid   float64            `bson:"amount_paid" json:"amount_paid"`
+	Payments     []FinePayment      `bson:"payments" json:"payments"`
+	Status       FineStatus         `bson:"status" json:"status"`
+	WaiveReason  string             `bson:"waive_reason,omitempty" json:"waive_reason,omitempty"`
+	Refunded     float64            `bson:"refunded,omitempty" json:"refunded,omitempty"`
+	RefundReason string             `bson:"refund_reason,omitempty" json:"refund_reason,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Outstanding is what the member still owes on the fine.
+func (f Fine) Outstanding() float64 {
+	if f.Status != FineOpen {
+		return 0
+	}
+	return f.Amount - f.AmountPaid
+}
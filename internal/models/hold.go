@@ -8,11 +8,24 @@ import (
 type Hold struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	MemberID    primitive.ObjectID `bson:"member_id" json:"member_id"`
-	CopyBarcode string             `bson:"copy_barcode" json:"copy_barcode"`
+	CopyBarcode string             `bson:"copy_barcode" json:"copy_barcode"`     // for title holds, the copy assigned once notified
+	ISBN        string             `bson:"isbn,omitempty" json:"isbn,omitempty"` // set for title holds, satisfied by any copy
 	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
 	Fulfilled   bool               `bson:"fulfilled" json:"fulfilled"`
 	Notified    bool               `bson:"notified" json:"notified"`
 	PickupBy    *time.Time         `bson:"pickup_by,omitempty" json:"pickup_by,omitempty"`
+	Cancelled   bool               `bson:"cancelled" json:"cancelled"`
+	Expired     bool               `bson:"expired" json:"expired"` // not picked up before PickupBy
+}
+
+// IsTitleHold reports whether the hold was placed on a book rather than a copy.
+func (h Hold) IsTitleHold() bool {
+	return h.ISBN != ""
+}
+
+// IsOpen reports whether the hold is still waiting in, or at the head of, its queue.
+func (h Hold) IsOpen() bool {
+	return !h.Fulfilled && !h.Cancelled && !h.Expired
 }
 
 const (
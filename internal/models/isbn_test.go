@@ -0,0 +1,37 @@
+package models_test
+
+import (
+	"testing"
+
+	"open-library-explorer/internal/models"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name  string
+		isbn  string
+		want  string
+		valid bool
+	}{
+		{"ISBN-13", "9780140449136", "9780140449136", true},
+		{"Hyphenated ISBN-13", "978-3-16-148410-0", "9783161484100", true},
+		{"ISBN-10", "0140449132", "9780140449136", true},
+		{"ISBN-10 with X check digit", "0-8044-2957-x", "9780804429573", true},
+		{"979 prefix", "979-10-90636-07-1", "9791090636071", true},
+		{"Bad ISBN-13 checksum", "9780140449137", "", false},
+		{"Bad ISBN-10 checksum", "0140449133", "", false},
+		{"X outside check digit", "01404491X2", "", false},
+		{"Not a book prefix", "9771234567003", "", false},
+		{"Wrong length", "12345", "", false},
+		{"Empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.NormalizeISBN(tt.isbn)
+			if (err == nil) != tt.valid || got != tt.want {
+				t.Errorf("NormalizeISBN(%q) = %q, %v, want %q", tt.isbn, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,16 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// LoanOutcome says how a loan that did not end in a normal check-in was
+// closed.
+type LoanOutcome string
+
+const (
+	OutcomeLost    LoanOutcome = "lost"
+	OutcomeDamaged LoanOutcome = "damaged"
+	OutcomeFound   LoanOutcome = "found" // the copy turned up after being lost
+)
+
 type Loan struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	MemberID    primitive.ObjectID `bson:"member_id" json:"member_id"`
@@ -13,6 +23,10 @@ type Loan struct {
 	LoanDate    time.Time          `bson:"loan_date" json:"loan_date"`
 	DueDate     time.Time          `bson:"due_date" json:"due_date"`
 	Returned    bool               `bson:"returned" json:"returned"`
+	Outcome     LoanOutcome        `bson:"outcome,omitempty" json:"outcome,omitempty"`
+	// ClaimsReturnedAt is set while the member says they returned a copy that
+	// cannot be found
+	ClaimsReturnedAt *time.Time `bson:"claims_returned_at,omitempty" json:"claims_returned_at,omitempty"`
 }
 
 const (
@@ -1,8 +1,16 @@
 package models
 
 import (
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"strings"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type MembershipTier string
@@ -12,18 +20,55 @@ const (
 	TierPremium  MembershipTier = "PREMIUM"
 
 	MemberEntity = "member"
+
+	BlockedByFines = "fines"
 )
 
+// MemberStatus is where a member stands with the library. Being blocked by
+// fines is tracked apart from it, as it comes and goes with the balance.
+type MemberStatus string
+
+const (
+	MemberActive      MemberStatus = "ACTIVE"
+	MemberSuspended   MemberStatus = "SUSPENDED"   // barred from borrowing by staff, see Suspension
+	MemberDeactivated MemberStatus = "DEACTIVATED" // closed, e.g. on leaving the library
+	MemberExpired     MemberStatus = "EXPIRED"     // membership ran out
+)
+
+// MembershipTerm is how long a membership of a tier runs and what renewing
+// it costs.
+type MembershipTerm struct {
+	Months int
+	Fee    float64
+}
+
+// DefaultMembershipMonths is the length of a membership whose tier has no term.
+const DefaultMembershipMonths = 12
+
+// Suspension records why and until when a member is suspended.
+type Suspension struct {
+	Reason string     `bson:"reason" json:"reason"`
+	Since  time.Time  `bson:"since" json:"since"`
+	Until  *time.Time `bson:"until,omitempty" json:"until,omitempty"` // nil until lifted by staff
+}
+
 type Member struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name      string             `bson:"name" json:"name"`
-	Email     string             `bson:"email" json:"email"`
-	Phone     string             `bson:"phone" json:"phone"`
-	Tier      MembershipTier     `bson:"tier" json:"tier"`
-	Blocked   bool               `bson:"blocked" json:"blocked"`
-	Active    bool               `bson:"active" json:"active"` // For deactivation
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Email      string             `bson:"email" json:"email"`
+	Phone      string             `bson:"phone" json:"phone"`
+	CardNumber string             `bson:"card_number,omitempty" json:"card_number,omitempty"` // on the library card, accepted instead of the ID
+	Tier       MembershipTier     `bson:"tier" json:"tier"`
+	Status     MemberStatus       `bson:"status,omitempty" json:"status"`
+	Suspension *Suspension        `bson:"suspension,omitempty" json:"suspension,omitempty"`
+	// Members registered before memberships had dates never expire
+	MembershipStart  *time.Time `bson:"membership_start,omitempty" json:"membership_start,omitempty"`
+	MembershipExpiry *time.Time `bson:"membership_expiry,omitempty" json:"membership_expiry,omitempty"`
+	ExpiryNoticeSent *time.Time `bson:"expiry_notice_sent,omitempty" json:"expiry_notice_sent,omitempty"` // cleared on renewal
+	Blocked          bool       `bson:"blocked" json:"blocked"`
+	BlockedBy        string     `bson:"blocked_by,omitempty" json:"blocked_by,omitempty"` // BlockedByFines when set automatically
+	CreatedAt        time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time  `bson:"updated_at" json:"updated_at"`
 }
 
 var MemberTierMap = map[string]bool{
@@ -39,3 +84,176 @@ var TierRenewalDays = map[string]int{
 	string(TierStandard): 7,
 	string(TierPremium):  14,
 }
+
+var (
+	ErrMemberSuspended         = errors.New("member is suspended")
+	ErrMemberDeactivated       = errors.New("member is deactivated")
+	ErrMemberExpired           = errors.New("membership has expired")
+	ErrMemberBlocked           = errors.New("member is blocked by outstanding fines")
+	ErrInvalidMemberTransition = errors.New("invalid member status transition")
+
+	ErrMemberName        = errors.New("name is required")
+	ErrMemberEmail       = errors.New("email must be a valid address")
+	ErrMemberPhone       = errors.New("phone must be in E.164 format, e.g. +14155552671")
+	ErrMemberTier        = errors.New("invalid member tier")
+	ErrInvalidCardNumber = errors.New("invalid card number")
+)
+
+// StatusAt returns the status of the member at now. Members registered
+// before statuses existed are active, a membership past its expiry date has
+// expired even before the daily job marks it, and a suspension with an end
+// date is over once that date has passed.
+func (m Member) StatusAt(now time.Time) MemberStatus {
+	switch {
+	case m.Status == MemberDeactivated:
+		return m.Status
+	case m.MembershipExpiry != nil && !now.Before(*m.MembershipExpiry):
+		return MemberExpired
+	case m.Status == "":
+		return MemberActive
+	case m.Status == MemberSuspended && m.Suspension != nil && m.Suspension.Until != nil && !now.Before(*m.Suspension.Until):
+		return MemberActive
+	}
+	return m.Status
+}
+
+// statusError explains why a member in status may not use the library.
+func (m Member) statusError(status MemberStatus) error {
+	switch status {
+	case MemberSuspended:
+		if m.Suspension != nil && m.Suspension.Reason != "" {
+			return fmt.Errorf("%w: %s", ErrMemberSuspended, m.Suspension.Reason)
+		}
+		return ErrMemberSuspended
+	case MemberDeactivated:
+		return ErrMemberDeactivated
+	case MemberExpired:
+		return ErrMemberExpired
+	}
+	return nil
+}
+
+// CanBorrow reports why the member may not check out or renew loans at now,
+// or nil when they may. Only active members who are not blocked by fines can.
+func (m Member) CanBorrow(now time.Time) error {
+	if err := m.statusError(m.StatusAt(now)); err != nil {
+		return err
+	}
+	if m.Blocked {
+		return ErrMemberBlocked
+	}
+	return nil
+}
+
+// CanPlaceHold reports why the member may not place holds at now, or nil when
+// they may. Suspended and fine-blocked members can still queue for books
+// they will borrow once they are in good standing again.
+func (m Member) CanPlaceHold(now time.Time) error {
+	status := m.StatusAt(now)
+	if status == MemberSuspended {
+		return nil
+	}
+	return m.statusError(status)
+}
+
+// memberTransitions lists for each status the statuses a member may move to
+// it from. Suspending a suspended member replaces the suspension.
+var memberTransitions = map[MemberStatus][]MemberStatus{
+	MemberActive:      {MemberSuspended, MemberDeactivated},
+	MemberSuspended:   {MemberActive, MemberSuspended},
+	MemberDeactivated: {MemberActive, MemberSuspended, MemberExpired},
+}
+
+// CheckMemberTransition returns ErrInvalidMemberTransition unless a member
+// may move from one status to another.
+func CheckMemberTransition(from, to MemberStatus) error {
+	for _, allowed := range memberTransitions[to] {
+		if allowed == from {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w from %s to %s", ErrInvalidMemberTransition, from, to)
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Normalize trims the contact fields and lowercases the email, so that the
+// unique index on it ignores case.
+func (m *Member) Normalize() {
+	m.Name = strings.TrimSpace(m.Name)
+	m.Email = strings.ToLower(strings.TrimSpace(m.Email))
+	m.Phone = strings.TrimSpace(m.Phone)
+}
+
+// Validate checks the fields a member is registered with. The phone is
+// optional.
+func (m Member) Validate() error {
+	if m.Name == "" {
+		return ErrMemberName
+	}
+	if addr, err := mail.ParseAddress(m.Email); err != nil || addr.Address != m.Email {
+		return ErrMemberEmail
+	}
+	if m.Phone != "" && !e164.MatchString(m.Phone) {
+		return ErrMemberPhone
+	}
+	if !IsValidMemberTier(string(m.Tier)) {
+		return ErrMemberTier
+	}
+	return nil
+}
+
+const (
+	// cardNumberDigits is the length of a card number without its check digit.
+	cardNumberDigits = 9
+	// CardNumberAttempts is how often to draw another card number when the
+	// one generated turns out to be taken.
+	CardNumberAttempts = 5
+)
+
+// NewCardNumber returns a random card number: nine digits, the first never 0,
+// followed by a Luhn check digit so that mistyped numbers are caught.
+func NewCardNumber() string {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(cardNumberDigits), nil)
+	min := new(big.Int).Div(max, big.NewInt(10))
+	n, err := rand.Int(rand.Reader, new(big.Int).Sub(max, min))
+	if err != nil {
+		panic(err)
+	}
+	digits := n.Add(n, min).String()
+	return digits + luhnCheckDigit(digits)
+}
+
+// NormalizeCardNumber validates a card number, with or without spaces or
+// hyphens, and returns its bare digits.
+func NormalizeCardNumber(number string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	if len(digits) != cardNumberDigits+1 || !allDigits(digits) {
+		return "", ErrInvalidCardNumber
+	}
+	if luhnCheckDigit(digits[:cardNumberDigits]) != digits[cardNumberDigits:] {
+		return "", ErrInvalidCardNumber
+	}
+	return digits, nil
+}
+
+// luhnCheckDigit computes the Luhn check digit to append to digits.
+func luhnCheckDigit(digits string) string {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
@@ -1,7 +1,9 @@
 package models_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"open-library-explorer/internal/models"
 )
@@ -26,3 +28,132 @@ func TestIsValidMemberTier(t *testing.T) {
 		})
 	}
 }
+
+func TestMemberValidate(t *testing.T) {
+	valid := models.Member{Name: "Ada Lovelace", Email: "ada@example.com", Phone: "+441234567890", Tier: models.TierStandard}
+	tests := []struct {
+		name   string
+		change func(m *models.Member)
+		want   error
+	}{
+		{"Valid", func(m *models.Member) {}, nil},
+		{"Without Phone", func(m *models.Member) { m.Phone = "" }, nil},
+		{"Missing Name", func(m *models.Member) { m.Name = "" }, models.ErrMemberName},
+		{"Missing Email", func(m *models.Member) { m.Email = "" }, models.ErrMemberEmail},
+		{"Email Without Domain", func(m *models.Member) { m.Email = "ada@" }, models.ErrMemberEmail},
+		{"Email With Display Name", func(m *models.Member) { m.Email = "Ada <ada@example.com>" }, models.ErrMemberEmail},
+		{"Phone Without Plus", func(m *models.Member) { m.Phone = "441234567890" }, models.ErrMemberPhone},
+		{"Phone With Spaces", func(m *models.Member) { m.Phone = "+44 1234 567890" }, models.ErrMemberPhone},
+		{"Phone Too Long", func(m *models.Member) { m.Phone = "+1234567890123456" }, models.ErrMemberPhone},
+		{"Invalid Tier", func(m *models.Member) { m.Tier = "GOLD" }, models.ErrMemberTier},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			member := valid
+			tt.change(&member)
+			if got := member.Validate(); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCardNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  string
+		want    string
+		wantErr bool
+	}{
+		{"Valid", "1234567897", "1234567897", false},
+		{"With Spaces And Hyphens", "123-456 789-7", "1234567897", false},
+		{"Wrong Check Digit", "1234567890", "", true},
+		{"Transposed Digits", "2134567897", "", true},
+		{"Too Short", "123456789", "", true},
+		{"Letters", "12345678X7", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.NormalizeCardNumber(tt.number)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("NormalizeCardNumber(%q) = %q, %v, want %q", tt.number, got, err, tt.want)
+			}
+		})
+	}
+
+	for i := 0; i < 100; i++ {
+		number := models.NewCardNumber()
+		if got, err := models.NormalizeCardNumber(number); err != nil || got != number || number[0] == '0' {
+			t.Fatalf("generated card number %q does not validate: %v", number, err)
+		}
+	}
+}
+
+func TestMemberPrivileges(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.AddDate(0, 0, 7), now.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name      string
+		member    models.Member
+		status    models.MemberStatus
+		borrowErr error
+		holdErr   error
+	}{
+		{"Legacy Member", models.Member{}, models.MemberActive, nil, nil},
+		{"Active", models.Member{Status: models.MemberActive}, models.MemberActive, nil, nil},
+		{"Blocked By Fines", models.Member{Status: models.MemberActive, Blocked: true}, models.MemberActive, models.ErrMemberBlocked, nil},
+		{"Suspended", models.Member{Status: models.MemberSuspended, Suspension: &models.Suspension{Reason: "damaged books", Until: &later}},
+			models.MemberSuspended, models.ErrMemberSuspended, nil},
+		{"Suspension Over", models.Member{Status: models.MemberSuspended, Suspension: &models.Suspension{Reason: "damaged books", Until: &earlier}},
+			models.MemberActive, nil, nil},
+		{"Suspended Indefinitely", models.Member{Status: models.MemberSuspended, Suspension: &models.Suspension{Reason: "abuse"}},
+			models.MemberSuspended, models.ErrMemberSuspended, nil},
+		{"Deactivated", models.Member{Status: models.MemberDeactivated}, models.MemberDeactivated, models.ErrMemberDeactivated, models.ErrMemberDeactivated},
+		{"Expired", models.Member{Status: models.MemberExpired}, models.MemberExpired, models.ErrMemberExpired, models.ErrMemberExpired},
+		{"Membership Running", models.Member{Status: models.MemberActive, MembershipExpiry: &later}, models.MemberActive, nil, nil},
+		{"Membership Lapsed", models.Member{Status: models.MemberActive, MembershipExpiry: &earlier}, models.MemberExpired, models.ErrMemberExpired, models.ErrMemberExpired},
+		{"Suspended Past Expiry", models.Member{Status: models.MemberSuspended, Suspension: &models.Suspension{Reason: "abuse"}, MembershipExpiry: &earlier},
+			models.MemberExpired, models.ErrMemberExpired, models.ErrMemberExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.member.StatusAt(now); got != tt.status {
+				t.Errorf("StatusAt() = %v, want %v", got, tt.status)
+			}
+			if err := tt.member.CanBorrow(now); !errors.Is(err, tt.borrowErr) {
+				t.Errorf("CanBorrow() = %v, want %v", err, tt.borrowErr)
+			}
+			if err := tt.member.CanPlaceHold(now); !errors.Is(err, tt.holdErr) {
+				t.Errorf("CanPlaceHold() = %v, want %v", err, tt.holdErr)
+			}
+		})
+	}
+}
+
+func TestCheckMemberTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.MemberStatus
+		allowed  bool
+	}{
+		{models.MemberActive, models.MemberSuspended, true},
+		{models.MemberSuspended, models.MemberSuspended, true},
+		{models.MemberSuspended, models.MemberActive, true},
+		{models.MemberActive, models.MemberDeactivated, true},
+		{models.MemberDeactivated, models.MemberActive, true},
+		{models.MemberExpired, models.MemberDeactivated, true},
+		{models.MemberActive, models.MemberActive, false},
+		{models.MemberDeactivated, models.MemberSuspended, false},
+		{models.MemberExpired, models.MemberActive, false},
+	}
+
+	for _, tt := range tests {
+		err := models.CheckMemberTransition(tt.from, tt.to)
+		if (err == nil) != tt.allowed || err != nil && !errors.Is(err, models.ErrInvalidMemberTransition) {
+			t.Errorf("CheckMemberTransition(%s, %s) = %v, want allowed %v", tt.from, tt.to, err, tt.allowed)
+		}
+	}
+}
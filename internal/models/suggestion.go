@@ -0,0 +1,15 @@
+package models
+
+const (
+	SuggestTitle  = "title"
+	SuggestAuthor = "author"
+)
+
+// Suggestion is one entry of the autocomplete index: a book title or author
+// name with the trigrams it is matched by.
+type Suggestion struct {
+	ISBN     string   `bson:"isbn" json:"isbn"`
+	Kind     string   `bson:"kind" json:"kind"` // SuggestTitle or SuggestAuthor
+	Text     string   `bson:"text" json:"text"`
+	Trigrams []string `bson:"trigrams" json:"-"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleLibrarian Role = "librarian"
+	RolePatron    Role = "patron"
+
+	UserEntity = "user"
+)
+
+type User struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Username     string             `bson:"username" json:"username"`
+	PasswordHash string             `bson:"password_hash" json:"-"`
+	Role         Role               `bson:"role" json:"role"`
+	MemberID     primitive.ObjectID `bson:"member_id,omitempty" json:"member_id,omitempty"` // the patron's own member record
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+var ValidRoles = map[string]bool{
+	string(RoleAdmin):     true,
+	string(RoleLibrarian): true,
+	string(RolePatron):    true,
+}
+
+func IsValidRole(role string) bool {
+	return ValidRoles[role]
+}
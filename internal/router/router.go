@@ -0,0 +1,198 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"open-library-explorer/configs"
+	"open-library-explorer/internal/daemon"
+	"open-library-explorer/internal/enrichment"
+	"open-library-explorer/internal/handlers"
+	"open-library-explorer/internal/middleware"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// New registers every API route on a router whose handlers use stores.
+// daemons may be nil when no background jobs run, and metadata nil when new
+// books are not enriched.
+func New(cfg configs.Config, stores store.Stores, daemons *daemon.Supervisor, metadata enrichment.Provider) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(middleware.JSONMiddleware)
+	r.Use(middleware.RequestInfoMiddleware)
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	})
+
+	auditLogger := utils.Logger{Store: stores.Audit}
+	holdQueue := &services.HoldQueue{
+		Holds:       stores.Holds,
+		Copies:      stores.Copies,
+		AuditLogger: auditLogger,
+		PickupDays:  cfg.HoldPickupDays,
+	}
+	fineLedger := &services.FineLedger{
+		Fines:          stores.Fines,
+		Members:        stores.Members,
+		AuditLogger:    auditLogger,
+		Rate:           cfg.FineRate,
+		BlockThreshold: cfg.FineBlockThreshold,
+	}
+	lostItems := &services.LostItems{
+		Loans:           stores.Loans,
+		Copies:          stores.Copies,
+		HoldQueue:       holdQueue,
+		Fines:           fineLedger,
+		AuditLogger:     auditLogger,
+		ReplacementCost: cfg.ReplacementCost,
+	}
+
+	// authed needs any valid token; staff and admin additionally need a role
+	authed := r.PathPrefix("/").Subrouter()
+	authed.Use(middleware.JWTAuthMiddleware)
+
+	staff := authed.NewRoute().Subrouter()
+	staff.Use(middleware.RequireRole(models.RoleAdmin, models.RoleLibrarian))
+
+	admin := authed.NewRoute().Subrouter()
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+
+	authHandler := &handlers.AuthHandler{Users: stores.Users, AuditLogger: auditLogger}
+	r.HandleFunc("/login", authHandler.Login).Methods("POST")
+	admin.HandleFunc("/users", authHandler.CreateUser).Methods("POST")
+
+	withdrawal := &services.Withdrawal{
+		Books:       stores.Books,
+		Copies:      stores.Copies,
+		Loans:       stores.Loans,
+		Holds:       stores.Holds,
+		AuditLogger: auditLogger,
+	}
+
+	bookHandler := handlers.NewBookHandler(stores.Books, stores.Copies, auditLogger)
+	bookHandler.Withdrawal = withdrawal
+	if metadata != nil {
+		bookHandler.Enricher = &services.Enricher{Books: stores.Books, Provider: metadata, AuditLogger: auditLogger}
+	}
+	bookHandler.Suggester = &services.Suggester{Suggestions: stores.Suggestions, Copies: stores.Copies, Loans: stores.Loans}
+	bookHandler.Availability = &services.AvailabilityEstimator{
+		Copies:   stores.Copies,
+		Loans:    stores.Loans,
+		Holds:    stores.Holds,
+		LoanDays: cfg.StandardMembersRenewalDays,
+	}
+
+	staff.HandleFunc("/books", bookHandler.AddBook).Methods("POST")
+	staff.HandleFunc("/books/import", bookHandler.ImportBooks).Methods("POST")
+	staff.HandleFunc("/books/export", bookHandler.ExportBooks).Methods("GET")
+	authed.HandleFunc("/books", bookHandler.GetBooks).Methods("GET")
+	authed.HandleFunc("/books/search", bookHandler.SearchBooks).Methods("GET")
+	authed.HandleFunc("/books/suggest", bookHandler.SuggestBooks).Methods("GET")
+	authed.HandleFunc("/books/{isbn}", bookHandler.GetBook).Methods("GET")
+	authed.HandleFunc("/books/{isbn}/availability", bookHandler.GetAvailability).Methods("GET")
+	staff.HandleFunc("/books/{isbn}", bookHandler.UpdateBook).Methods("PUT")
+	admin.HandleFunc("/books/{isbn}", bookHandler.DeleteBook).Methods("DELETE")
+
+	copyHandler := handlers.CopyHandler{
+		Store:       stores.Copies,
+		AuditLogger: auditLogger,
+		Withdrawal:  withdrawal,
+		LostItems:   lostItems,
+	}
+
+	staff.HandleFunc("/copies", copyHandler.AddCopy).Methods("POST")
+	authed.HandleFunc("/copies", copyHandler.GetCopies).Methods("GET")
+	staff.HandleFunc("/copies/{barcode}", copyHandler.UpdateCopy).Methods("PUT")
+	staff.HandleFunc("/copies/{barcode}/history", copyHandler.GetHistory).Methods("GET")
+	admin.HandleFunc("/copies/{barcode}", copyHandler.DeleteCopy).Methods("DELETE")
+
+	memberHandler := handlers.NewMemberHandler(stores.Members, auditLogger)
+	memberHandler.Loans = stores.Loans
+	memberHandler.Holds = stores.Holds
+	memberHandler.HoldQueue = holdQueue
+	memberHandler.Fines = fineLedger
+	memberHandler.Membership = &services.Membership{
+		Members:     stores.Members,
+		Loans:       stores.Loans,
+		Holds:       stores.Holds,
+		HoldQueue:   holdQueue,
+		Fines:       fineLedger,
+		AuditLogger: auditLogger,
+		Terms: map[models.MembershipTier]models.MembershipTerm{
+			models.TierStandard: {Months: cfg.StandardMembershipMonths, Fee: cfg.StandardMembershipFee},
+			models.TierPremium:  {Months: cfg.PremiumMembershipMonths, Fee: cfg.PremiumMembershipFee},
+		},
+		NoticeDays: cfg.MembershipNoticeDays,
+	}
+
+	staff.HandleFunc("/members", memberHandler.RegisterMember).Methods("POST")
+	staff.HandleFunc("/members", memberHandler.SearchMembers).Methods("GET")
+	authed.HandleFunc("/members/{id}", memberHandler.GetMember).Methods("GET")
+	authed.HandleFunc("/members/{id}/loans", memberHandler.GetMemberLoans).Methods("GET")
+	authed.HandleFunc("/members/{id}/holds", memberHandler.GetMemberHolds).Methods("GET")
+	authed.HandleFunc("/members/{id}/fines", memberHandler.GetMemberFines).Methods("GET")
+	staff.HandleFunc("/members/{id}", memberHandler.UpdateMember).Methods("PUT")
+	staff.HandleFunc("/members/{id}/deactivate", memberHandler.DeactivateMember).Methods("PATCH")
+	staff.HandleFunc("/members/{id}/suspend", memberHandler.SuspendMember).Methods("PATCH")
+	staff.HandleFunc("/members/{id}/reactivate", memberHandler.ReactivateMember).Methods("PATCH")
+	authed.HandleFunc("/members/{id}/renew", memberHandler.RenewMembership).Methods("POST")
+
+	loanHandler := &handlers.LoanHandler{
+		MemberStore: stores.Members,
+		CopyStore:   stores.Copies,
+		LoanStore:   stores.Loans,
+		HoldStore:   stores.Holds,
+		HoldQueue:   holdQueue,
+		Fines:       fineLedger,
+		LostItems:   lostItems,
+		AuditLogger: auditLogger,
+		Config: struct {
+			PremiumMemberRenewalDays  int
+			StandardMemberRenewalDays int
+		}{PremiumMemberRenewalDays: cfg.PremiumMembersRenewalDays, StandardMemberRenewalDays: cfg.StandardMembersRenewalDays},
+	}
+
+	staff.HandleFunc("/checkout", loanHandler.CheckOut).Methods("POST")
+	staff.HandleFunc("/checkin", loanHandler.CheckIn).Methods("POST")
+	authed.HandleFunc("/loan/renew", loanHandler.RenewLoan).Methods("POST")
+	staff.HandleFunc("/loans/overdue", loanHandler.GetOverdueLoans).Methods("GET")
+	staff.HandleFunc("/loans/{id}/declare", loanHandler.DeclareLoan).Methods("POST")
+
+	reservationHandler := &handlers.ReservationHandler{
+		HoldStore:   stores.Holds,
+		BookStore:   stores.Books,
+		CopyStore:   stores.Copies,
+		MemberStore: stores.Members,
+		HoldQueue:   holdQueue,
+		AuditLogger: auditLogger,
+	}
+
+	authed.HandleFunc("/holds", reservationHandler.ListHolds).Methods("GET")
+	authed.HandleFunc("/holds/place", reservationHandler.PlaceHold).Methods("POST")
+	admin.HandleFunc("/holds/{id}", reservationHandler.CancelHold).Methods("DELETE")
+
+	fineHandler := &handlers.FineHandler{Store: stores.Fines, Ledger: fineLedger}
+
+	authed.HandleFunc("/fines", fineHandler.GetFines).Methods("GET")
+	staff.HandleFunc("/fines/{id}/pay", fineHandler.PayFine).Methods("POST")
+	staff.HandleFunc("/fines/{id}/waive", fineHandler.WaiveFine).Methods("POST")
+
+	metricsHandler := handlers.MetricsHandler{
+		CopyStore:   stores.Copies,
+		MemberStore: stores.Members,
+		LoanStore:   stores.Loans,
+		FineStore:   stores.Fines,
+		Config:      struct{ FineRate float64 }{FineRate: cfg.FineRate},
+	}
+
+	admin.HandleFunc("/admin/metrics", metricsHandler.GetMetrics).Methods("GET")
+
+	daemonHandler := &handlers.DaemonHandler{Supervisor: daemons}
+	admin.HandleFunc("/admin/daemons", daemonHandler.GetStatus).Methods("GET")
+
+	return r
+}
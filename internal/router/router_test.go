@@ -0,0 +1,218 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"open-library-explorer/configs"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/router"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+func TestRouter_MemoryBackedFlow(t *testing.T) {
+	utils.InitJwtSecret("test-secret")
+	cfg := configs.Config{
+		PremiumMembersRenewalDays:  14,
+		StandardMembersRenewalDays: 7,
+	}
+	stores := store.NewMemoryStores()
+	services.EnsureAdmin(context.Background(), stores.Users, "admin", "password")
+	r := router.New(cfg, stores, nil, nil)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var reqBytes []byte
+		if body != nil {
+			reqBytes, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(reqBytes))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/login", "", map[string]string{"username": "admin", "password": "password"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: expected OK, got %d", w.Code)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(w.Body).Decode(&login)
+
+	if w := do(http.MethodPost, "/books", login.Token, models.Book{ISBN: "9780140449136", Title: "Crime and Punishment"}); w.Code != http.StatusCreated {
+		t.Fatalf("add book: expected Created, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/copies", login.Token, models.Copy{ISBN: "9780140449136", Barcode: "C-1", Status: models.StatusAvailable}); w.Code != http.StatusCreated {
+		t.Fatalf("add copy: expected Created, got %d", w.Code)
+	}
+
+	w = do(http.MethodPost, "/members", login.Token, models.Member{Name: "Jane Doe", Email: "jane@example.com", Tier: models.TierStandard})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register member: expected Created, got %d", w.Code)
+	}
+	var member models.Member
+	json.NewDecoder(w.Body).Decode(&member)
+
+	if w := do(http.MethodPost, "/checkout", login.Token, map[string]string{"member_id": member.ID.Hex(), "copy_barcode": "C-1"}); w.Code != http.StatusOK {
+		t.Fatalf("checkout: expected OK, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/checkout", login.Token, map[string]string{"member_id": member.ID.Hex(), "copy_barcode": "C-1"}); w.Code != http.StatusConflict {
+		t.Fatalf("second checkout: expected Conflict, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/checkin", login.Token, map[string]string{"copy_barcode": "C-1"}); w.Code != http.StatusOK {
+		t.Fatalf("checkin: expected OK, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/books/search?status=AVAILABLE", login.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("search: expected OK, got %d", w.Code)
+	}
+}
+
+func TestRouter_RoleAccess(t *testing.T) {
+	utils.InitJwtSecret("test-secret")
+	stores := store.NewMemoryStores()
+	services.EnsureAdmin(context.Background(), stores.Users, "admin", "password")
+	r := router.New(configs.Config{}, stores, nil, nil)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var reqBytes []byte
+		if body != nil {
+			reqBytes, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(reqBytes))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username, password string) string {
+		w := do(http.MethodPost, "/login", "", map[string]string{"username": username, "password": password})
+		if w.Code != http.StatusOK {
+			t.Fatalf("login %s: expected OK, got %d", username, w.Code)
+		}
+		var resp struct {
+			Token string `json:"token"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp.Token
+	}
+
+	adminToken := login("admin", "password")
+
+	w := do(http.MethodPost, "/members", adminToken, models.Member{Name: "Jane Doe", Email: "jane@example.com", Tier: models.TierStandard})
+	var member models.Member
+	json.NewDecoder(w.Body).Decode(&member)
+
+	users := []map[string]string{
+		{"username": "lib", "password": "secret", "role": "librarian"},
+		{"username": "jane", "password": "secret", "role": "patron", "member_id": member.ID.Hex()},
+	}
+	for _, u := range users {
+		if w := do(http.MethodPost, "/users", adminToken, u); w.Code != http.StatusCreated {
+			t.Fatalf("create user %s: expected Created, got %d: %s", u["username"], w.Code, w.Body)
+		}
+	}
+	librarianToken := login("lib", "secret")
+	patronToken := login("jane", "secret")
+
+	if w := do(http.MethodPost, "/users", librarianToken, users[0]); w.Code != http.StatusForbidden {
+		t.Errorf("librarian creating user: expected Forbidden, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/books", librarianToken, models.Book{ISBN: "9780140449136", Title: "Crime and Punishment"}); w.Code != http.StatusCreated {
+		t.Errorf("librarian adding book: expected Created, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/books/9780140449136", librarianToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("librarian deleting book: expected Forbidden, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/books", patronToken, models.Book{ISBN: "9780140449137", Title: "The Idiot"}); w.Code != http.StatusForbidden {
+		t.Errorf("patron adding book: expected Forbidden, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/books/9780140449136", patronToken, nil); w.Code != http.StatusOK {
+		t.Errorf("patron reading book: expected OK, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/metrics", patronToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("patron reading metrics: expected Forbidden, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/fines", patronToken, nil); w.Code != http.StatusOK {
+		t.Errorf("patron reading own fines: expected OK, got %d", w.Code)
+	}
+	other := "0123456789abcdef01234567"
+	if w := do(http.MethodGet, "/fines?member_id="+other, patronToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("patron reading other fines: expected Forbidden, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/fines?member_id="+other, librarianToken, nil); w.Code != http.StatusOK {
+		t.Errorf("librarian reading fines: expected OK, got %d", w.Code)
+	}
+}
+
+func TestRouter_AuditActor(t *testing.T) {
+	utils.InitJwtSecret("test-secret")
+	stores := store.NewMemoryStores()
+	services.EnsureAdmin(context.Background(), stores.Users, "admin", "password")
+	r := router.New(configs.Config{}, stores, nil, nil)
+
+	do := func(method, path, token string, body any) *httptest.ResponseRecorder {
+		var reqBytes []byte
+		if body != nil {
+			reqBytes, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(reqBytes))
+		req.Header.Set("X-Request-ID", "req-42")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/copies"},
+		{http.MethodPost, "/members"},
+		{http.MethodPost, "/checkout"},
+		{http.MethodPost, "/checkin"},
+		{http.MethodPost, "/loan/renew"},
+		{http.MethodPost, "/holds/place"},
+		{http.MethodGet, "/admin/metrics"},
+	} {
+		if w := do(route.method, route.path, "", nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without token: expected Unauthorized, got %d", route.method, route.path, w.Code)
+		}
+	}
+
+	w := do(http.MethodPost, "/login", "", map[string]string{"username": "admin", "password": "password"})
+	var login struct {
+		Token string `json:"token"`
+	}
+	json.NewDecoder(w.Body).Decode(&login)
+	admin, _ := stores.Users.GetByUsername(context.Background(), "admin")
+
+	w = do(http.MethodPost, "/books", login.Token, models.Book{ISBN: "9780140449136", Title: "Crime and Punishment"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add book: expected Created, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("expected request ID to be echoed, got %q", got)
+	}
+
+	logs, _ := stores.Audit.FindUnexported(context.Background(), 0)
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(logs))
+	}
+	entry := logs[0]
+	if entry.PerformedBy != admin.ID.Hex() || entry.RequestID != "req-42" || entry.ClientIP != "203.0.113.7" {
+		t.Errorf("unexpected audit context: by=%q request=%q ip=%q", entry.PerformedBy, entry.RequestID, entry.ClientIP)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// DefaultLoanDays is used when AvailabilityEstimator.LoanDays is not set. It
+// matches the loan period of members without a known tier.
+const DefaultLoanDays = 7
+
+// Availability summarizes whether a book is on the shelf. NextDue is the
+// soonest due date of its copies on loan and EstimatedWaitDays how long a
+// hold placed now would likely wait; both are omitted when unknown, the
+// latter because no copy circulates.
+type Availability struct {
+	ISBN              string                    `json:"isbn"`
+	Copies            int                       `json:"copies"`
+	ByStatus          map[models.CopyStatus]int `json:"by_status"`
+	NextDue           *time.Time                `json:"next_due,omitempty"`
+	HoldQueue         int                       `json:"hold_queue"`
+	EstimatedWaitDays *int                      `json:"estimated_wait_days,omitempty"`
+}
+
+// AvailabilityEstimator builds availability summaries from copies, their
+// loans and the holds waiting for them.
+type AvailabilityEstimator struct {
+	Copies   store.CopyStore
+	Loans    store.LoanStore
+	Holds    store.HoldStore
+	LoanDays int
+}
+
+func (e *AvailabilityEstimator) loanPeriod() time.Duration {
+	days := e.LoanDays
+	if days <= 0 {
+		days = DefaultLoanDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Availability summarizes the copies of isbn as of now.
+func (e *AvailabilityEstimator) Availability(ctx context.Context, isbn string, now time.Time) (Availability, error) {
+	a := Availability{ISBN: isbn, ByStatus: map[models.CopyStatus]int{}}
+	copies, err := e.Copies.Find(ctx, store.CopyFilter{ISBN: isbn})
+	if err != nil {
+		return a, err
+	}
+	a.Copies = len(copies)
+
+	var onLoan []string
+	barcodes := make([]string, 0, len(copies))
+	for _, copyObj := range copies {
+		a.ByStatus[copyObj.Status]++
+		barcodes = append(barcodes, copyObj.Barcode)
+		if copyObj.Status == models.StatusOnLoan {
+			onLoan = append(onLoan, copyObj.Barcode)
+		}
+	}
+
+	dueDates := map[string]time.Time{}
+	if len(onLoan) > 0 {
+		loans, err := e.Loans.Find(ctx, store.LoanFilter{CopyBarcodes: onLoan, Returned: store.Bool(false)})
+		if err != nil {
+			return a, err
+		}
+		for _, loan := range loans {
+			dueDates[loan.CopyBarcode] = loan.DueDate
+			if a.NextDue == nil || loan.DueDate.Before(*a.NextDue) {
+				due := loan.DueDate
+				a.NextDue = &due
+			}
+		}
+	}
+
+	waiting, err := e.waitingHolds(ctx, isbn, barcodes)
+	if err != nil {
+		return a, err
+	}
+	a.HoldQueue = waiting
+
+	// Each circulating copy becomes free at some time; a reserved copy once
+	// its holder has borrowed it for a loan period.
+	var free []time.Time
+	for _, copyObj := range copies {
+		switch copyObj.Status {
+		case models.StatusAvailable:
+			free = append(free, now)
+		case models.StatusOnLoan:
+			due, ok := dueDates[copyObj.Barcode]
+			if !ok || due.Before(now) {
+				due = now
+			}
+			free = append(free, due)
+		case models.StatusReserved:
+			free = append(free, now.Add(e.loanPeriod()))
+		}
+	}
+	if len(free) > 0 {
+		days := int(math.Ceil(e.turnOf(free, waiting).Sub(now).Hours() / 24))
+		a.EstimatedWaitDays = &days
+	}
+	return a, nil
+}
+
+// waitingHolds counts the open holds on isbn or its copies that have not been
+// offered a copy yet. A title hold assigned a copy matches both and is
+// counted once.
+func (e *AvailabilityEstimator) waitingHolds(ctx context.Context, isbn string, barcodes []string) (int, error) {
+	titleHolds, err := e.Holds.Find(ctx, store.HoldFilter{ISBN: isbn, Open: true, Notified: store.Bool(false)})
+	if err != nil {
+		return 0, err
+	}
+	copyHolds, err := e.Holds.Find(ctx, store.HoldFilter{CopyBarcodes: barcodes, Open: true, Notified: store.Bool(false)})
+	if err != nil {
+		return 0, err
+	}
+	waiting := map[primitive.ObjectID]bool{}
+	for _, hold := range append(titleHolds, copyHolds...) {
+		waiting[hold.ID] = true
+	}
+	return len(waiting), nil
+}
+
+// turnOf hands copies, free at the given times, to ahead holds in turn, each
+// keeping its copy for a loan period, and returns when the next hold would
+// get one. Holds on a particular copy are treated like title holds.
+func (e *AvailabilityEstimator) turnOf(free []time.Time, ahead int) time.Time {
+	free = append([]time.Time(nil), free...)
+	for ; ahead > 0; ahead-- {
+		sort.Slice(free, func(i, j int) bool { return free[i].Before(free[j]) })
+		free[0] = free[0].Add(e.loanPeriod())
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i].Before(free[j]) })
+	return free[0]
+}
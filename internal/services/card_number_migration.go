@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// CardNumberMigrationReport summarises a run of AssignCardNumbers.
+type CardNumberMigrationReport struct {
+	Members  int `json:"members"`  // members examined
+	Assigned int `json:"assigned"` // members that had no card number yet
+}
+
+// AssignCardNumbers gives every member registered before card numbers
+// existed a newly generated one. Running it again is a no-op.
+func AssignCardNumbers(ctx context.Context, members store.MemberStore) (CardNumberMigrationReport, error) {
+	var report CardNumberMigrationReport
+
+	// IDs do not change, so updating while paging by ID is safe
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		batch, next, err := members.FindPage(ctx, store.MemberFilter{}, page)
+		if err != nil {
+			return report, err
+		}
+		for _, member := range batch {
+			report.Members++
+			if member.CardNumber != "" {
+				continue
+			}
+			for attempt := 0; attempt < models.CardNumberAttempts; attempt++ {
+				err = members.Update(ctx, member.ID, map[string]interface{}{"card_number": models.NewCardNumber()})
+				if !errors.Is(err, store.ErrDuplicate) {
+					break
+				}
+			}
+			if err != nil {
+				return report, err
+			}
+			report.Assigned++
+		}
+		if next == "" {
+			return report, nil
+		}
+		page.Cursor = next
+	}
+}
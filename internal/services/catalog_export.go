@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+
+	"open-library-explorer/internal/catalog"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// CatalogExporter streams the whole catalog to a catalog.Writer a page at a
+// time, so memory use does not grow with the size of the catalog.
+type CatalogExporter struct {
+	Books  store.BookStore
+	Copies store.CopyStore
+}
+
+// Export writes every book in ISBN order, with its copies when withCopies is
+// set, and returns how many books were written. It does not close w.
+func (e *CatalogExporter) Export(ctx context.Context, w catalog.Writer, withCopies bool) (int, error) {
+	written := 0
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		books, next, err := e.Books.FindPage(ctx, store.BookFilter{}, page)
+		if err != nil {
+			return written, err
+		}
+
+		var copies map[string][]models.Copy
+		if withCopies && len(books) > 0 {
+			if copies, err = e.copiesOf(ctx, books); err != nil {
+				return written, err
+			}
+		}
+
+		for _, book := range books {
+			if err := w.Write(catalog.ExportBook{Book: book, Copies: copies[book.ISBN]}); err != nil {
+				return written, err
+			}
+			written++
+		}
+
+		if next == "" {
+			return written, nil
+		}
+		page.Cursor = next
+	}
+}
+
+func (e *CatalogExporter) copiesOf(ctx context.Context, books []models.Book) (map[string][]models.Copy, error) {
+	isbns := make([]string, len(books))
+	for i, book := range books {
+		isbns[i] = book.ISBN
+	}
+	found, err := e.Copies.Find(ctx, store.CopyFilter{ISBNs: isbns})
+	if err != nil {
+		return nil, err
+	}
+	copies := map[string][]models.Copy{}
+	for _, copyObj := range found {
+		copies[copyObj.ISBN] = append(copies[copyObj.ISBN], copyObj)
+	}
+	return copies, nil
+}
@@ -0,0 +1,50 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"open-library-explorer/internal/catalog"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+type collectWriter struct {
+	books []catalog.ExportBook
+}
+
+func (c *collectWriter) Write(book catalog.ExportBook) error {
+	c.books = append(c.books, book)
+	return nil
+}
+
+func (c *collectWriter) Close() error { return nil }
+
+func TestCatalogExporter_ExportPagesThroughCatalog(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	total := store.MaxPageLimit + 3
+	for i := 0; i < total; i++ {
+		stores.Books.Insert(ctx, &models.Book{ISBN: fmt.Sprintf("%04d", i), Title: "Book"})
+	}
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: "0000", Barcode: "A", Status: models.StatusAvailable})
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: "0000", Barcode: "B", Status: models.StatusLost})
+
+	exporter := services.CatalogExporter{Books: stores.Books, Copies: stores.Copies}
+	w := &collectWriter{}
+	written, err := exporter.Export(ctx, w, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != total || len(w.books) != total {
+		t.Fatalf("expected %d books, wrote %d", total, written)
+	}
+	if w.books[total-1].ISBN != fmt.Sprintf("%04d", total-1) {
+		t.Errorf("expected ISBN order, last was %s", w.books[total-1].ISBN)
+	}
+	if len(w.books[0].Copies) != 2 || len(w.books[1].Copies) != 0 {
+		t.Errorf("unexpected copies %+v / %+v", w.books[0].Copies, w.books[1].Copies)
+	}
+}
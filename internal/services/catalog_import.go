@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"open-library-explorer/internal/catalog"
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportRejected = "rejected"
+)
+
+// maxBarcodeAttempts bounds the search for a free generated barcode.
+const maxBarcodeAttempts = 100
+
+// ImportResult is the outcome of one record. Index is its 1-based position
+// in the import file.
+type ImportResult struct {
+	Index    int      `json:"index"`
+	ISBN     string   `json:"isbn,omitempty"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+	Barcodes []string `json:"barcodes,omitempty"`
+}
+
+type ImportReport struct {
+	Created       int            `json:"created"`
+	Updated       int            `json:"updated"`
+	Rejected      int            `json:"rejected"`
+	CopiesCreated int            `json:"copies_created"`
+	Records       []ImportResult `json:"records"`
+}
+
+// CatalogImporter upserts books by ISBN from parsed import records.
+type CatalogImporter struct {
+	Books       store.BookStore
+	Copies      store.CopyStore
+	AuditLogger utils.Logger
+}
+
+// Import upserts every record and, for each accepted record, creates copies
+// AVAILABLE copies with generated barcodes. A record that asks for its own
+// number of copies overrides copies. One bad record never stops the rest.
+func (i *CatalogImporter) Import(ctx context.Context, records []catalog.Record, copies int) ImportReport {
+	report := ImportReport{Records: make([]ImportResult, 0, len(records))}
+
+	for n, rec := range records {
+		result := ImportResult{Index: n + 1, ISBN: rec.Book.ISBN}
+
+		isbn, status, err := i.upsert(ctx, rec)
+		if err != nil {
+			result.Status = ImportRejected
+			result.Error = err.Error()
+			report.Rejected++
+			report.Records = append(report.Records, result)
+			continue
+		}
+		result.ISBN = isbn
+		result.Status = status
+		if status == ImportCreated {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+
+		want := copies
+		if rec.Copies >= 0 {
+			want = rec.Copies
+		}
+		for c := 0; c < want; c++ {
+			barcode, err := i.addCopy(ctx, isbn)
+			if err != nil {
+				result.Error = "failed to create copies: " + err.Error()
+				break
+			}
+			result.Barcodes = append(result.Barcodes, barcode)
+			report.CopiesCreated++
+		}
+		report.Records = append(report.Records, result)
+	}
+
+	i.AuditLogger.Log(ctx, models.BookEntity, constants.Import, map[string]int{
+		"created":        report.Created,
+		"updated":        report.Updated,
+		"rejected":       report.Rejected,
+		"copies_created": report.CopiesCreated,
+	})
+	return report
+}
+
+// upsert stores the book of rec under its normalized ISBN and returns that
+// ISBN along with whether the book was created or updated.
+func (i *CatalogImporter) upsert(ctx context.Context, rec catalog.Record) (string, string, error) {
+	if rec.Err != nil {
+		return "", "", rec.Err
+	}
+	book := rec.Book
+	if book.ISBN == "" {
+		return "", "", errors.New("missing ISBN")
+	}
+	isbn, err := models.NormalizeISBN(book.ISBN)
+	if err != nil {
+		return "", "", err
+	}
+	book.ISBN = isbn
+	if book.Title == "" {
+		return "", "", errors.New("missing title")
+	}
+	if err := book.Validate(); err != nil {
+		return "", "", err
+	}
+
+	_, err = i.Books.Get(ctx, book.ISBN)
+	if errors.Is(err, store.ErrNotFound) {
+		err = i.Books.Insert(ctx, &book)
+		if err == nil {
+			return isbn, ImportCreated, nil
+		}
+		// inserted concurrently by someone else; update it instead
+		if !errors.Is(err, store.ErrDuplicate) {
+			return "", "", err
+		}
+	} else if err != nil {
+		return "", "", err
+	}
+
+	// only overwrite what the record actually provides
+	if _, err := i.Books.Update(ctx, book.ISBN, providedFields(book)); err != nil {
+		return "", "", err
+	}
+	return isbn, ImportUpdated, nil
+}
+
+// providedFields returns the descriptive fields of book that have a value,
+// as a $set. The ISBN and tags are left out.
+func providedFields(book models.Book) map[string]interface{} {
+	fields := map[string]interface{}{}
+	set := func(name string, value interface{}, ok bool) {
+		if ok {
+			fields[name] = value
+		}
+	}
+	set("title", book.Title, book.Title != "")
+	set("contributors", book.Contributors, len(book.Contributors) > 0)
+	set("publisher", book.Publisher, book.Publisher != "")
+	set("subjects", book.Subjects, len(book.Subjects) > 0)
+	set("language", book.Language, book.Language != "")
+	set("edition", book.Edition, book.Edition != "")
+	set("series", book.Series, book.Series != "")
+	set("series_number", book.SeriesNumber, book.SeriesNumber != 0)
+	set("published_year", book.PublishedYear, book.PublishedYear != 0)
+	set("page_count", book.PageCount, book.PageCount != 0)
+	set("cover_id", book.CoverID, book.CoverID != 0)
+	set("description", book.Description, book.Description != "")
+	return fields
+}
+
+// addCopy creates an AVAILABLE copy with the first free barcode of the form
+// <isbn>-<n>.
+func (i *CatalogImporter) addCopy(ctx context.Context, isbn string) (string, error) {
+	existing, err := i.Copies.Count(ctx, store.CopyFilter{ISBN: isbn})
+	if err != nil {
+		return "", err
+	}
+	for n := existing + 1; n <= existing+maxBarcodeAttempts; n++ {
+		copyObj := models.Copy{
+			ISBN:      isbn,
+			Barcode:   fmt.Sprintf("%s-%d", isbn, n),
+			Status:    models.StatusAvailable,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		err := i.Copies.Insert(ctx, &copyObj)
+		if err == nil {
+			i.AuditLogger.Log(ctx, models.CopyEntity, constants.Create, copyObj)
+			return copyObj.Barcode, nil
+		}
+		if !errors.Is(err, store.ErrDuplicate) {
+			return "", err
+		}
+	}
+	return "", errors.New("no free barcode found")
+}
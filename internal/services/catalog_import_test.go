@@ -0,0 +1,59 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"open-library-explorer/internal/catalog"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func TestCatalogImporter_Import(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	stores.Books.Insert(ctx, &models.Book{ISBN: "9780140449136", Title: "Old title", Publisher: "Kept Publisher"})
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: "9783161484100", Barcode: "9783161484100-2", Status: models.StatusAvailable})
+
+	importer := services.CatalogImporter{Books: stores.Books, Copies: stores.Copies}
+	report := importer.Import(ctx, []catalog.Record{
+		{Book: models.Book{ISBN: "0-14-044913-2", Title: "New title", PublishedYear: 1999}, Copies: -1},
+		{Book: models.Book{ISBN: "978-3-16-148410-0", Title: "Fresh"}, Copies: 2},
+		{Book: models.Book{ISBN: "9780140449137", Title: "Bad checksum"}, Copies: -1},
+		{Book: models.Book{Title: "No ISBN"}, Copies: -1},
+		{Copies: -1, Err: errors.New("unreadable")},
+	}, 1)
+
+	if report.Created != 1 || report.Updated != 1 || report.Rejected != 3 || report.CopiesCreated != 3 {
+		t.Fatalf("unexpected totals %+v", report)
+	}
+	statuses := []string{services.ImportUpdated, services.ImportCreated, services.ImportRejected, services.ImportRejected, services.ImportRejected}
+	for i, want := range statuses {
+		if got := report.Records[i]; got.Status != want || got.Index != i+1 {
+			t.Errorf("record %d: expected %s, got %+v", i+1, want, got)
+		}
+	}
+	if report.Records[4].Error != "unreadable" {
+		t.Errorf("expected the parse error in the report, got %q", report.Records[4].Error)
+	}
+
+	updated, _ := stores.Books.Get(ctx, "9780140449136")
+	if updated.Title != "New title" || updated.Publisher != "Kept Publisher" || updated.PublishedYear != 1999 {
+		t.Errorf("expected a partial update, got %+v", updated)
+	}
+
+	if report.Records[0].ISBN != "9780140449136" {
+		t.Errorf("expected the normalized ISBN in the report, got %q", report.Records[0].ISBN)
+	}
+
+	// 9783161484100-2 already exists, so generated barcodes skip it
+	barcodes := report.Records[1].Barcodes
+	if len(barcodes) != 2 || barcodes[0] != "9783161484100-3" || barcodes[1] != "9783161484100-4" {
+		t.Errorf("unexpected barcodes %v", barcodes)
+	}
+	if n, _ := stores.Copies.Count(ctx, store.CopyFilter{ISBN: "9780140449136", Status: models.StatusAvailable}); n != 1 {
+		t.Errorf("expected 1 copy of 9780140449136, got %d", n)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// ContributorMigrationReport summarises a run of MigrateContributors.
+type ContributorMigrationReport struct {
+	Books    int `json:"books"`    // books examined
+	Migrated int `json:"migrated"` // books that still had single-author fields
+}
+
+// MigrateContributors converts books stored with the single author and
+// subject strings, or the authors list filled in by enrichment, to
+// contributors and subjects, then removes the old fields. Values already
+// present in the new fields win. Running it again is a no-op.
+func MigrateContributors(ctx context.Context, books store.BookStore) (ContributorMigrationReport, error) {
+	var report ContributorMigrationReport
+
+	// ISBNs do not change, so updating while paging by ISBN is safe
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		batch, next, err := books.FindPage(ctx, store.BookFilter{IncludeWithdrawn: true}, page)
+		if err != nil {
+			return report, err
+		}
+		for _, book := range batch {
+			report.Books++
+			fields := legacyFields(book)
+			if len(fields) == 0 {
+				continue
+			}
+			if _, err := books.Update(ctx, book.ISBN, fields); err != nil {
+				return report, err
+			}
+			report.Migrated++
+		}
+		if next == "" {
+			return report, nil
+		}
+		page.Cursor = next
+	}
+}
+
+// legacyFields returns the update that moves the old fields of book to the
+// new ones, or nil when there is nothing left to migrate.
+func legacyFields(book models.Book) map[string]interface{} {
+	if book.LegacyAuthor == "" && len(book.LegacyAuthors) == 0 && book.LegacySubject == "" {
+		return nil
+	}
+	fields := map[string]interface{}{"author": nil, "authors": nil, "subject": nil}
+
+	if len(book.Contributors) == 0 {
+		names := book.LegacyAuthors
+		if len(names) == 0 && book.LegacyAuthor != "" {
+			names = []string{book.LegacyAuthor}
+		}
+		var contributors []models.Contributor
+		for _, name := range names {
+			contributors = append(contributors, models.Contributor{Name: name, Role: models.ContributorAuthor})
+		}
+		if contributors != nil {
+			fields["contributors"] = contributors
+		}
+	}
+
+	if len(book.Subjects) == 0 {
+		var subjects []string
+		for _, subject := range strings.Split(book.LegacySubject, ";") {
+			if subject = strings.TrimSpace(subject); subject != "" {
+				subjects = append(subjects, subject)
+			}
+		}
+		if subjects != nil {
+			fields["subjects"] = subjects
+		}
+	}
+	return fields
+}
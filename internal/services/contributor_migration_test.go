@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func TestMigrateContributors(t *testing.T) {
+	ctx := context.Background()
+	books := store.NewMemoryBookStore()
+	books.Insert(ctx, &models.Book{ISBN: "9780140449136", Title: "Crime and Punishment",
+		LegacyAuthor: "Fyodor Dostoyevsky", LegacySubject: "Murder; Psychological fiction"})
+	books.Insert(ctx, &models.Book{ISBN: "9780140268867", Title: "The Odyssey",
+		LegacyAuthor: "Homer", LegacyAuthors: []string{"Homer", "Robert Fagles"}})
+	current := models.Book{ISBN: "9783161484100", Title: "Current",
+		Contributors: []models.Contributor{{Name: "A", Role: models.ContributorEditor}}, Subjects: []string{"S"}}
+	books.Insert(ctx, &current)
+
+	report, err := services.MigrateContributors(ctx, books)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Books != 3 || report.Migrated != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	crime, _ := books.Get(ctx, "9780140449136")
+	want := models.Book{ISBN: "9780140449136", Title: "Crime and Punishment",
+		Contributors: []models.Contributor{{Name: "Fyodor Dostoyevsky", Role: models.ContributorAuthor}},
+		Subjects:     []string{"Murder", "Psychological fiction"}}
+	if !reflect.DeepEqual(crime, want) {
+		t.Errorf("unexpected migrated book\n got %+v\nwant %+v", crime, want)
+	}
+
+	odyssey, _ := books.Get(ctx, "9780140268867")
+	if authors := odyssey.Authors(); len(authors) != 2 || authors[1] != "Robert Fagles" || odyssey.LegacyAuthor != "" {
+		t.Errorf("expected the authors list to win, got %+v", odyssey)
+	}
+
+	if got, _ := books.Get(ctx, "9783161484100"); !reflect.DeepEqual(got, current) {
+		t.Errorf("expected a current book to be left alone, got %+v", got)
+	}
+
+	if again, _ := services.MigrateContributors(ctx, books); again.Migrated != 0 {
+		t.Errorf("expected a second run to be a no-op, got %+v", again)
+	}
+}
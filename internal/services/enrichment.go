@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/enrichment"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// EnrichReport counts the outcome of a batch enrichment run.
+type EnrichReport struct {
+	Books     int      `json:"books"`
+	Enriched  int      `json:"enriched"`
+	Unchanged int      `json:"unchanged"`
+	NotFound  int      `json:"not_found"`
+	Failed    int      `json:"failed"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Enricher fills in the fields a book is missing from a metadata provider.
+// Fields that already have a value are never overwritten.
+type Enricher struct {
+	Books       store.BookStore
+	Provider    enrichment.Provider
+	AuditLogger utils.Logger
+}
+
+// EnrichBook looks the book up and stores whatever it was missing. It returns
+// the fields that were set, which is empty when the provider had nothing new,
+// and enrichment.ErrNotFound when the provider does not know the ISBN.
+func (e *Enricher) EnrichBook(ctx context.Context, isbn string) (map[string]interface{}, error) {
+	book, err := e.Books.Get(ctx, isbn)
+	if err != nil {
+		return nil, err
+	}
+	md, err := e.Provider.Lookup(ctx, isbn)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := missingFields(book, metadataBook(md))
+	if len(fields) == 0 {
+		return fields, nil
+	}
+	if _, err := e.Books.Update(ctx, isbn, fields); err != nil {
+		return nil, err
+	}
+
+	e.AuditLogger.Log(ctx, models.BookEntity, constants.Enrich, map[string]interface{}{
+		"isbn":   isbn,
+		"fields": fields,
+	})
+	return fields, nil
+}
+
+// EnrichAll runs EnrichBook over the whole catalog. A failure on one book is
+// recorded in the report and does not stop the run; only a failure to list
+// the catalog is returned as an error.
+func (e *Enricher) EnrichAll(ctx context.Context) (EnrichReport, error) {
+	var report EnrichReport
+	page := store.Page{Limit: store.MaxPageLimit, Sort: "isbn"}
+	for {
+		books, next, err := e.Books.FindPage(ctx, store.BookFilter{}, page)
+		if err != nil {
+			return report, err
+		}
+		for _, book := range books {
+			report.Books++
+			fields, err := e.EnrichBook(ctx, book.ISBN)
+			switch {
+			case errors.Is(err, enrichment.ErrNotFound):
+				report.NotFound++
+			case err != nil:
+				report.Failed++
+				report.Errors = append(report.Errors, book.ISBN+": "+err.Error())
+			case len(fields) == 0:
+				report.Unchanged++
+			default:
+				report.Enriched++
+			}
+		}
+		if next == "" {
+			return report, nil
+		}
+		page.Cursor = next
+	}
+}
+
+// metadataBook is md in the shape of a book, so it can be merged with
+// missingFields.
+func metadataBook(md enrichment.Metadata) models.Book {
+	book := models.Book{
+		Title:         md.Title,
+		Subjects:      md.Subjects,
+		PublishedYear: md.PublishYear,
+		CoverID:       md.CoverID,
+		PageCount:     md.PageCount,
+	}
+	for _, name := range md.Authors {
+		book.Contributors = append(book.Contributors, models.Contributor{Name: name, Role: models.ContributorAuthor})
+	}
+	if len(md.Publishers) > 0 {
+		book.Publisher = md.Publishers[0]
+	}
+	return book
+}
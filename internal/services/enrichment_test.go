@@ -0,0 +1,55 @@
+package services_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"open-library-explorer/internal/enrichment"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+type stubProvider map[string]enrichment.Metadata
+
+func (p stubProvider) Lookup(_ context.Context, isbn string) (enrichment.Metadata, error) {
+	md, ok := p[isbn]
+	if !ok {
+		return enrichment.Metadata{}, enrichment.ErrNotFound
+	}
+	return md, nil
+}
+
+func TestEnricher_EnrichAll(t *testing.T) {
+	ctx := context.Background()
+	books := store.NewMemoryBookStore()
+	books.Insert(ctx, &models.Book{ISBN: "9780140449136", Title: "Odyssey (Penguin)", PublishedYear: 1946})
+	books.Insert(ctx, &models.Book{ISBN: "9783161484100", Title: "Complete", Publisher: "P",
+		Contributors: []models.Contributor{{Name: "A", Role: models.ContributorAuthor}}, Subjects: []string{"S"}, PublishedYear: 2000, CoverID: 1, PageCount: 10})
+	books.Insert(ctx, &models.Book{ISBN: "9780306406157", Title: "Unknown"})
+
+	provider := stubProvider{
+		"9780140449136": {Title: "The Odyssey", Authors: []string{"Homer"}, Subjects: []string{"Epic", "Myth"},
+			Publishers: []string{"Penguin"}, PublishYear: 2003, CoverID: 12345, PageCount: 560},
+		"9783161484100": {Title: "Other", Authors: []string{"B"}, PageCount: 99},
+	}
+
+	enricher := services.Enricher{Books: books, Provider: provider}
+	report, err := enricher.EnrichAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Books != 3 || report.Enriched != 1 || report.Unchanged != 1 || report.NotFound != 1 || report.Failed != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	got, _ := books.Get(ctx, "9780140449136")
+	want := models.Book{ISBN: "9780140449136", Title: "Odyssey (Penguin)",
+		Contributors: []models.Contributor{{Name: "Homer", Role: models.ContributorAuthor}},
+		Publisher:    "Penguin", Subjects: []string{"Epic", "Myth"}, PublishedYear: 1946,
+		CoverID: 12345, PageCount: 560}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected only missing fields to be filled:\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+var (
+	ErrFineClosed    = errors.New("fine is already paid or waived")
+	ErrInvalidAmount = errors.New("amount must be positive and no more than the outstanding balance")
+)
+
+// FineLedger records fines against members and blocks members whose unpaid
+// balance exceeds BlockThreshold. A threshold of zero disables blocking.
+type FineLedger struct {
+	Fines          store.FineStore
+	Members        store.MemberStore
+	AuditLogger    utils.Logger
+	Rate           float64 // charged per day overdue
+	BlockThreshold float64
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// DaysLate is the number of whole days between due and returned.
+func DaysLate(due, returned time.Time) int {
+	days := int(returned.Sub(due).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// AssessOverdue charges the member of loan for every whole day it was
+// returned late. It returns nil when nothing is owed.
+func (l *FineLedger) AssessOverdue(ctx context.Context, loan models.Loan, returned time.Time) (*models.Fine, error) {
+	amount := roundCents(float64(DaysLate(loan.DueDate, returned)) * l.Rate)
+	if amount <= 0 {
+		return nil, nil
+	}
+	return l.charge(ctx, loan, "overdue", amount)
+}
+
+// ChargeReplacement charges the member of loan the replacement cost of a
+// copy that was lost or damaged; reason says which. It returns nil when the
+// cost is zero.
+func (l *FineLedger) ChargeReplacement(ctx context.Context, loan models.Loan, reason models.LoanOutcome, cost float64) (*models.Fine, error) {
+	amount := roundCents(cost)
+	if amount <= 0 {
+		return nil, nil
+	}
+	return l.charge(ctx, loan, string(reason), amount)
+}
+
+// ChargeFee charges the member a fee that belongs to no loan, such as a
+// membership renewal. Nothing is charged for a zero amount.
+func (l *FineLedger) ChargeFee(ctx context.Context, memberID primitive.ObjectID, reason string, amount float64) (*models.Fine, error) {
+	amount = roundCents(amount)
+	if amount <= 0 {
+		return nil, nil
+	}
+	return l.charge(ctx, models.Loan{MemberID: memberID}, reason, amount)
+}
+
+func (l *FineLedger) charge(ctx context.Context, loan models.Loan, reason string, amount float64) (*models.Fine, error) {
+	now := time.Now()
+	fine := models.Fine{
+		MemberID:    loan.MemberID,
+		LoanID:      loan.ID,
+		CopyBarcode: loan.CopyBarcode,
+		Reason:      reason,
+		Amount:      amount,
+		Payments:    []models.FinePayment{},
+		Status:      models.FineOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := l.Fines.Insert(ctx, &fine); err != nil {
+		return nil, err
+	}
+	l.AuditLogger.Log(ctx, models.FineEntity, constants.Create, fine)
+
+	return &fine, l.enforce(ctx, loan.MemberID)
+}
+
+// Balance is the total outstanding on the member's open fines.
+func (l *FineLedger) Balance(ctx context.Context, memberID primitive.ObjectID) (float64, error) {
+	fines, err := l.Fines.Find(ctx, store.FineFilter{MemberID: memberID, Status: models.FineOpen})
+	if err != nil {
+		return 0, err
+	}
+	var balance float64
+	for _, fine := range fines {
+		balance += fine.Outstanding()
+	}
+	return roundCents(balance), nil
+}
+
+// OverThreshold reports whether the member owes more than BlockThreshold.
+func (l *FineLedger) OverThreshold(ctx context.Context, memberID primitive.ObjectID) (bool, error) {
+	if l.BlockThreshold <= 0 {
+		return false, nil
+	}
+	balance, err := l.Balance(ctx, memberID)
+	return balance > l.BlockThreshold, err
+}
+
+// Pay records a partial or full payment. The fine is marked PAID once nothing
+// is outstanding. It fails with store.ErrConflict when the fine was paid,
+// waived or refunded concurrently.
+func (l *FineLedger) Pay(ctx context.Context, id primitive.ObjectID, amount float64) (models.Fine, error) {
+	fine, err := l.Fines.Get(ctx, id)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if fine.Status != models.FineOpen {
+		return fine, ErrFineClosed
+	}
+	amount = roundCents(amount)
+	if amount <= 0 || amount > roundCents(fine.Outstanding()) {
+		return fine, ErrInvalidAmount
+	}
+
+	// Only applied while nobody else paid or closed the fine since it was read
+	read := fine
+	now := time.Now()
+	payment := models.FinePayment{Amount: amount, PaidAt: now}
+	fine.Payments = append(fine.Payments, payment)
+	fine.AmountPaid = roundCents(fine.AmountPaid + amount)
+	if fine.Outstanding() <= 0 {
+		fine.Status = models.FinePaid
+	}
+	fine.UpdatedAt = now
+
+	if err := l.Fines.UpdateIf(ctx, read, bson.M{
+		"amount_paid": fine.AmountPaid,
+		"status":      fine.Status,
+		"updated_at":  now,
+	}, &payment); err != nil {
+		return fine, err
+	}
+	l.AuditLogger.Log(ctx, models.FineEntity, constants.Pay, bson.M{"fine_id": id, "amount": amount})
+
+	return fine, l.enforce(ctx, fine.MemberID)
+}
+
+// Waive forgives whatever is still outstanding on the fine. Like Pay it fails
+// with store.ErrConflict when the fine changed concurrently.
+func (l *FineLedger) Waive(ctx context.Context, id primitive.ObjectID, reason string) (models.Fine, error) {
+	fine, err := l.Fines.Get(ctx, id)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if fine.Status != models.FineOpen {
+		return fine, ErrFineClosed
+	}
+
+	read := fine
+	fine.Status = models.FineWaived
+	fine.WaiveReason = reason
+	fine.UpdatedAt = time.Now()
+
+	if err := l.Fines.UpdateIf(ctx, read, bson.M{
+		"status":       fine.Status,
+		"waive_reason": reason,
+		"updated_at":   fine.UpdatedAt,
+	}, nil); err != nil {
+		return fine, err
+	}
+	l.AuditLogger.Log(ctx, models.FineEntity, constants.Waive, bson.M{"fine_id": id, "reason": reason})
+
+	return fine, l.enforce(ctx, fine.MemberID)
+}
+
+// Refund cancels a fine that was charged in error, such as the replacement
+// cost of a copy that turned up again. Whatever was paid is recorded as
+// refunded and nothing more is owed.
+func (l *FineLedger) Refund(ctx context.Context, id primitive.ObjectID, reason string) (models.Fine, error) {
+	fine, err := l.Fines.Get(ctx, id)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if fine.Status != models.FineOpen && fine.Status != models.FinePaid {
+		return fine, ErrFineClosed
+	}
+
+	read := fine
+	fine.Status = models.FineRefunded
+	fine.Refunded = fine.AmountPaid
+	fine.RefundReason = reason
+	fine.UpdatedAt = time.Now()
+
+	if err := l.Fines.UpdateIf(ctx, read, bson.M{
+		"status":        fine.Status,
+		"refunded":      fine.Refunded,
+		"refund_reason": reason,
+		"updated_at":    fine.UpdatedAt,
+	}, nil); err != nil {
+		return fine, err
+	}
+	l.AuditLogger.Log(ctx, models.FineEntity, constants.Refund, bson.M{"fine_id": id, "amount": fine.Refunded, "reason": reason})
+
+	return fine, l.enforce(ctx, fine.MemberID)
+}
+
+// enforce blocks the member while their balance is over the threshold and
+// lifts a block it placed itself once the balance drops back under it.
+// Blocks placed for other reasons are left alone.
+func (l *FineLedger) enforce(ctx context.Context, memberID primitive.ObjectID) error {
+	member, err := l.Members.Get(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	over, err := l.OverThreshold(ctx, memberID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case over && !member.Blocked:
+		l.AuditLogger.Log(ctx, models.MemberEntity, constants.Block, memberID.Hex())
+		return l.Members.Update(ctx, memberID, bson.M{
+			"blocked":    true,
+			"blocked_by": models.BlockedByFines,
+			"updated_at": time.Now(),
+		})
+	case !over && member.Blocked && member.BlockedBy == models.BlockedByFines:
+		l.AuditLogger.Log(ctx, models.MemberEntity, constants.Unblock, memberID.Hex())
+		return l.Members.Update(ctx, memberID, bson.M{
+			"blocked":    false,
+			"blocked_by": "",
+			"updated_at": time.Now(),
+		})
+	}
+	return nil
+}
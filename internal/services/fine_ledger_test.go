@@ -0,0 +1,121 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func TestFineLedger(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	ledger := services.FineLedger{Fines: stores.Fines, Members: stores.Members, Rate: 1.5, BlockThreshold: 10}
+
+	member := models.Member{Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+
+	returned := time.Now()
+	onTime := models.Loan{MemberID: member.ID, DueDate: returned.Add(time.Hour)}
+	if fine, err := ledger.AssessOverdue(ctx, onTime, returned); fine != nil || err != nil {
+		t.Fatalf("expected no fine for an on-time return, got %+v, %v", fine, err)
+	}
+
+	late := models.Loan{MemberID: member.ID, CopyBarcode: "123456", DueDate: returned.AddDate(0, 0, -8)}
+	fine, err := ledger.AssessOverdue(ctx, late, returned)
+	if err != nil || fine == nil || fine.Amount != 12 {
+		t.Fatalf("expected a fine of 12, got %+v, %v", fine, err)
+	}
+
+	member, _ = stores.Members.Get(ctx, member.ID)
+	if !member.Blocked || member.BlockedBy != models.BlockedByFines {
+		t.Fatalf("expected member to be blocked for fines, got %+v", member)
+	}
+
+	if _, err := ledger.Pay(ctx, fine.ID, 20); !errors.Is(err, services.ErrInvalidAmount) {
+		t.Errorf("expected overpayment to be rejected, got %v", err)
+	}
+
+	paid, err := ledger.Pay(ctx, fine.ID, 4.25)
+	if err != nil || paid.Status != models.FineOpen || paid.Outstanding() != 7.75 {
+		t.Fatalf("expected partial payment to leave 7.75 open, got %+v, %v", paid, err)
+	}
+	if balance, _ := ledger.Balance(ctx, member.ID); balance != 7.75 {
+		t.Errorf("Balance() = %v, want 7.75", balance)
+	}
+
+	member, _ = stores.Members.Get(ctx, member.ID)
+	if member.Blocked {
+		t.Errorf("expected block to lift once the balance is under the threshold")
+	}
+
+	waived, err := ledger.Waive(ctx, fine.ID, "first offence")
+	if err != nil || waived.Status != models.FineWaived {
+		t.Fatalf("expected fine to be waived, got %+v, %v", waived, err)
+	}
+	if _, err := ledger.Pay(ctx, fine.ID, 1); !errors.Is(err, services.ErrFineClosed) {
+		t.Errorf("expected payment on a waived fine to fail, got %v", err)
+	}
+}
+
+// staleFines answers Get with the fine as a concurrent request read it
+// before the last change.
+type staleFines struct {
+	store.FineStore
+	read models.Fine
+}
+
+func (s staleFines) Get(context.Context, primitive.ObjectID) (models.Fine, error) {
+	return s.read, nil
+}
+
+func TestFineLedger_ConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	ledger := services.FineLedger{Fines: stores.Fines, Members: stores.Members}
+
+	member := models.Member{Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+	fine, _ := ledger.ChargeFee(ctx, member.ID, "test", 10)
+	read, _ := stores.Fines.Get(ctx, fine.ID)
+
+	if _, err := ledger.Pay(ctx, fine.ID, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	// both payments fit what was outstanding when the second was read
+	stale := services.FineLedger{Fines: staleFines{stores.Fines, read}, Members: stores.Members}
+	if _, err := stale.Pay(ctx, fine.ID, 6); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("expected a payment on a stale fine to conflict, got %v", err)
+	}
+	if _, err := stale.Waive(ctx, fine.ID, "stale"); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("expected a waiver of a stale fine to conflict, got %v", err)
+	}
+
+	got, _ := stores.Fines.Get(ctx, fine.ID)
+	if got.AmountPaid != 6 || len(got.Payments) != 1 || got.Status != models.FineOpen {
+		t.Errorf("expected only the first payment to be recorded, got %+v", got)
+	}
+}
+
+func TestFineLedger_KeepsManualBlock(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	ledger := services.FineLedger{Fines: stores.Fines, Members: stores.Members, Rate: 1, BlockThreshold: 1}
+
+	member := models.Member{Tier: models.TierStandard, Blocked: true}
+	stores.Members.Insert(ctx, &member)
+
+	fine, _ := ledger.AssessOverdue(ctx, models.Loan{MemberID: member.ID, DueDate: time.Now().AddDate(0, 0, -3)}, time.Now())
+	ledger.Pay(ctx, fine.ID, fine.Amount)
+
+	if member, _ = stores.Members.Get(ctx, member.ID); !member.Blocked {
+		t.Errorf("expected a block not placed by the ledger to stay in place")
+	}
+}
@@ -0,0 +1,227 @@
+// Package services holds circulation logic that is shared between the HTTP
+// handlers and the background daemons.
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// DefaultPickupDays is used when HoldQueue.PickupDays is not set.
+const DefaultPickupDays = 3
+
+// HoldQueue moves copies through the queue of holds placed on them.
+type HoldQueue struct {
+	Holds       store.HoldStore
+	Copies      store.CopyStore
+	AuditLogger utils.Logger
+	PickupDays  int
+}
+
+func (q *HoldQueue) pickupDeadline(now time.Time) time.Time {
+	days := q.PickupDays
+	if days <= 0 {
+		days = DefaultPickupDays
+	}
+	return now.AddDate(0, 0, days)
+}
+
+// next returns the oldest hold still waiting for copyObj: either a hold on
+// that copy or a title hold on its ISBN that has not been assigned a copy.
+func (q *HoldQueue) next(ctx context.Context, copyObj models.Copy) (models.Hold, bool) {
+	copyHold, copyErr := q.Holds.FindOne(ctx, store.HoldFilter{
+		CopyBarcode: copyObj.Barcode,
+		Open:        true,
+		Notified:    store.Bool(false),
+	})
+	titleHold, titleErr := models.Hold{}, store.ErrNotFound
+	if copyObj.ISBN != "" {
+		titleHold, titleErr = q.Holds.FindOne(ctx, store.HoldFilter{
+			ISBN:       copyObj.ISBN,
+			Unassigned: true,
+			Open:       true,
+		})
+	}
+	switch {
+	case copyErr != nil && titleErr != nil:
+		return models.Hold{}, false
+	case copyErr != nil:
+		return titleHold, true
+	case titleErr != nil:
+		return copyHold, true
+	case titleHold.Timestamp.Before(copyHold.Timestamp):
+		return titleHold, true
+	}
+	return copyHold, true
+}
+
+// Waiting reports whether anybody is queued for copyObj, either on the copy
+// itself or on its title.
+func (q *HoldQueue) Waiting(ctx context.Context, copyObj models.Copy) (bool, error) {
+	n, err := q.Holds.Count(ctx, store.HoldFilter{CopyBarcode: copyObj.Barcode, Open: true})
+	if err != nil || n > 0 {
+		return n > 0, err
+	}
+	if copyObj.ISBN == "" {
+		return false, nil
+	}
+	n, err = q.Holds.Count(ctx, store.HoldFilter{ISBN: copyObj.ISBN, Unassigned: true, Open: true})
+	return n > 0, err
+}
+
+// Release hands a copy that is leaving status from to the oldest waiting hold
+// on it or on its title. That hold is notified and given a pickup deadline and
+// the copy becomes RESERVED; with nobody waiting the copy becomes AVAILABLE.
+// The new copy status is returned.
+func (q *HoldQueue) Release(ctx context.Context, barcode string, from models.CopyStatus) (models.CopyStatus, error) {
+	trigger := models.TriggerHold
+	if from == models.StatusOnLoan || from == models.StatusLost {
+		trigger = models.TriggerCheckin
+	}
+	return q.release(ctx, barcode, models.StatusChange{From: from, Trigger: trigger})
+}
+
+// ReleaseFound is Release for a lost copy that was found, with note as the
+// reason for the change.
+func (q *HoldQueue) ReleaseFound(ctx context.Context, barcode, note string) (models.CopyStatus, error) {
+	return q.release(ctx, barcode, models.StatusChange{From: models.StatusLost, Trigger: models.TriggerFound, Reason: note})
+}
+
+// release carries out change, completed with the status the copy moves to.
+func (q *HoldQueue) release(ctx context.Context, barcode string, change models.StatusChange) (models.CopyStatus, error) {
+	copyObj, err := q.Copies.Get(ctx, barcode)
+	if err != nil {
+		return "", err
+	}
+
+	hold, hasHold := q.next(ctx, copyObj)
+
+	newStatus := models.StatusAvailable
+	if hasHold {
+		newStatus = models.StatusReserved
+	}
+
+	change.To = newStatus
+	if err := q.Copies.SetStatus(ctx, barcode, change); err != nil {
+		return "", err
+	}
+
+	if hasHold {
+		pickupBy := q.pickupDeadline(time.Now())
+		_ = q.Holds.Update(ctx, hold.ID, bson.M{
+			"notified":     true,
+			"pickup_by":    pickupBy,
+			"copy_barcode": barcode,
+		})
+
+		utils.AppendToEmailLog(ctx, hold.MemberID.Hex(), barcode)
+	}
+	return newStatus, nil
+}
+
+// Position returns the 1-based place of hold in its queue, or 0 when the hold
+// is no longer open. Copy holds queue per copy; title holds that are still
+// waiting for a copy queue per ISBN, and an assigned title hold is first in
+// line for its copy.
+func (q *HoldQueue) Position(ctx context.Context, hold models.Hold) (int, error) {
+	positions, err := q.Positions(ctx, []models.Hold{hold})
+	if err != nil {
+		return 0, err
+	}
+	return positions[hold.ID], nil
+}
+
+// Positions returns the queue position of every hold in holds, keyed by hold
+// ID, loading all the queues involved in a single query.
+func (q *HoldQueue) Positions(ctx context.Context, holds []models.Hold) (map[primitive.ObjectID]int, error) {
+	positions := make(map[primitive.ObjectID]int, len(holds))
+	queued := make(map[primitive.ObjectID]bool)
+	queues := store.HoldQueues{Copies: []string{}, ISBNs: []string{}}
+	for _, hold := range holds {
+		positions[hold.ID] = 0
+		switch {
+		case !hold.IsOpen():
+		case hold.IsTitleHold() && hold.Notified:
+			positions[hold.ID] = 1
+		case hold.IsTitleHold():
+			queued[hold.ID] = true
+			queues.ISBNs = append(queues.ISBNs, hold.ISBN)
+		default:
+			queued[hold.ID] = true
+			queues.Copies = append(queues.Copies, hold.CopyBarcode)
+		}
+	}
+	if len(queued) == 0 {
+		return positions, nil
+	}
+
+	open, err := q.Holds.Find(ctx, store.HoldFilter{Queues: &queues, Open: true})
+	if err != nil {
+		return nil, err
+	}
+	// holds come back oldest first, so counting per queue gives the place
+	lengths := make(map[string]int)
+	for _, h := range open {
+		key := "copy:" + h.CopyBarcode
+		if h.CopyBarcode == "" {
+			key = "isbn:" + h.ISBN
+		}
+		lengths[key]++
+		if queued[h.ID] {
+			positions[h.ID] = lengths[key]
+		}
+	}
+	return positions, nil
+}
+
+// Cancel closes an open hold. If the copy was already waiting on the shelf
+// for this hold it is passed on to the next member in the queue.
+func (q *HoldQueue) Cancel(ctx context.Context, hold models.Hold) error {
+	if err := q.Holds.Update(ctx, hold.ID, bson.M{"cancelled": true}); err != nil {
+		return err
+	}
+	q.AuditLogger.Log(ctx, models.HoldEntity, constants.Cancel, hold.ID.Hex())
+
+	if hold.Notified {
+		if _, err := q.Release(ctx, hold.CopyBarcode, models.StatusReserved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireStale expires every notified hold whose pickup deadline passed before
+// now and passes each copy on to the next member in its queue. It returns the
+// number of holds expired.
+func (q *HoldQueue) ExpireStale(ctx context.Context, now time.Time) (int, error) {
+	stale, err := q.Holds.Find(ctx, store.HoldFilter{
+		Open:         true,
+		Notified:     store.Bool(true),
+		PickupBefore: now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, hold := range stale {
+		if err := q.Holds.Update(ctx, hold.ID, bson.M{"expired": true}); err != nil {
+			return expired, err
+		}
+		expired++
+		q.AuditLogger.Log(ctx, models.HoldEntity, constants.Expire, hold.ID.Hex())
+
+		if _, err := q.Release(ctx, hold.CopyBarcode, models.StatusReserved); err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,147 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func TestHoldQueue_ExpireStale(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	queue := services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies, PickupDays: 2}
+
+	barcode := "123456"
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: barcode, Status: models.StatusOnLoan})
+
+	first := models.Hold{MemberID: primitive.NewObjectID(), CopyBarcode: barcode, Timestamp: time.Now().Add(-time.Hour)}
+	second := models.Hold{MemberID: primitive.NewObjectID(), CopyBarcode: barcode, Timestamp: time.Now()}
+	stores.Holds.Insert(ctx, &first)
+	stores.Holds.Insert(ctx, &second)
+
+	status, err := queue.Release(ctx, barcode, models.StatusOnLoan)
+	if err != nil || status != models.StatusReserved {
+		t.Fatalf("Release() = %v, %v; want RESERVED", status, err)
+	}
+	first, _ = stores.Holds.Get(ctx, first.ID)
+	if !first.Notified || first.PickupBy == nil {
+		t.Fatalf("expected first hold to be notified with a pickup deadline, got %+v", first)
+	}
+
+	if n, _ := queue.ExpireStale(ctx, time.Now()); n != 0 {
+		t.Fatalf("expected nothing to expire before the deadline, expired %d", n)
+	}
+
+	n, err := queue.ExpireStale(ctx, time.Now().AddDate(0, 0, 3))
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireStale() = %d, %v; want 1", n, err)
+	}
+	first, _ = stores.Holds.Get(ctx, first.ID)
+	second, _ = stores.Holds.Get(ctx, second.ID)
+	if !first.Expired || first.IsOpen() {
+		t.Errorf("expected first hold to be expired, got %+v", first)
+	}
+	if !second.Notified {
+		t.Errorf("expected copy to pass to the second hold, got %+v", second)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, barcode); copyObj.Status != models.StatusReserved {
+		t.Errorf("expected copy to stay reserved, got %v", copyObj.Status)
+	}
+
+	if err := queue.Cancel(ctx, second); err != nil {
+		t.Fatalf("Cancel() = %v", err)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, barcode); copyObj.Status != models.StatusAvailable {
+		t.Errorf("expected copy to be available once the queue is empty, got %v", copyObj.Status)
+	}
+}
+
+func TestHoldQueue_Position(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	queue := services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies}
+
+	var holds []models.Hold
+	for i := 0; i < 3; i++ {
+		hold := models.Hold{MemberID: primitive.NewObjectID(), CopyBarcode: "123456", Timestamp: time.Now().Add(time.Duration(i) * time.Minute)}
+		stores.Holds.Insert(ctx, &hold)
+		holds = append(holds, hold)
+	}
+
+	queue.Cancel(ctx, holds[0])
+	holds[0].Cancelled = true
+
+	for i, want := range []int{0, 1, 2} {
+		if got, _ := queue.Position(ctx, holds[i]); got != want {
+			t.Errorf("Position(hold %d) = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestHoldQueue_TitleHolds(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	queue := services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies}
+
+	isbn := "9780140449136"
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: isbn, Barcode: "C-1", Status: models.StatusOnLoan})
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: isbn, Barcode: "C-2", Status: models.StatusOnLoan})
+
+	now := time.Now()
+	titleHold := models.Hold{MemberID: primitive.NewObjectID(), ISBN: isbn, Timestamp: now.Add(-2 * time.Hour)}
+	copyHold := models.Hold{MemberID: primitive.NewObjectID(), CopyBarcode: "C-1", Timestamp: now.Add(-time.Hour)}
+	laterTitleHold := models.Hold{MemberID: primitive.NewObjectID(), ISBN: isbn, Timestamp: now}
+	for _, hold := range []*models.Hold{&titleHold, &copyHold, &laterTitleHold} {
+		stores.Holds.Insert(ctx, hold)
+	}
+
+	if got, _ := queue.Position(ctx, laterTitleHold); got != 2 {
+		t.Errorf("expected later title hold to be second for the title, got %d", got)
+	}
+
+	// the oldest hold is on the title, so whichever copy comes back first satisfies it
+	if _, err := queue.Release(ctx, "C-2", models.StatusOnLoan); err != nil {
+		t.Fatalf("Release() = %v", err)
+	}
+	titleHold, _ = stores.Holds.Get(ctx, titleHold.ID)
+	if !titleHold.Notified || titleHold.CopyBarcode != "C-2" {
+		t.Fatalf("expected title hold to be assigned C-2, got %+v", titleHold)
+	}
+
+	// C-1 then goes to its own, older, copy hold ahead of the later title hold
+	if _, err := queue.Release(ctx, "C-1", models.StatusOnLoan); err != nil {
+		t.Fatalf("Release() = %v", err)
+	}
+	copyHold, _ = stores.Holds.Get(ctx, copyHold.ID)
+	laterTitleHold, _ = stores.Holds.Get(ctx, laterTitleHold.ID)
+	if !copyHold.Notified || laterTitleHold.Notified {
+		t.Errorf("expected copy hold to be served before the later title hold")
+	}
+	if waiting, _ := queue.Waiting(ctx, models.Copy{ISBN: isbn, Barcode: "C-3"}); !waiting {
+		t.Errorf("expected the later title hold to count as waiting for any copy")
+	}
+}
+
+func TestHoldQueue_ReleaseCopyWithoutISBN(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	queue := services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies}
+
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-1", Status: models.StatusOnLoan})
+	titleHold := models.Hold{MemberID: primitive.NewObjectID(), ISBN: "9780140449136", Timestamp: time.Now()}
+	stores.Holds.Insert(ctx, &titleHold)
+
+	status, err := queue.Release(ctx, "C-1", models.StatusOnLoan)
+	if err != nil || status != models.StatusAvailable {
+		t.Fatalf("Release() = %v, %v; want AVAILABLE", status, err)
+	}
+	if titleHold, _ = stores.Holds.Get(ctx, titleHold.ID); titleHold.Notified || titleHold.CopyBarcode != "" {
+		t.Errorf("expected a title hold of another book to keep waiting, got %+v", titleHold)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// ISBNMigrationReport summarises a run of NormalizeCatalogISBNs.
+type ISBNMigrationReport struct {
+	Books   int      `json:"books"`   // books examined
+	Renamed int      `json:"renamed"` // books whose ISBN was rewritten in place
+	Merged  int      `json:"merged"`  // duplicates folded into another book
+	Copies  int      `json:"copies"`  // copies whose ISBN was normalized
+	Holds   int      `json:"holds"`   // title holds whose ISBN was normalized
+	Invalid []string `json:"invalid,omitempty"`
+}
+
+// NormalizeCatalogISBNs rewrites every stored ISBN to its ISBN-13 form. When
+// the normalized ISBN already belongs to another book the two are merged:
+// fields the surviving book lacks are taken from the duplicate, which is then
+// deleted. Autocomplete suggestions move with the book. The ISBNs of copies
+// and title holds are normalized in passes of their own, so they follow their
+// book and also match it when only they were stored in another form. Book
+// ISBNs that fail validation are reported and, like those of copies and
+// holds, left alone. Running it again is a no-op.
+func NormalizeCatalogISBNs(ctx context.Context, stores store.Stores) (ISBNMigrationReport, error) {
+	var report ISBNMigrationReport
+
+	// Collect first, then rewrite, so the scan is not disturbed by its own
+	// changes
+	var stale []models.Book
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		books, next, err := stores.Books.FindPage(ctx, store.BookFilter{IncludeWithdrawn: true}, page)
+		if err != nil {
+			return report, err
+		}
+		for _, book := range books {
+			report.Books++
+			normalized, err := models.NormalizeISBN(book.ISBN)
+			if err != nil {
+				report.Invalid = append(report.Invalid, book.ISBN)
+			} else if normalized != book.ISBN {
+				stale = append(stale, book)
+			}
+		}
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+
+	suggester := Suggester{Suggestions: stores.Suggestions}
+	for _, book := range stale {
+		normalized, _ := models.NormalizeISBN(book.ISBN)
+
+		target, err := stores.Books.Get(ctx, normalized)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			if _, err := stores.Books.Update(ctx, book.ISBN, map[string]interface{}{"isbn": normalized}); err != nil {
+				return report, err
+			}
+			report.Renamed++
+		case err != nil:
+			return report, err
+		default:
+			if fields := missingFields(target, book); len(fields) > 0 {
+				if _, err := stores.Books.Update(ctx, normalized, fields); err != nil {
+					return report, err
+				}
+			}
+			if err := stores.Books.Delete(ctx, book.ISBN); err != nil {
+				return report, err
+			}
+			report.Merged++
+		}
+
+		// The suggestions follow the book to its new ISBN
+		for _, isbn := range []string{book.ISBN, normalized} {
+			if err := suggester.Refresh(ctx, stores.Books, isbn); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := normalizeCopyISBNs(ctx, stores.Copies, &report); err != nil {
+		return report, err
+	}
+	return report, normalizeHoldISBNs(ctx, stores.Holds, &report)
+}
+
+// missingFields returns the fields target lacks that from has, as a $set.
+func missingFields(target, from models.Book) map[string]interface{} {
+	fields := providedFields(from)
+	for name := range providedFields(target) {
+		delete(fields, name)
+	}
+
+	tags := append([]string{}, target.Tags...)
+	for _, tag := range from.Tags {
+		found := false
+		for _, t := range tags {
+			found = found || t == tag
+		}
+		if !found {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > len(target.Tags) {
+		fields["tags"] = tags
+	}
+	return fields
+}
+
+// normalizeCopyISBNs rewrites the ISBN of every copy to its ISBN-13 form.
+// Barcodes do not change, so the copies can be updated while paged through.
+func normalizeCopyISBNs(ctx context.Context, copies store.CopyStore, report *ISBNMigrationReport) error {
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		batch, next, err := copies.FindPage(ctx, store.CopyFilter{}, page)
+		if err != nil {
+			return err
+		}
+		for _, copyObj := range batch {
+			normalized, err := models.NormalizeISBN(copyObj.ISBN)
+			if err != nil || normalized == copyObj.ISBN {
+				continue
+			}
+			if err := copies.Update(ctx, copyObj.Barcode, map[string]interface{}{"isbn": normalized}); err != nil {
+				return err
+			}
+			report.Copies++
+		}
+		if next == "" {
+			return nil
+		}
+		page.Cursor = next
+	}
+}
+
+// normalizeHoldISBNs rewrites the ISBN of every title hold to its ISBN-13
+// form.
+func normalizeHoldISBNs(ctx context.Context, holds store.HoldStore, report *ISBNMigrationReport) error {
+	all, err := holds.Find(ctx, store.HoldFilter{})
+	if err != nil {
+		return err
+	}
+	for _, hold := range all {
+		if !hold.IsTitleHold() {
+			continue
+		}
+		normalized, err := models.NormalizeISBN(hold.ISBN)
+		if err != nil || normalized == hold.ISBN {
+			continue
+		}
+		if err := holds.Update(ctx, hold.ID, map[string]interface{}{"isbn": normalized}); err != nil {
+			return err
+		}
+		report.Holds++
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func TestNormalizeCatalogISBNs(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	stores.Books.Insert(ctx, &models.Book{ISBN: "9780140449136", Title: "Crime and Punishment", Tags: []string{"classic"}})
+	stores.Books.Insert(ctx, &models.Book{ISBN: "0-14-044913-2", Title: "Crime & Punishment", Contributors: []models.Contributor{{Name: "Dostoyevsky", Role: models.ContributorAuthor}}, Tags: []string{"russian"}})
+	stores.Books.Insert(ctx, &models.Book{ISBN: "978-3-16-148410-0", Title: "Renamed"})
+	stores.Books.Insert(ctx, &models.Book{ISBN: "not-an-isbn", Title: "Broken"})
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: "0-14-044913-2", Barcode: "C-1", Status: models.StatusAvailable})
+	stores.Holds.Insert(ctx, &models.Hold{ISBN: "978-3-16-148410-0"})
+	// a canonical book whose copy and hold were stored in another form
+	stores.Books.Insert(ctx, &models.Book{ISBN: "9780306406157", Title: "Canonical"})
+	stores.Copies.Insert(ctx, &models.Copy{ISBN: "0-306-40615-2", Barcode: "C-2", Status: models.StatusAvailable})
+	stores.Holds.Insert(ctx, &models.Hold{ISBN: "978-0-306-40615-7"})
+
+	suggester := services.Suggester{Suggestions: stores.Suggestions, Copies: stores.Copies, Loans: stores.Loans}
+	renamed, _ := stores.Books.Get(ctx, "978-3-16-148410-0")
+	suggester.Index(ctx, renamed)
+
+	report, err := services.NormalizeCatalogISBNs(ctx, stores)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Books != 5 || report.Merged != 1 || report.Renamed != 1 || report.Copies != 2 || report.Holds != 2 ||
+		len(report.Invalid) != 1 || report.Invalid[0] != "not-an-isbn" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	merged, err := stores.Books.Get(ctx, "9780140449136")
+	if err != nil || merged.Title != "Crime and Punishment" || len(merged.Contributors) != 1 || len(merged.Tags) != 2 {
+		t.Errorf("unexpected merged book %+v (%v)", merged, err)
+	}
+	if _, err := stores.Books.Get(ctx, "0-14-044913-2"); err != store.ErrNotFound {
+		t.Errorf("expected the duplicate to be deleted, got %v", err)
+	}
+	if _, err := stores.Books.Get(ctx, "9783161484100"); err != nil {
+		t.Errorf("expected the book to be renamed: %v", err)
+	}
+	if got, _ := suggester.Suggest(ctx, "Renamed", 5); len(got) != 1 || len(got[0].ISBNs) != 1 || got[0].ISBNs[0] != "9783161484100" {
+		t.Errorf("expected the suggestions to follow the renamed book, got %+v", got)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, "C-1"); copyObj.ISBN != "9780140449136" {
+		t.Errorf("expected the copy to follow its book, got %q", copyObj.ISBN)
+	}
+	if n, _ := stores.Holds.Count(ctx, store.HoldFilter{ISBN: "9783161484100"}); n != 1 {
+		t.Errorf("expected the hold to follow its book, got %d", n)
+	}
+
+	if copyObj, _ := stores.Copies.Get(ctx, "C-2"); copyObj.ISBN != "9780306406157" {
+		t.Errorf("expected the copy of a canonical book to be normalized, got %q", copyObj.ISBN)
+	}
+	if n, _ := stores.Holds.Count(ctx, store.HoldFilter{ISBN: "9780306406157"}); n != 1 {
+		t.Errorf("expected the hold on a canonical book to be normalized, got %d", n)
+	}
+
+	again, _ := services.NormalizeCatalogISBNs(ctx, stores)
+	if again.Renamed != 0 || again.Merged != 0 || again.Copies != 0 || again.Holds != 0 {
+		t.Errorf("expected a second run to change nothing, got %+v", again)
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+var (
+	ErrLoanClosed = errors.New("loan is already closed")
+	ErrClaimOpen  = errors.New("a claims returned investigation is already open for this loan")
+	ErrNotLost    = errors.New("copy is not lost")
+)
+
+// LostItems handles copies that do not come back from a loan: declared lost
+// or damaged, which closes the loan and charges the replacement cost, or
+// claimed returned by the member, which marks the copy missing while the
+// library looks for it.
+type LostItems struct {
+	Loans       store.LoanStore
+	Copies      store.CopyStore
+	HoldQueue   *HoldQueue
+	Fines       *FineLedger
+	AuditLogger utils.Logger
+	// ReplacementCost is charged for copies without a cost of their own
+	ReplacementCost float64
+}
+
+// Declare closes the open loan id as lost or damaged, moving its copy to
+// LOST or DAMAGED and charging the member the copy's replacement cost. A
+// loan under a claims returned investigation can still be declared lost once
+// the copy is given up on. The charge is nil when the cost is zero. When any
+// step fails the loan stays open and nothing is left charged, so the
+// declaration can be retried.
+func (l *LostItems) Declare(ctx context.Context, id primitive.ObjectID, outcome models.LoanOutcome, reason string) (models.Loan, *models.Fine, error) {
+	var status models.CopyStatus
+	var trigger models.StatusTrigger
+	var action string
+	switch outcome {
+	case models.OutcomeLost:
+		status, trigger, action = models.StatusLost, models.TriggerLost, constants.Lost
+	case models.OutcomeDamaged:
+		status, trigger, action = models.StatusDamaged, models.TriggerDamaged, constants.Damaged
+	default:
+		return models.Loan{}, nil, fmt.Errorf("cannot declare a loan %s", outcome)
+	}
+	if reason == "" {
+		reason = string(outcome)
+	}
+
+	loan, err := l.Loans.FindOne(ctx, store.LoanFilter{ID: id})
+	if err != nil {
+		return loan, nil, err
+	}
+	if loan.Returned {
+		return loan, nil, ErrLoanClosed
+	}
+	copyObj, err := l.Copies.Get(ctx, loan.CopyBarcode)
+	if err != nil {
+		return loan, nil, err
+	}
+
+	// Charge first so the member is always billed for a closed loan; the
+	// charge is waived again if the loan or copy cannot be updated
+	cost := copyObj.ReplacementCost
+	if cost <= 0 {
+		cost = l.ReplacementCost
+	}
+	fine, err := l.Fines.ChargeReplacement(ctx, loan, outcome, cost)
+	fail := func(err error) (models.Loan, *models.Fine, error) {
+		if fine != nil {
+			l.Fines.Waive(context.Background(), fine.ID, "declaration failed")
+		}
+		return loan, nil, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	// Only one declaration or check-in can match the open loan
+	loan, err = l.Loans.FindOneAndUpdate(ctx, store.LoanFilter{ID: id, Returned: store.Bool(false)},
+		bson.M{"returned": true, "outcome": outcome})
+	if errors.Is(err, store.ErrNotFound) {
+		return fail(ErrLoanClosed)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if copyObj.Status != status {
+		change := models.StatusChange{From: copyObj.Status, To: status, Trigger: trigger, Reason: reason}
+		if err := l.Copies.SetStatus(ctx, copyObj.Barcode, change); err != nil {
+			_ = l.Loans.Update(context.Background(), id, bson.M{"returned": false, "outcome": nil})
+			return fail(err)
+		}
+	}
+	loan.Returned = true
+	loan.Outcome = outcome
+	l.AuditLogger.Log(ctx, models.LoanEntity, action, bson.M{"loan_id": id, "reason": reason})
+	return loan, fine, nil
+}
+
+// ClaimReturned records that the member of the open loan id says they
+// returned its copy although it cannot be found. The copy is marked LOST and
+// the loan stays open, without charges, until the copy is found or the loan
+// is declared lost.
+func (l *LostItems) ClaimReturned(ctx context.Context, id primitive.ObjectID, reason string) (models.Loan, error) {
+	loan, err := l.Loans.FindOne(ctx, store.LoanFilter{ID: id})
+	if err != nil {
+		return loan, err
+	}
+	if loan.Returned {
+		return loan, ErrLoanClosed
+	}
+	if loan.ClaimsReturnedAt != nil {
+		return loan, ErrClaimOpen
+	}
+
+	copyObj, err := l.Copies.Get(ctx, loan.CopyBarcode)
+	if err != nil {
+		return loan, err
+	}
+	if reason == "" {
+		reason = "claims returned"
+	}
+	if copyObj.Status != models.StatusLost {
+		change := models.StatusChange{From: copyObj.Status, To: models.StatusLost, Trigger: models.TriggerLost, Reason: reason}
+		if err := l.Copies.SetStatus(ctx, copyObj.Barcode, change); err != nil {
+			return loan, err
+		}
+	}
+
+	now := time.Now()
+	if err := l.Loans.Update(ctx, id, bson.M{"claims_returned_at": now}); err != nil {
+		return loan, err
+	}
+	loan.ClaimsReturnedAt = &now
+	l.AuditLogger.Log(ctx, models.LoanEntity, constants.Claim, bson.M{"loan_id": id, "reason": reason})
+	return loan, nil
+}
+
+// Found puts a lost copy back into circulation, reserved for the next hold
+// on it or its title if there is one. An open claims returned loan of it is
+// closed in the member's favour; otherwise the replacement charged for its
+// last lost loan is refunded. The refunded fines are returned.
+func (l *LostItems) Found(ctx context.Context, barcode, note string) ([]models.Fine, error) {
+	copyObj, err := l.Copies.Get(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if copyObj.Status != models.StatusLost {
+		return nil, ErrNotLost
+	}
+	if _, err := l.HoldQueue.ReleaseFound(ctx, barcode, note); err != nil {
+		return nil, err
+	}
+	l.AuditLogger.Log(ctx, models.CopyEntity, constants.Found, bson.M{"barcode": barcode, "note": note})
+
+	// A claim is upheld: the loan ends as if checked in, without charges
+	claimed, err := l.Loans.FindOneAndUpdate(ctx,
+		store.LoanFilter{CopyBarcode: barcode, Returned: store.Bool(false)},
+		bson.M{"returned": true, "outcome": models.OutcomeFound})
+	if err == nil {
+		l.AuditLogger.Log(ctx, models.LoanEntity, constants.CheckIn, claimed.ID.Hex())
+		return nil, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	lost, err := l.lastLostLoan(ctx, barcode)
+	if err != nil || lost == nil {
+		return nil, err
+	}
+	if err := l.Loans.Update(ctx, lost.ID, bson.M{"outcome": models.OutcomeFound}); err != nil {
+		return nil, err
+	}
+	fines, err := l.Fines.Fines.Find(ctx, store.FineFilter{LoanID: lost.ID})
+	if err != nil {
+		return nil, err
+	}
+	var refunded []models.Fine
+	for _, fine := range fines {
+		if fine.Reason != string(models.OutcomeLost) {
+			continue
+		}
+		fine, err := l.Fines.Refund(ctx, fine.ID, "copy found")
+		if errors.Is(err, ErrFineClosed) {
+			continue
+		}
+		if err != nil {
+			return refunded, err
+		}
+		refunded = append(refunded, fine)
+	}
+	return refunded, nil
+}
+
+// lastLostLoan returns the most recent loan of barcode that was declared
+// lost, or nil.
+func (l *LostItems) lastLostLoan(ctx context.Context, barcode string) (*models.Loan, error) {
+	loans, err := l.Loans.Find(ctx, store.LoanFilter{CopyBarcode: barcode, Returned: store.Bool(true)})
+	if err != nil {
+		return nil, err
+	}
+	var last *models.Loan
+	for i := range loans {
+		if loans[i].Outcome == models.OutcomeLost && (last == nil || loans[i].LoanDate.After(last.LoanDate)) {
+			last = &loans[i]
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,161 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func newLostItems(t *testing.T) (*services.LostItems, store.Stores, models.Member) {
+	t.Helper()
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	ledger := &services.FineLedger{Fines: stores.Fines, Members: stores.Members, Rate: 1}
+	member := models.Member{Tier: models.TierStandard}
+	stores.Members.Insert(ctx, &member)
+	queue := &services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies}
+	return &services.LostItems{Loans: stores.Loans, Copies: stores.Copies, HoldQueue: queue, Fines: ledger, ReplacementCost: 25}, stores, member
+}
+
+func lend(t *testing.T, stores store.Stores, member models.Member, copyObj models.Copy) models.Loan {
+	t.Helper()
+	ctx := context.Background()
+	copyObj.Status = models.StatusOnLoan
+	stores.Copies.Insert(ctx, &copyObj)
+	loan := models.Loan{MemberID: member.ID, CopyBarcode: copyObj.Barcode, LoanDate: time.Now(), DueDate: time.Now().AddDate(0, 0, 7)}
+	if err := stores.Loans.Insert(ctx, &loan); err != nil {
+		t.Fatal(err)
+	}
+	return loan
+}
+
+func TestLostItems_DeclareLostAndFound(t *testing.T) {
+	ctx := context.Background()
+	lostItems, stores, member := newLostItems(t)
+	loan := lend(t, stores, member, models.Copy{Barcode: "C-1", ReplacementCost: 40})
+
+	closed, fine, err := lostItems.Declare(ctx, loan.ID, models.OutcomeLost, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closed.Returned || closed.Outcome != models.OutcomeLost {
+		t.Errorf("expected the loan to be closed as lost, got %+v", closed)
+	}
+	if fine == nil || fine.Amount != 40 || fine.Reason != "lost" {
+		t.Fatalf("expected the copy's replacement cost to be charged, got %+v", fine)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, "C-1"); copyObj.Status != models.StatusLost {
+		t.Errorf("expected the copy to be LOST, got %s", copyObj.Status)
+	}
+	if _, _, err := lostItems.Declare(ctx, loan.ID, models.OutcomeLost, ""); !errors.Is(err, services.ErrLoanClosed) {
+		t.Errorf("expected a second declaration to fail, got %v", err)
+	}
+
+	lostItems.Fines.Pay(ctx, fine.ID, 40)
+	refunded, err := lostItems.Found(ctx, "C-1", "behind the shelf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunded) != 1 || refunded[0].Status != models.FineRefunded || refunded[0].Refunded != 40 {
+		t.Errorf("expected the paid replacement to be refunded, got %+v", refunded)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, "C-1"); copyObj.Status != models.StatusAvailable {
+		t.Errorf("expected the copy to be back on the shelf, got %s", copyObj.Status)
+	}
+	if _, err := lostItems.Found(ctx, "C-1", ""); !errors.Is(err, services.ErrNotLost) {
+		t.Errorf("expected finding an available copy to fail, got %v", err)
+	}
+}
+
+func TestLostItems_DeclareDamagedUsesDefaultCost(t *testing.T) {
+	ctx := context.Background()
+	lostItems, stores, member := newLostItems(t)
+	loan := lend(t, stores, member, models.Copy{Barcode: "C-1"})
+
+	_, fine, err := lostItems.Declare(ctx, loan.ID, models.OutcomeDamaged, "water damage")
+	if err != nil || fine == nil || fine.Amount != 25 {
+		t.Fatalf("expected the default replacement cost to be charged, got %+v, %v", fine, err)
+	}
+	copyObj, _ := stores.Copies.Get(ctx, "C-1")
+	if copyObj.Status != models.StatusDamaged || copyObj.History[0].Reason != "water damage" {
+		t.Errorf("expected the copy to be DAMAGED with the reason recorded, got %+v", copyObj)
+	}
+}
+
+func TestLostItems_DeclareChargeFails(t *testing.T) {
+	ctx := context.Background()
+	lostItems, stores, member := newLostItems(t)
+	loan := lend(t, stores, member, models.Copy{Barcode: "C-1", ReplacementCost: 40})
+
+	lostItems.Fines.Fines = failingFines{stores.Fines}
+	if _, _, err := lostItems.Declare(ctx, loan.ID, models.OutcomeLost, ""); err == nil {
+		t.Fatal("expected the declaration to fail with the charge")
+	}
+	if got, _ := stores.Loans.FindOne(ctx, store.LoanFilter{ID: loan.ID}); got.Returned {
+		t.Errorf("expected the loan to stay open, got %+v", got)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, "C-1"); copyObj.Status != models.StatusOnLoan {
+		t.Errorf("expected the copy to stay ON_LOAN, got %s", copyObj.Status)
+	}
+
+	// a retry once the fines are back bills the member
+	lostItems.Fines.Fines = stores.Fines
+	if _, fine, err := lostItems.Declare(ctx, loan.ID, models.OutcomeLost, ""); err != nil || fine == nil || fine.Amount != 40 {
+		t.Errorf("expected the retry to charge the replacement, got %+v, %v", fine, err)
+	}
+}
+
+func TestLostItems_ClaimReturned(t *testing.T) {
+	ctx := context.Background()
+	lostItems, stores, member := newLostItems(t)
+	loan := lend(t, stores, member, models.Copy{Barcode: "C-1"})
+
+	claimed, err := lostItems.ClaimReturned(ctx, loan.ID, "")
+	if err != nil || claimed.ClaimsReturnedAt == nil || claimed.Returned {
+		t.Fatalf("expected the loan to stay open under investigation, got %+v, %v", claimed, err)
+	}
+	if copyObj, _ := stores.Copies.Get(ctx, "C-1"); copyObj.Status != models.StatusLost {
+		t.Errorf("expected the missing copy to be LOST, got %s", copyObj.Status)
+	}
+	if _, err := lostItems.ClaimReturned(ctx, loan.ID, ""); !errors.Is(err, services.ErrClaimOpen) {
+		t.Errorf("expected a second claim to fail, got %v", err)
+	}
+
+	if _, err := lostItems.Found(ctx, "C-1", ""); err != nil {
+		t.Fatal(err)
+	}
+	loan, _ = stores.Loans.FindOne(ctx, store.LoanFilter{ID: loan.ID})
+	if !loan.Returned || loan.Outcome != models.OutcomeFound {
+		t.Errorf("expected the claim to be upheld, got %+v", loan)
+	}
+	if fines, _ := stores.Fines.Find(ctx, store.FineFilter{MemberID: member.ID}); len(fines) != 0 {
+		t.Errorf("expected no charges, got %+v", fines)
+	}
+}
+
+func TestLostItems_FoundCopyGoesToHold(t *testing.T) {
+	ctx := context.Background()
+	lostItems, stores, member := newLostItems(t)
+	loan := lend(t, stores, member, models.Copy{Barcode: "C-1", ISBN: "9780140449136"})
+	if _, _, err := lostItems.Declare(ctx, loan.ID, models.OutcomeLost, ""); err != nil {
+		t.Fatal(err)
+	}
+	hold := models.Hold{MemberID: member.ID, ISBN: "9780140449136", Timestamp: time.Now()}
+	stores.Holds.Insert(ctx, &hold)
+
+	if _, err := lostItems.Found(ctx, "C-1", "returned by post"); err != nil {
+		t.Fatal(err)
+	}
+	copyObj, _ := stores.Copies.Get(ctx, "C-1")
+	if last := copyObj.History[len(copyObj.History)-1]; copyObj.Status != models.StatusReserved || last.Trigger != models.TriggerFound {
+		t.Errorf("expected the found copy to be RESERVED for the hold, got %+v", copyObj)
+	}
+	if hold, _ = stores.Holds.Get(ctx, hold.ID); !hold.Notified || hold.CopyBarcode != "C-1" {
+		t.Errorf("expected the title hold to be offered the copy, got %+v", hold)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// MemberStatusMigrationReport summarises a run of MigrateMemberStatuses.
+type MemberStatusMigrationReport struct {
+	Members     int `json:"members"`     // members examined
+	Migrated    int `json:"migrated"`    // members that had no status yet
+	Deactivated int `json:"deactivated"` // of those, members found deactivated
+}
+
+// MigrateMemberStatuses gives a status to members stored before statuses
+// existed. Deactivation used to set blocked without a blocked_by, so those
+// members become DEACTIVATED and are unblocked; the rest become ACTIVE. The
+// unused active flag is removed. Running it again is a no-op.
+func MigrateMemberStatuses(ctx context.Context, members store.MemberStore) (MemberStatusMigrationReport, error) {
+	var report MemberStatusMigrationReport
+
+	// IDs do not change, so updating while paging by ID is safe
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		batch, next, err := members.FindPage(ctx, store.MemberFilter{}, page)
+		if err != nil {
+			return report, err
+		}
+		for _, member := range batch {
+			report.Members++
+			if member.Status != "" {
+				continue
+			}
+			fields := map[string]interface{}{
+				"status":     models.MemberActive,
+				"active":     nil,
+				"updated_at": time.Now(),
+			}
+			if member.Blocked && member.BlockedBy == "" {
+				fields["status"] = models.MemberDeactivated
+				fields["blocked"] = false
+				report.Deactivated++
+			}
+			if err := members.Update(ctx, member.ID, fields); err != nil {
+				return report, err
+			}
+			report.Migrated++
+		}
+		if next == "" {
+			return report, nil
+		}
+		page.Cursor = next
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+var (
+	ErrLoansOutstanding = errors.New("member still has loans outstanding")
+	ErrSuspensionReason = errors.New("a reason is required to suspend a member")
+	ErrSuspensionUntil  = errors.New("a suspension must end in the future")
+)
+
+// MembershipRenewal is the reason recorded on renewal fees.
+const MembershipRenewal = "membership renewal"
+
+// Membership moves members between statuses: suspended by staff for a
+// reason, until a date or until lifted, deactivated when they leave, and
+// reactivated from either. It also runs memberships out on their expiry date
+// and renews them.
+type Membership struct {
+	Members     store.MemberStore
+	Loans       store.LoanStore
+	Holds       store.HoldStore
+	HoldQueue   *HoldQueue
+	Fines       *FineLedger
+	AuditLogger utils.Logger
+	// Terms are the length and renewal fee of each tier
+	Terms map[models.MembershipTier]models.MembershipTerm
+	// NoticeDays is how long before expiry members are reminded
+	NoticeDays int
+}
+
+// Suspend suspends member id for reason until the given date, or until
+// reactivated when until is nil. Suspending a suspended member replaces the
+// reason and end date.
+func (m *Membership) Suspend(ctx context.Context, id primitive.ObjectID, reason string, until *time.Time, now time.Time) (models.Member, error) {
+	if reason == "" {
+		return models.Member{}, ErrSuspensionReason
+	}
+	if until != nil && !until.After(now) {
+		return models.Member{}, ErrSuspensionUntil
+	}
+	suspension := models.Suspension{Reason: reason, Since: now, Until: until}
+	return m.move(ctx, id, models.MemberSuspended, constants.Suspend, bson.M{"suspension": suspension}, now)
+}
+
+// Reactivate makes a suspended or deactivated member active again. An
+// expired membership has to be renewed instead.
+func (m *Membership) Reactivate(ctx context.Context, id primitive.ObjectID, now time.Time) (models.Member, error) {
+	return m.move(ctx, id, models.MemberActive, constants.Reactivate, bson.M{"suspension": nil}, now)
+}
+
+// Deactivate closes the membership of member id and cancels their open
+// holds. It fails with ErrLoansOutstanding while any of their loans, claims
+// returned ones included, is still open.
+func (m *Membership) Deactivate(ctx context.Context, id primitive.ObjectID, now time.Time) (models.Member, error) {
+	open, err := m.Loans.Count(ctx, store.LoanFilter{MemberID: id, Returned: store.Bool(false)})
+	if err != nil {
+		return models.Member{}, err
+	}
+	if open > 0 {
+		return models.Member{}, fmt.Errorf("%w (%d)", ErrLoansOutstanding, open)
+	}
+
+	member, err := m.move(ctx, id, models.MemberDeactivated, constants.Deactivate, bson.M{"suspension": nil}, now)
+	if err != nil {
+		return member, err
+	}
+
+	holds, err := m.Holds.Find(ctx, store.HoldFilter{MemberID: id, Open: true})
+	if err != nil {
+		return member, err
+	}
+	for _, hold := range holds {
+		if err := m.HoldQueue.Cancel(ctx, hold); err != nil {
+			return member, err
+		}
+	}
+	return member, nil
+}
+
+// move checks that member id may go to status and stores it with fields.
+func (m *Membership) move(ctx context.Context, id primitive.ObjectID, to models.MemberStatus, action string, fields bson.M, now time.Time) (models.Member, error) {
+	member, err := m.Members.Get(ctx, id)
+	if err != nil {
+		return member, err
+	}
+	from := member.StatusAt(now)
+	if from == models.MemberExpired && to == models.MemberActive {
+		return member, fmt.Errorf("%w: renew the membership instead", models.ErrMemberExpired)
+	}
+	if err := models.CheckMemberTransition(from, to); err != nil {
+		return member, err
+	}
+
+	fields["status"] = to
+	fields["updated_at"] = now
+	if err := m.Members.Update(ctx, id, fields); err != nil {
+		return member, err
+	}
+	m.AuditLogger.Log(ctx, models.MemberEntity, action, map[string]interface{}{"member_id": id.Hex(), "from": from, "to": to})
+	return m.Members.Get(ctx, id)
+}
+
+// term returns the membership term of tier.
+func (m *Membership) term(tier models.MembershipTier) models.MembershipTerm {
+	term := m.Terms[tier]
+	if term.Months <= 0 {
+		term.Months = models.DefaultMembershipMonths
+	}
+	return term
+}
+
+// Begin starts the membership of a member being registered at now.
+func (m *Membership) Begin(member *models.Member, now time.Time) {
+	expiry := now.AddDate(0, m.term(member.Tier).Months, 0)
+	member.MembershipStart = &now
+	member.MembershipExpiry = &expiry
+	member.ExpiryNoticeSent = nil
+}
+
+// Renew extends the membership of member id by one term of tier, or of its
+// current tier when tier is empty, and charges the tier's fee. The term runs
+// from the current expiry date, or from now once that has passed. An expired
+// member becomes active again, or suspended while a suspension they had is
+// still running. The fee is nil when it is zero.
+func (m *Membership) Renew(ctx context.Context, id primitive.ObjectID, tier models.MembershipTier, now time.Time) (models.Member, *models.Fine, error) {
+	member, err := m.Members.Get(ctx, id)
+	if err != nil {
+		return member, nil, err
+	}
+	status := member.StatusAt(now)
+	if status == models.MemberDeactivated {
+		return member, nil, fmt.Errorf("%w: reactivate the member before renewing", models.ErrInvalidMemberTransition)
+	}
+	if tier == "" {
+		tier = member.Tier
+	}
+	if !models.IsValidMemberTier(string(tier)) {
+		return member, nil, models.ErrMemberTier
+	}
+
+	from := now
+	if member.MembershipExpiry != nil && member.MembershipExpiry.After(now) {
+		from = *member.MembershipExpiry
+	}
+	term := m.term(tier)
+	expiry := from.AddDate(0, term.Months, 0)
+
+	fields := bson.M{
+		"tier":               tier,
+		"membership_expiry":  expiry,
+		"expiry_notice_sent": nil,
+		"updated_at":         now,
+	}
+	if member.MembershipStart == nil {
+		fields["membership_start"] = now
+	}
+	switch {
+	case status == models.MemberExpired:
+		fields["status"] = models.MemberActive
+		if suspension := member.Suspension; suspension != nil && (suspension.Until == nil || suspension.Until.After(now)) {
+			fields["status"] = models.MemberSuspended // the suspension outlasts the expiry
+		} else {
+			fields["suspension"] = nil
+		}
+	case member.Status == "":
+		fields["status"] = models.MemberActive // registered before statuses existed
+	}
+
+	// The fee is charged first so a failed charge leaves the membership as it
+	// was, and waived again if the renewal itself cannot be saved.
+	fee, err := m.Fines.ChargeFee(ctx, id, MembershipRenewal, term.Fee)
+	if err == nil {
+		err = m.Members.Update(ctx, id, fields)
+	}
+	if err != nil {
+		if fee != nil {
+			m.Fines.Waive(ctx, fee.ID, "membership renewal failed")
+		}
+		return member, nil, err
+	}
+	m.AuditLogger.Log(ctx, models.MemberEntity, constants.Renew, map[string]interface{}{"member_id": id.Hex(), "tier": tier, "expiry": expiry})
+
+	member, err = m.Members.Get(ctx, id)
+	return member, fee, err
+}
+
+// ExpireDue marks EXPIRED every active or suspended member whose membership
+// ran out by now, and returns how many it marked. Members registered before
+// statuses existed count as active.
+func (m *Membership) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	for _, status := range []models.MemberStatus{models.MemberActive, models.MemberSuspended} {
+		err := m.eachMember(ctx, store.MemberFilter{Status: status, ExpiresBy: now}, func(member models.Member) error {
+			if err := m.Members.Update(ctx, member.ID, bson.M{"status": models.MemberExpired, "updated_at": now}); err != nil {
+				return err
+			}
+			m.AuditLogger.Log(ctx, models.MemberEntity, constants.Expire, member.ID.Hex())
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// NotifyExpiring reminds every active member, including those registered
+// before statuses existed, whose membership expires within NoticeDays of now,
+// once per term, and returns how many it reminded.
+func (m *Membership) NotifyExpiring(ctx context.Context, now time.Time) (int, error) {
+	if m.NoticeDays <= 0 {
+		return 0, nil
+	}
+	notified := 0
+	filter := store.MemberFilter{
+		Status:         models.MemberActive,
+		ExpiresAfter:   now,
+		ExpiresBy:      now.AddDate(0, 0, m.NoticeDays),
+		ExpiryNotified: store.Bool(false),
+	}
+	err := m.eachMember(ctx, filter, func(member models.Member) error {
+		utils.NotifyMembershipExpiry(ctx, member.ID.Hex(), *member.MembershipExpiry)
+		if err := m.Members.Update(ctx, member.ID, bson.M{"expiry_notice_sent": now}); err != nil {
+			return err
+		}
+		m.AuditLogger.Log(ctx, models.MemberEntity, constants.Notify, member.ID.Hex())
+		notified++
+		return nil
+	})
+	return notified, err
+}
+
+// eachMember calls fn for every member matching filter. IDs do not change,
+// so fn may update the members while they are paged through.
+func (m *Membership) eachMember(ctx context.Context, filter store.MemberFilter, fn func(models.Member) error) error {
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		batch, next, err := m.Members.FindPage(ctx, filter, page)
+		if err != nil {
+			return err
+		}
+		for _, member := range batch {
+			if err := fn(member); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		page.Cursor = next
+	}
+}
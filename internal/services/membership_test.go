@@ -0,0 +1,272 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func newMembership(stores store.Stores) *services.Membership {
+	return &services.Membership{
+		Members:   stores.Members,
+		Loans:     stores.Loans,
+		Holds:     stores.Holds,
+		HoldQueue: &services.HoldQueue{Holds: stores.Holds, Copies: stores.Copies},
+	}
+}
+
+func TestMembership_Suspend(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	membership := newMembership(stores)
+
+	member := models.Member{Name: "Ada", Status: models.MemberActive}
+	stores.Members.Insert(ctx, &member)
+
+	now := time.Now()
+	if _, err := membership.Suspend(ctx, member.ID, "", nil, now); !errors.Is(err, services.ErrSuspensionReason) {
+		t.Errorf("expected a reason to be required, got %v", err)
+	}
+	past := now.Add(-time.Hour)
+	if _, err := membership.Suspend(ctx, member.ID, "late returns", &past, now); !errors.Is(err, services.ErrSuspensionUntil) {
+		t.Errorf("expected an end date in the past to be refused, got %v", err)
+	}
+
+	until := now.AddDate(0, 0, 14)
+	suspended, err := membership.Suspend(ctx, member.ID, "late returns", &until, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suspended.Status != models.MemberSuspended || suspended.Suspension == nil || suspended.Suspension.Reason != "late returns" {
+		t.Fatalf("unexpected suspended member %+v", suspended)
+	}
+	if !errors.Is(suspended.CanBorrow(now), models.ErrMemberSuspended) || suspended.CanBorrow(until) != nil {
+		t.Errorf("expected the suspension to bar borrowing until %v", until)
+	}
+
+	reactivated, err := membership.Reactivate(ctx, member.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reactivated.Status != models.MemberActive || reactivated.Suspension != nil {
+		t.Errorf("expected the suspension to be lifted, got %+v", reactivated)
+	}
+	if _, err := membership.Reactivate(ctx, member.ID, now); !errors.Is(err, models.ErrInvalidMemberTransition) {
+		t.Errorf("expected reactivating an active member to be refused, got %v", err)
+	}
+}
+
+func TestMembership_Deactivate(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	membership := newMembership(stores)
+
+	member := models.Member{Name: "Ada", Status: models.MemberActive}
+	stores.Members.Insert(ctx, &member)
+	loan := models.Loan{MemberID: member.ID, CopyBarcode: "C-1"}
+	stores.Loans.Insert(ctx, &loan)
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-2", Status: models.StatusOnLoan})
+	hold := models.Hold{MemberID: member.ID, CopyBarcode: "C-2", Timestamp: time.Now()}
+	stores.Holds.Insert(ctx, &hold)
+
+	now := time.Now()
+	if _, err := membership.Deactivate(ctx, member.ID, now); !errors.Is(err, services.ErrLoansOutstanding) {
+		t.Fatalf("expected the open loan to block deactivation, got %v", err)
+	}
+
+	stores.Loans.FindOneAndUpdate(ctx, store.LoanFilter{ID: loan.ID}, map[string]interface{}{"returned": true})
+	deactivated, err := membership.Deactivate(ctx, member.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deactivated.Status != models.MemberDeactivated {
+		t.Errorf("expected the member to be deactivated, got %+v", deactivated)
+	}
+	if hold, _ = stores.Holds.Get(ctx, hold.ID); !hold.Cancelled {
+		t.Errorf("expected the open hold to be cancelled, got %+v", hold)
+	}
+	if _, err := membership.Suspend(ctx, member.ID, "abuse", nil, now); !errors.Is(err, models.ErrInvalidMemberTransition) {
+		t.Errorf("expected a deactivated member not to be suspended, got %v", err)
+	}
+	if reactivated, err := membership.Reactivate(ctx, member.ID, now); err != nil || reactivated.Status != models.MemberActive {
+		t.Errorf("expected the member to be reactivated, got %+v, %v", reactivated, err)
+	}
+}
+
+func TestMigrateMemberStatuses(t *testing.T) {
+	ctx := context.Background()
+	members := store.NewMemoryMemberStore()
+	plain := models.Member{Name: "Ada"}
+	finesBlocked := models.Member{Name: "Alan", Blocked: true, BlockedBy: models.BlockedByFines}
+	deactivated := models.Member{Name: "Grace", Blocked: true}
+	for _, member := range []*models.Member{&plain, &finesBlocked, &deactivated} {
+		members.Insert(ctx, member)
+	}
+
+	report, err := services.MigrateMemberStatuses(ctx, members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Members != 3 || report.Migrated != 3 || report.Deactivated != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if got, _ := members.Get(ctx, plain.ID); got.Status != models.MemberActive {
+		t.Errorf("expected an active member, got %+v", got)
+	}
+	if got, _ := members.Get(ctx, finesBlocked.ID); got.Status != models.MemberActive || !got.Blocked {
+		t.Errorf("expected the fines block to stay, got %+v", got)
+	}
+	if got, _ := members.Get(ctx, deactivated.ID); got.Status != models.MemberDeactivated || got.Blocked {
+		t.Errorf("expected a deactivated, unblocked member, got %+v", got)
+	}
+
+	if again, _ := services.MigrateMemberStatuses(ctx, members); again.Migrated != 0 {
+		t.Errorf("expected a second run to be a no-op, got %+v", again)
+	}
+}
+
+func TestMembership_Renew(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	membership := newMembership(stores)
+	membership.Fines = &services.FineLedger{Fines: stores.Fines, Members: stores.Members}
+	membership.Terms = map[models.MembershipTier]models.MembershipTerm{
+		models.TierStandard: {Months: 12},
+		models.TierPremium:  {Months: 6, Fee: 30},
+	}
+
+	now := time.Now().Truncate(time.Millisecond) // as stored
+	current := now.AddDate(0, 1, 0)
+	member := models.Member{Name: "Ada", Tier: models.TierStandard, Status: models.MemberActive, MembershipExpiry: &current, ExpiryNoticeSent: &now}
+	stores.Members.Insert(ctx, &member)
+
+	renewed, fee, err := membership.Renew(ctx, member.ID, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee != nil {
+		t.Errorf("expected no fee for a free tier, got %+v", fee)
+	}
+	if want := current.AddDate(0, 12, 0); !renewed.MembershipExpiry.Equal(want) || renewed.ExpiryNoticeSent != nil {
+		t.Errorf("expected the term to run on from the current expiry to %v, got %+v", want, renewed)
+	}
+
+	expired := now.AddDate(0, 0, -3)
+	stores.Members.Update(ctx, member.ID, map[string]interface{}{"status": models.MemberExpired, "membership_expiry": expired})
+	renewed, fee, err = membership.Renew(ctx, member.ID, models.TierPremium, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.Status != models.MemberActive || renewed.Tier != models.TierPremium || !renewed.MembershipExpiry.Equal(now.AddDate(0, 6, 0)) {
+		t.Errorf("expected an active premium member until %v, got %+v", now.AddDate(0, 6, 0), renewed)
+	}
+	if fee == nil || fee.Amount != 30 || fee.Reason != services.MembershipRenewal {
+		t.Errorf("expected the premium fee to be charged, got %+v", fee)
+	}
+
+	if _, err := membership.Reactivate(ctx, member.ID, now.AddDate(1, 0, 0)); !errors.Is(err, models.ErrMemberExpired) {
+		t.Errorf("expected reactivating an expired member to be refused, got %v", err)
+	}
+	stores.Members.Update(ctx, member.ID, map[string]interface{}{"status": models.MemberDeactivated})
+	if _, _, err := membership.Renew(ctx, member.ID, "", now); !errors.Is(err, models.ErrInvalidMemberTransition) {
+		t.Errorf("expected a deactivated member not to be renewed, got %v", err)
+	}
+}
+
+// failingFines refuses to store any fine.
+type failingFines struct {
+	store.FineStore
+}
+
+func (failingFines) Insert(context.Context, *models.Fine) error {
+	return errors.New("fines unavailable")
+}
+
+func TestMembership_RenewCharge(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	membership := newMembership(stores)
+	membership.Terms = map[models.MembershipTier]models.MembershipTerm{
+		models.TierPremium: {Months: 6, Fee: 30},
+	}
+
+	now := time.Now().Truncate(time.Millisecond) // as stored
+	current := now.AddDate(0, 1, 0)
+	legacy := models.Member{Name: "Ken", Tier: models.TierPremium, MembershipExpiry: &current}
+	stores.Members.Insert(ctx, &legacy)
+
+	membership.Fines = &services.FineLedger{Fines: failingFines{stores.Fines}, Members: stores.Members}
+	if _, _, err := membership.Renew(ctx, legacy.ID, "", now); err == nil {
+		t.Fatal("expected the renewal to fail with the charge")
+	}
+	if got, _ := stores.Members.Get(ctx, legacy.ID); !got.MembershipExpiry.Equal(current) {
+		t.Errorf("expected an unpaid renewal not to extend the membership, got %+v", got)
+	}
+
+	membership.Fines = &services.FineLedger{Fines: stores.Fines, Members: stores.Members}
+	renewed, fee, err := membership.Renew(ctx, legacy.ID, "", now)
+	if err != nil || fee == nil {
+		t.Fatalf("expected the renewal to be charged, got %+v, %v", fee, err)
+	}
+	if renewed.Status != models.MemberActive || !renewed.MembershipExpiry.Equal(current.AddDate(0, 6, 0)) {
+		t.Errorf("expected the renewal to record an active status, got %+v", renewed)
+	}
+}
+
+func TestMembership_ExpireAndNotify(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	membership := newMembership(stores)
+	membership.NoticeDays = 14
+
+	now := time.Now()
+	at := func(days int) *time.Time {
+		t := now.AddDate(0, 0, days)
+		return &t
+	}
+	lapsed := models.Member{Name: "Ada", Status: models.MemberActive, MembershipExpiry: at(-1)}
+	suspended := models.Member{Name: "Alan", Status: models.MemberSuspended, MembershipExpiry: at(-2)}
+	expiring := models.Member{Name: "Grace", Status: models.MemberActive, MembershipExpiry: at(10)}
+	later := models.Member{Name: "Edsger", Status: models.MemberActive, MembershipExpiry: at(30)}
+	open := models.Member{Name: "Barbara", Status: models.MemberActive}
+	// registered before statuses existed
+	legacyLapsed := models.Member{Name: "Ken", MembershipExpiry: at(-1)}
+	legacyExpiring := models.Member{Name: "Dennis", MembershipExpiry: at(5)}
+	for _, member := range []*models.Member{&lapsed, &suspended, &expiring, &later, &open, &legacyLapsed, &legacyExpiring} {
+		stores.Members.Insert(ctx, member)
+	}
+
+	expired, err := membership.ExpireDue(ctx, now)
+	if err != nil || expired != 3 {
+		t.Fatalf("expected 3 memberships to expire, got %d, %v", expired, err)
+	}
+	for _, id := range []primitive.ObjectID{lapsed.ID, suspended.ID, legacyLapsed.ID} {
+		if got, _ := stores.Members.Get(ctx, id); got.Status != models.MemberExpired {
+			t.Errorf("expected %s to be expired, got %s", got.Name, got.Status)
+		}
+	}
+	if got, _ := stores.Members.Get(ctx, open.ID); got.Status != models.MemberActive {
+		t.Errorf("expected a member without an expiry to stay active, got %s", got.Status)
+	}
+
+	notified, err := membership.NotifyExpiring(ctx, now)
+	if err != nil || notified != 2 {
+		t.Fatalf("expected 2 members to be reminded, got %d, %v", notified, err)
+	}
+	for _, id := range []primitive.ObjectID{expiring.ID, legacyExpiring.ID} {
+		if got, _ := stores.Members.Get(ctx, id); got.ExpiryNoticeSent == nil {
+			t.Errorf("expected the reminder to %s to be recorded, got %+v", got.Name, got)
+		}
+	}
+	if again, _ := membership.NotifyExpiring(ctx, now); again != 0 {
+		t.Errorf("expected members to be reminded once, got %d", again)
+	}
+}
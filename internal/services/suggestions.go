@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+)
+
+// MinSimilarity is the share of the query's trigrams an entry must contain to
+// be suggested.
+const MinSimilarity = 0.4
+
+// suggestCandidates is how many index entries are ranked per query.
+const suggestCandidates = 200
+
+// SuggestionResult is one suggested title or author. A title shared by
+// several editions, or an author of several books, is suggested once with
+// all their ISBNs.
+type SuggestionResult struct {
+	Text       string   `json:"text"`
+	Kind       string   `json:"kind"`
+	ISBNs      []string `json:"isbns"`
+	Loans      int64    `json:"loans"`
+	Similarity float64  `json:"similarity"`
+	Score      float64  `json:"score"`
+}
+
+// Suggester maintains the autocomplete index and answers queries from it,
+// ranking matches by loan history.
+type Suggester struct {
+	Suggestions store.SuggestionStore
+	Copies      store.CopyStore
+	Loans       store.LoanStore
+}
+
+// Index replaces the entries of book with its title and authors.
+func (s *Suggester) Index(ctx context.Context, book models.Book) error {
+	var entries []models.Suggestion
+	add := func(kind, text string) {
+		if grams := trigrams(text, false); len(grams) > 0 {
+			entries = append(entries, models.Suggestion{ISBN: book.ISBN, Kind: kind, Text: text, Trigrams: grams})
+		}
+	}
+	add(models.SuggestTitle, book.Title)
+	for _, name := range book.Authors() {
+		add(models.SuggestAuthor, name)
+	}
+	return s.Suggestions.Replace(ctx, book.ISBN, entries)
+}
+
+// Remove drops the entries of a deleted book.
+func (s *Suggester) Remove(ctx context.Context, isbn string) error {
+	return s.Suggestions.Replace(ctx, isbn, nil)
+}
+
+// Refresh brings the entries of isbn up to date with the stored book,
+// removing them when the book no longer exists or was withdrawn.
+func (s *Suggester) Refresh(ctx context.Context, books store.BookStore, isbn string) error {
+	book, err := books.Get(ctx, isbn)
+	switch {
+	case errors.Is(err, store.ErrNotFound) || (err == nil && book.WithdrawnAt != nil):
+		return s.Remove(ctx, isbn)
+	case err != nil:
+		return err
+	}
+	return s.Index(ctx, book)
+}
+
+// Rebuild indexes every book in the catalog and returns how many it indexed.
+func (s *Suggester) Rebuild(ctx context.Context, books store.BookStore) (int, error) {
+	indexed := 0
+	page := store.Page{Limit: store.MaxPageLimit}
+	for {
+		batch, next, err := books.FindPage(ctx, store.BookFilter{}, page)
+		if err != nil {
+			return indexed, err
+		}
+		for _, book := range batch {
+			if err := s.Index(ctx, book); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+		if next == "" {
+			return indexed, nil
+		}
+		page.Cursor = next
+	}
+}
+
+// Suggest returns up to limit titles and authors resembling query, which may
+// be the beginning of a word or contain typos. Matches are ranked by their
+// similarity weighted by how often their books were loaned:
+// similarity * (1 + ln(1 + loans)).
+func (s *Suggester) Suggest(ctx context.Context, query string, limit int) ([]SuggestionResult, error) {
+	grams := trigrams(query, true)
+	if len(grams) == 0 {
+		return []SuggestionResult{}, nil
+	}
+	matches, err := s.Suggestions.Match(ctx, grams, suggestCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group entries by what is shown, keeping the best similarity
+	type key struct{ kind, text string }
+	grouped := map[key]*SuggestionResult{}
+	var order []key
+	var isbns []string
+	for _, m := range matches {
+		similarity := float64(m.Overlap) / float64(len(grams))
+		if similarity < MinSimilarity {
+			continue
+		}
+		k := key{m.Kind, m.Text}
+		r, ok := grouped[k]
+		if !ok {
+			r = &SuggestionResult{Text: m.Text, Kind: m.Kind}
+			grouped[k] = r
+			order = append(order, k)
+		}
+		r.Similarity = math.Max(r.Similarity, similarity)
+		r.ISBNs = append(r.ISBNs, m.ISBN)
+		isbns = append(isbns, m.ISBN)
+	}
+	if len(order) == 0 {
+		return []SuggestionResult{}, nil
+	}
+
+	loans, err := s.loansByISBN(ctx, isbns)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SuggestionResult, 0, len(order))
+	for _, k := range order {
+		r := grouped[k]
+		for _, isbn := range r.ISBNs {
+			r.Loans += loans[isbn]
+		}
+		r.Score = r.Similarity * (1 + math.Log1p(float64(r.Loans)))
+		results = append(results, *r)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Text < results[j].Text
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// loansByISBN counts the loans of all copies of each of isbns.
+func (s *Suggester) loansByISBN(ctx context.Context, isbns []string) (map[string]int64, error) {
+	copies, err := s.Copies.Find(ctx, store.CopyFilter{ISBNs: isbns})
+	if err != nil {
+		return nil, err
+	}
+	barcodes := make([]string, 0, len(copies))
+	for _, copyObj := range copies {
+		barcodes = append(barcodes, copyObj.Barcode)
+	}
+	byCopy, err := s.Loans.CountByCopy(ctx, barcodes)
+	if err != nil {
+		return nil, err
+	}
+	loans := map[string]int64{}
+	for _, copyObj := range copies {
+		loans[copyObj.ISBN] += byCopy[copyObj.Barcode]
+	}
+	return loans, nil
+}
+
+// trigrams splits text into lower-cased words, pads each with two spaces in
+// front and one behind, and returns the distinct three letter windows. For a
+// query the last word is not padded behind, since it may be unfinished.
+func trigrams(text string, query bool) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := map[string]bool{}
+	var grams []string
+	for i, word := range words {
+		padded := "  " + word
+		if !query || i < len(words)-1 {
+			padded += " "
+		}
+		runes := []rune(padded)
+		for j := 0; j+3 <= len(runes); j++ {
+			gram := string(runes[j : j+3])
+			if !seen[gram] {
+				seen[gram] = true
+				grams = append(grams, gram)
+			}
+		}
+	}
+	return grams
+}
@@ -0,0 +1,94 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func newSuggester(t *testing.T, books ...models.Book) *services.Suggester {
+	t.Helper()
+	s := &services.Suggester{
+		Suggestions: store.NewMemorySuggestionStore(),
+		Copies:      store.NewMemoryCopyStore(),
+		Loans:       store.NewMemoryLoanStore(),
+	}
+	for _, book := range books {
+		if err := s.Index(context.Background(), book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func authoredBy(name string) []models.Contributor {
+	return []models.Contributor{{Name: name, Role: models.ContributorAuthor}}
+}
+
+func TestSuggestMatchesPrefixesAndTypos(t *testing.T) {
+	s := newSuggester(t,
+		models.Book{ISBN: "9780140449136", Title: "Crime and Punishment", Contributors: authoredBy("Fyodor Dostoyevsky")},
+		models.Book{ISBN: "9780141439518", Title: "Pride and Prejudice", Contributors: authoredBy("Jane Austen")},
+	)
+
+	tests := []struct {
+		query string
+		want  string
+		kind  string
+	}{
+		{"crim", "Crime and Punishment", models.SuggestTitle},
+		{"pride and prej", "Pride and Prejudice", models.SuggestTitle},
+		{"dostoevsky", "Fyodor Dostoyevsky", models.SuggestAuthor},
+		{"jane austin", "Jane Austen", models.SuggestAuthor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := s.Suggest(context.Background(), tt.query, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) == 0 || got[0].Text != tt.want || got[0].Kind != tt.kind {
+				t.Fatalf("expected %s %q first, got %+v", tt.kind, tt.want, got)
+			}
+		})
+	}
+
+	if got, _ := s.Suggest(context.Background(), "zzz", 5); len(got) != 0 {
+		t.Errorf("expected no suggestions, got %+v", got)
+	}
+}
+
+func TestSuggestRanksByLoans(t *testing.T) {
+	ctx := context.Background()
+	s := newSuggester(t,
+		models.Book{ISBN: "9780000000002", Title: "Dune Messiah"},
+		models.Book{ISBN: "9780000000019", Title: "Dune Messiah"},
+		models.Book{ISBN: "9780000000026", Title: "Dune"},
+	)
+	s.Copies.Insert(ctx, &models.Copy{Barcode: "M-1", ISBN: "9780000000002", Status: models.StatusAvailable})
+	s.Copies.Insert(ctx, &models.Copy{Barcode: "M-2", ISBN: "9780000000019", Status: models.StatusAvailable})
+	for _, barcode := range []string{"M-1", "M-1", "M-2"} {
+		s.Loans.Insert(ctx, &models.Loan{CopyBarcode: barcode, Returned: true})
+	}
+
+	got, err := s.Suggest(ctx, "dune", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected editions to be grouped into 2 suggestions, got %+v", got)
+	}
+	if got[0].Text != "Dune Messiah" || got[0].Loans != 3 || len(got[0].ISBNs) != 2 {
+		t.Errorf("expected the loaned title first, got %+v", got)
+	}
+
+	if err := s.Remove(ctx, "9780000000026"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Suggest(ctx, "dune", 5); len(got) != 1 {
+		t.Errorf("expected the removed book to be gone, got %+v", got)
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// EnsureAdmin creates an admin account with the given credentials when there
+// are no user accounts yet, so a fresh install can log in and create the rest.
+func EnsureAdmin(ctx context.Context, users store.UserStore, username, password string) error {
+	count, err := users.Count(ctx)
+	if err != nil || count > 0 {
+		return err
+	}
+	if username == "" || password == "" {
+		return errors.New("no user accounts exist and no bootstrap admin is configured")
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return users.Insert(ctx, &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         models.RoleAdmin,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"open-library-explorer/internal/constants"
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
+	"open-library-explorer/internal/utils"
+)
+
+// DependentsError is returned when a book or copy cannot be withdrawn
+// because active records depend on it. Copies and holds can be cascaded;
+// open loans always have to be checked in first.
+type DependentsError struct {
+	Loans  int64 `json:"loans"`
+	Copies int64 `json:"copies"`
+	Holds  int64 `json:"holds"`
+}
+
+func (e *DependentsError) Error() string {
+	if e.Loans > 0 {
+		return fmt.Sprintf("%d copies are on loan and must be checked in first", e.Loans)
+	}
+	return fmt.Sprintf("%d copies and %d open holds depend on it; use cascade=true to withdraw and cancel them",
+		e.Copies, e.Holds)
+}
+
+// Withdrawal soft-deletes books and copies. Withdrawn records stay in their
+// stores so that historical loans keep resolving, but leave circulation.
+type Withdrawal struct {
+	Books       store.BookStore
+	Copies      store.CopyStore
+	Loans       store.LoanStore
+	Holds       store.HoldStore
+	AuditLogger utils.Logger
+}
+
+// WithdrawCopy withdraws the copy with barcode. Open holds on it are
+// cancelled when cascade is set, and otherwise make it fail with a
+// *DependentsError; title holds it was set aside for go back to the queue.
+func (w *Withdrawal) WithdrawCopy(ctx context.Context, barcode string, cascade bool) error {
+	copyObj, err := w.Copies.Get(ctx, barcode)
+	if err != nil {
+		return err
+	}
+	if copyObj.Status == models.StatusWithdrawn {
+		return nil
+	}
+
+	deps := &DependentsError{}
+	if deps.Loans, err = w.openLoans(ctx, []string{barcode}); err != nil {
+		return err
+	}
+	holds, err := w.Holds.Find(ctx, store.HoldFilter{CopyBarcode: barcode, Open: true})
+	if err != nil {
+		return err
+	}
+	deps.Holds = int64(len(holds))
+	if deps.Loans > 0 || (deps.Holds > 0 && !cascade) {
+		return deps
+	}
+
+	if err := w.withdraw(ctx, copyObj); err != nil {
+		return err
+	}
+	for _, hold := range holds {
+		if hold.IsTitleHold() {
+			err = w.requeue(ctx, hold)
+		} else {
+			err = w.cancel(ctx, hold)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithdrawBook withdraws the book with isbn. Its copies that are not yet
+// withdrawn and the open holds on it or its copies are withdrawn and
+// cancelled when cascade is set, and otherwise make it fail with a
+// *DependentsError.
+func (w *Withdrawal) WithdrawBook(ctx context.Context, isbn string, cascade bool) error {
+	book, err := w.Books.Get(ctx, isbn)
+	if err != nil {
+		return err
+	}
+	if book.WithdrawnAt != nil {
+		return nil
+	}
+
+	copies, err := w.Copies.Find(ctx, store.CopyFilter{ISBN: isbn})
+	if err != nil {
+		return err
+	}
+	var active []models.Copy
+	barcodes := make([]string, 0, len(copies))
+	for _, copyObj := range copies {
+		barcodes = append(barcodes, copyObj.Barcode)
+		if copyObj.Status != models.StatusWithdrawn {
+			active = append(active, copyObj)
+		}
+	}
+
+	deps := &DependentsError{Copies: int64(len(active))}
+	if deps.Loans, err = w.openLoans(ctx, barcodes); err != nil {
+		return err
+	}
+	holds, err := w.openHolds(ctx, isbn, barcodes)
+	if err != nil {
+		return err
+	}
+	deps.Holds = int64(len(holds))
+	if deps.Loans > 0 || ((deps.Copies > 0 || deps.Holds > 0) && !cascade) {
+		return deps
+	}
+
+	for _, hold := range holds {
+		if err := w.cancel(ctx, hold); err != nil {
+			return err
+		}
+	}
+	for _, copyObj := range active {
+		if err := w.withdraw(ctx, copyObj); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Books.Update(ctx, isbn, bson.M{"withdrawn_at": time.Now()}); err != nil {
+		return err
+	}
+	w.AuditLogger.Log(ctx, models.BookEntity, constants.Withdraw, isbn)
+	return nil
+}
+
+func (w *Withdrawal) openLoans(ctx context.Context, barcodes []string) (int64, error) {
+	return w.Loans.Count(ctx, store.LoanFilter{CopyBarcodes: barcodes, Returned: store.Bool(false)})
+}
+
+// openHolds returns the open holds on isbn or any of barcodes, each once.
+func (w *Withdrawal) openHolds(ctx context.Context, isbn string, barcodes []string) ([]models.Hold, error) {
+	titleHolds, err := w.Holds.Find(ctx, store.HoldFilter{ISBN: isbn, Open: true})
+	if err != nil {
+		return nil, err
+	}
+	copyHolds, err := w.Holds.Find(ctx, store.HoldFilter{CopyBarcodes: barcodes, Open: true})
+	if err != nil {
+		return nil, err
+	}
+	holds := titleHolds
+	for _, hold := range copyHolds {
+		if !hold.IsTitleHold() {
+			holds = append(holds, hold)
+		}
+	}
+	return holds, nil
+}
+
+// withdraw moves copyObj to WITHDRAWN from the status it was read in, so a
+// concurrent checkout makes it fail with store.ErrConflict.
+func (w *Withdrawal) withdraw(ctx context.Context, copyObj models.Copy) error {
+	change := models.StatusChange{From: copyObj.Status, To: models.StatusWithdrawn, Trigger: models.TriggerWithdraw}
+	if err := w.Copies.SetStatus(ctx, copyObj.Barcode, change); err != nil {
+		return err
+	}
+	if err := w.Copies.Update(ctx, copyObj.Barcode, bson.M{"withdrawn_at": time.Now()}); err != nil {
+		return err
+	}
+	w.AuditLogger.Log(ctx, models.CopyEntity, constants.Withdraw, copyObj.Barcode)
+	return nil
+}
+
+// cancel closes hold without offering its copy to anybody else, since the
+// copy is being withdrawn.
+func (w *Withdrawal) cancel(ctx context.Context, hold models.Hold) error {
+	if err := w.Holds.Update(ctx, hold.ID, bson.M{"cancelled": true}); err != nil {
+		return err
+	}
+	w.AuditLogger.Log(ctx, models.HoldEntity, constants.Cancel, hold.ID.Hex())
+	return nil
+}
+
+// requeue puts a title hold that had been offered a withdrawn copy back in
+// the queue of its book, keeping its place.
+func (w *Withdrawal) requeue(ctx context.Context, hold models.Hold) error {
+	if err := w.Holds.Update(ctx, hold.ID, bson.M{"copy_barcode": "", "notified": false, "pickup_by": nil}); err != nil {
+		return err
+	}
+	w.AuditLogger.Log(ctx, models.HoldEntity, constants.Update, hold.ID.Hex())
+	return nil
+}
@@ -0,0 +1,104 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/services"
+	"open-library-explorer/internal/store"
+)
+
+func newWithdrawal(stores store.Stores) *services.Withdrawal {
+	return &services.Withdrawal{Books: stores.Books, Copies: stores.Copies, Loans: stores.Loans, Holds: stores.Holds}
+}
+
+func TestWithdrawal_WithdrawCopy(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	withdrawal := newWithdrawal(stores)
+
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-1", ISBN: "9783161484100", Status: models.StatusReserved})
+	copyHold := models.Hold{MemberID: primitive.NewObjectID(), CopyBarcode: "C-1", Timestamp: time.Now()}
+	pickupBy := time.Now().AddDate(0, 0, 2)
+	titleHold := models.Hold{MemberID: primitive.NewObjectID(), ISBN: "9783161484100", CopyBarcode: "C-1",
+		Notified: true, PickupBy: &pickupBy, Timestamp: time.Now().Add(-time.Hour)}
+	stores.Holds.Insert(ctx, &copyHold)
+	stores.Holds.Insert(ctx, &titleHold)
+
+	var deps *services.DependentsError
+	if err := withdrawal.WithdrawCopy(ctx, "C-1", false); !errors.As(err, &deps) || deps.Holds != 2 {
+		t.Fatalf("expected the open holds to block the withdrawal, got %v", err)
+	}
+
+	if err := withdrawal.WithdrawCopy(ctx, "C-1", true); err != nil {
+		t.Fatal(err)
+	}
+	copyObj, _ := stores.Copies.Get(ctx, "C-1")
+	if copyObj.Status != models.StatusWithdrawn || copyObj.WithdrawnAt == nil {
+		t.Errorf("expected the copy to be kept as withdrawn, got %+v", copyObj)
+	}
+	copyHold, _ = stores.Holds.Get(ctx, copyHold.ID)
+	if !copyHold.Cancelled {
+		t.Errorf("expected the copy hold to be cancelled, got %+v", copyHold)
+	}
+	titleHold, _ = stores.Holds.Get(ctx, titleHold.ID)
+	if !titleHold.IsOpen() || titleHold.Notified || titleHold.CopyBarcode != "" || titleHold.PickupBy != nil {
+		t.Errorf("expected the title hold to go back to the queue, got %+v", titleHold)
+	}
+
+	if err := withdrawal.WithdrawCopy(ctx, "C-1", false); err != nil {
+		t.Errorf("expected withdrawing again to be a no-op, got %v", err)
+	}
+}
+
+func TestWithdrawal_WithdrawBook(t *testing.T) {
+	ctx := context.Background()
+	stores := store.NewMemoryStores()
+	withdrawal := newWithdrawal(stores)
+
+	isbn := "9783161484100"
+	stores.Books.Insert(ctx, &models.Book{ISBN: isbn, Title: "Test Book"})
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-1", ISBN: isbn, Status: models.StatusAvailable})
+	stores.Copies.Insert(ctx, &models.Copy{Barcode: "C-2", ISBN: isbn, Status: models.StatusOnLoan})
+	loan := models.Loan{CopyBarcode: "C-2", DueDate: time.Now().AddDate(0, 0, 7)}
+	stores.Loans.Insert(ctx, &loan)
+	hold := models.Hold{MemberID: primitive.NewObjectID(), ISBN: isbn, Timestamp: time.Now()}
+	stores.Holds.Insert(ctx, &hold)
+
+	var deps *services.DependentsError
+	if err := withdrawal.WithdrawBook(ctx, isbn, true); !errors.As(err, &deps) || deps.Loans != 1 {
+		t.Fatalf("expected the open loan to block even a cascade, got %v", err)
+	}
+
+	stores.Loans.Update(ctx, loan.ID, map[string]interface{}{"returned": true})
+	stores.Copies.SetStatus(ctx, "C-2", models.StatusChange{From: models.StatusOnLoan, To: models.StatusAvailable, Trigger: models.TriggerCheckin})
+
+	if err := withdrawal.WithdrawBook(ctx, isbn, false); !errors.As(err, &deps) || deps.Copies != 2 || deps.Holds != 1 {
+		t.Fatalf("expected copies and holds to block the withdrawal, got %v", err)
+	}
+	if err := withdrawal.WithdrawBook(ctx, isbn, true); err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := stores.Books.Get(ctx, isbn)
+	if err != nil || book.WithdrawnAt == nil {
+		t.Errorf("expected the book to be kept as withdrawn, got %+v, %v", book, err)
+	}
+	if n, _ := stores.Books.Count(ctx, store.BookFilter{}); n != 0 {
+		t.Errorf("expected withdrawn books to be left out of queries, got %d", n)
+	}
+	if n, _ := stores.Copies.Count(ctx, store.CopyFilter{Status: models.StatusWithdrawn}); n != 2 {
+		t.Errorf("expected both copies to be withdrawn, got %d", n)
+	}
+	if hold, _ = stores.Holds.Get(ctx, hold.ID); !hold.Cancelled {
+		t.Errorf("expected the title hold to be cancelled, got %+v", hold)
+	}
+	if _, err := stores.Loans.FindOne(ctx, store.LoanFilter{CopyBarcode: "C-2"}); err != nil {
+		t.Errorf("expected the loan history to remain, got %v", err)
+	}
+}
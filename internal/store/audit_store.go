@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"open-library-explorer/internal/models"
+)
+
+type AuditStore interface {
+	// Insert stores entry and assigns its ID when it has none.
+	Insert(ctx context.Context, entry *models.AuditLog) error
+	// FindUnexported returns up to limit entries, oldest first, that still
+	// await export. Dead-lettered entries are skipped; limit <= 0 means all.
+	FindUnexported(ctx context.Context, limit int) ([]models.AuditLog, error)
+	MarkExported(ctx context.Context, ids []primitive.ObjectID) error
+	// RecordFailure counts a failed export attempt against ids and moves the
+	// entries that reached maxAttempts to the dead-letter state.
+	RecordFailure(ctx context.Context, ids []primitive.ObjectID, reason string, maxAttempts int) error
+	FindDeadLetters(ctx context.Context) ([]models.AuditLog, error)
+}
+
+type MongoAuditStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAuditStore(coll *mongo.Collection) *MongoAuditStore {
+	return &MongoAuditStore{coll: coll}
+}
+
+func (s *MongoAuditStore) Insert(ctx context.Context, entry *models.AuditLog) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, entry)
+	return mongoErr(err)
+}
+
+func (s *MongoAuditStore) FindUnexported(ctx context.Context, limit int) ([]models.AuditLog, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	return s.find(ctx, bson.M{"exported": false, "dead_letter": bson.M{"$ne": true}}, opts)
+}
+
+func (s *MongoAuditStore) FindDeadLetters(ctx context.Context) ([]models.AuditLog, error) {
+	return s.find(ctx, bson.M{"dead_letter": true}, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+}
+
+func (s *MongoAuditStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.AuditLog, error) {
+	cursor, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var logs []models.AuditLog
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (s *MongoAuditStore) MarkExported(ctx context.Context, ids []primitive.ObjectID) error {
+	_, err := s.coll.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"exported": true}},
+	)
+	return err
+}
+
+func (s *MongoAuditStore) RecordFailure(ctx context.Context, ids []primitive.ObjectID, reason string, maxAttempts int) error {
+	_, err := s.coll.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"last_error": reason}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.coll.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "attempts": bson.M{"$gte": maxAttempts}},
+		bson.M{"$set": bson.M{"dead_letter": true}},
+	)
+	return err
+}
+
+type MemoryAuditStore struct {
+	mu   sync.RWMutex
+	logs []models.AuditLog
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (s *MemoryAuditStore) Insert(_ context.Context, entry *models.AuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	s.logs = append(s.logs, *entry)
+	return nil
+}
+
+// Entries are kept in insertion order, which is also timestamp order.
+func (s *MemoryAuditStore) FindUnexported(_ context.Context, limit int) ([]models.AuditLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var logs []models.AuditLog
+	for _, entry := range s.logs {
+		if limit > 0 && len(logs) == limit {
+			break
+		}
+		if !entry.Exported && !entry.DeadLetter {
+			logs = append(logs, entry)
+		}
+	}
+	return logs, nil
+}
+
+func (s *MemoryAuditStore) FindDeadLetters(_ context.Context) ([]models.AuditLog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var logs []models.AuditLog
+	for _, entry := range s.logs {
+		if entry.DeadLetter {
+			logs = append(logs, entry)
+		}
+	}
+	return logs, nil
+}
+
+func (s *MemoryAuditStore) MarkExported(_ context.Context, ids []primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.logs {
+		for _, id := range ids {
+			if s.logs[i].ID == id {
+				s.logs[i].Exported = true
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryAuditStore) RecordFailure(_ context.Context, ids []primitive.ObjectID, reason string, maxAttempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.logs {
+		for _, id := range ids {
+			if s.logs[i].ID == id {
+				s.logs[i].Attempts++
+				s.logs[i].LastError = reason
+				if s.logs[i].Attempts >= maxAttempts {
+					s.logs[i].DeadLetter = true
+				}
+			}
+		}
+	}
+	return nil
+}
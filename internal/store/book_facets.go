@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"open-library-explorer/internal/models"
+)
+
+// The values of the availability facet.
+const (
+	FacetAvailable   = "available"
+	FacetUnavailable = "unavailable"
+)
+
+// FacetCount is how many books share one value of a facet.
+type FacetCount struct {
+	Value string `json:"value" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+// BookFacets holds the most common values of each facet, most common first.
+// Decades are named by their first year and ordered by it. Availability
+// splits the books into those with and without an AVAILABLE copy, always in
+// that order.
+type BookFacets struct {
+	Authors    []FacetCount `json:"authors" bson:"authors"`
+	Subjects   []FacetCount `json:"subjects" bson:"subjects"`
+	Tags       []FacetCount `json:"tags" bson:"tags"`
+	Publishers []FacetCount `json:"publishers" bson:"publishers"`
+	Languages  []FacetCount `json:"languages" bson:"languages"`
+	Decades    []FacetCount `json:"decades" bson:"decades"`
+
+	Availability []FacetCount `json:"availability" bson:"availability"`
+}
+
+// facetStages groups the documents flowing in by the value at path and keeps
+// the limit most common.
+func facetStages(limit int, path string, pre ...bson.D) bson.A {
+	stages := bson.A{}
+	for _, stage := range pre {
+		stages = append(stages, stage)
+	}
+	return append(stages,
+		bson.D{{Key: "$match", Value: bson.M{path[1:]: bson.M{"$nin": bson.A{"", nil}}}}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": path, "count": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+}
+
+func (s *MongoBookStore) Facets(ctx context.Context, filter BookFilter, limit int) (BookFacets, error) {
+	unwind := func(path string) bson.D { return bson.D{{Key: "$unwind", Value: path}} }
+	decade := bson.M{"$toString": bson.M{"$subtract": bson.A{"$published_year", bson.M{"$mod": bson.A{"$published_year", 10}}}}}
+
+	available := bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$size": "$_available"}, 0}}, FacetUnavailable, FacetAvailable}}
+
+	pipeline := append(s.matchStages(filter),
+		bson.D{{Key: "$facet", Value: bson.M{
+			"authors": facetStages(limit, "$contributors.name", unwind("$contributors"),
+				bson.D{{Key: "$match", Value: bson.M{"contributors.role": models.ContributorAuthor}}}),
+			"subjects":   facetStages(limit, "$subjects", unwind("$subjects")),
+			"tags":       facetStages(limit, "$tags", unwind("$tags")),
+			"publishers": facetStages(limit, "$publisher"),
+			"languages":  facetStages(limit, "$language"),
+			"decades": bson.A{
+				bson.D{{Key: "$match", Value: bson.M{"published_year": bson.M{"$gt": 0}}}},
+				bson.D{{Key: "$group", Value: bson.M{"_id": decade, "count": bson.M{"$sum": 1}}}},
+				bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}},
+			},
+			"availability": bson.A{
+				s.copyLookup(models.StatusAvailable, "_available"),
+				bson.D{{Key: "$group", Value: bson.M{"_id": available, "count": bson.M{"$sum": 1}}}},
+			},
+		}}},
+	)
+
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return BookFacets{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var facets BookFacets
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&facets)
+	}
+	if err == nil {
+		err = cursor.Err()
+	}
+	facets.Availability = availabilityCounts(facets.Availability)
+	return facets, err
+}
+
+func (s *MemoryBookStore) Facets(ctx context.Context, filter BookFilter, limit int) (BookFacets, error) {
+	books, err := s.Find(ctx, filter)
+	if err != nil {
+		return BookFacets{}, err
+	}
+
+	authors, subjects, tags := map[string]int64{}, map[string]int64{}, map[string]int64{}
+	publishers, languages, decades := map[string]int64{}, map[string]int64{}, map[string]int64{}
+	var available int64
+	for _, book := range books {
+		if s.hasCopies(ctx, book.ISBN, models.StatusAvailable) {
+			available++
+		}
+		for _, name := range book.Authors() {
+			authors[name]++
+		}
+		for _, subject := range book.Subjects {
+			subjects[subject]++
+		}
+		for _, tag := range book.Tags {
+			tags[tag]++
+		}
+		publishers[book.Publisher]++
+		languages[book.Language]++
+		if book.PublishedYear > 0 {
+			decades[strconv.Itoa(book.PublishedYear-book.PublishedYear%10)]++
+		}
+	}
+
+	return BookFacets{
+		Authors:    topCounts(authors, limit),
+		Subjects:   topCounts(subjects, limit),
+		Tags:       topCounts(tags, limit),
+		Publishers: topCounts(publishers, limit),
+		Languages:  topCounts(languages, limit),
+		Decades:    sortedCounts(decades),
+
+		Availability: []FacetCount{
+			{Value: FacetAvailable, Count: available},
+			{Value: FacetUnavailable, Count: int64(len(books)) - available},
+		},
+	}, nil
+}
+
+// availabilityCounts puts the availability facet in order, with a zero count
+// for a value no book has.
+func availabilityCounts(counts []FacetCount) []FacetCount {
+	facet := []FacetCount{{Value: FacetAvailable}, {Value: FacetUnavailable}}
+	for _, c := range counts {
+		for i := range facet {
+			if facet[i].Value == c.Value {
+				facet[i].Count = c.Count
+			}
+		}
+	}
+	return facet
+}
+
+// topCounts returns the limit most common values, ties broken by value.
+func topCounts(counts map[string]int64, limit int) []FacetCount {
+	facet := sortedCounts(counts)
+	sort.SliceStable(facet, func(i, j int) bool { return facet[i].Count > facet[j].Count })
+	if len(facet) > limit {
+		facet = facet[:limit]
+	}
+	return facet
+}
+
+// sortedCounts returns the counts ordered by value, leaving out empty values.
+func sortedCounts(counts map[string]int64) []FacetCount {
+	facet := []FacetCount{}
+	for value, count := range counts {
+		if value != "" {
+			facet = append(facet, FacetCount{Value: value, Count: count})
+		}
+	}
+	sort.Slice(facet, func(i, j int) bool { return facet[i].Value < facet[j].Value })
+	return facet
+}
@@ -0,0 +1,453 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"open-library-explorer/internal/models"
+)
+
+// SortRelevance sorts search results by text score. It needs a Query.
+const SortRelevance = "score"
+
+// BookFilter narrows a book query. Zero values are ignored.
+type BookFilter struct {
+	Query       string   // full-text search over title, contributors, subjects, series and description
+	ISBNs       []string // restrict to these ISBNs when non-nil
+	Contributor string   // exact contributor name, whatever the role
+	Author      string   // exact name of a contributor with the author role
+	Subject     string
+	Tags        []string // books having all of these tags
+	Publisher   string
+	Language    string
+	Series      string
+	YearFrom    int // published_year range, inclusive
+	YearTo      int
+	// CopyStatuses restricts to books with a copy in each of these statuses
+	CopyStatuses []models.CopyStatus
+	// IncludeWithdrawn also matches deleted books, which are left out
+	// otherwise
+	IncludeWithdrawn bool
+}
+
+type BookStore interface {
+	Insert(ctx context.Context, book *models.Book) error
+	Find(ctx context.Context, filter BookFilter) ([]models.Book, error)
+	// FindPage returns one page of the books matching filter and the cursor
+	// of the next page, which is empty on the last one. Sorting by
+	// SortRelevance fills in each book's Score.
+	FindPage(ctx context.Context, filter BookFilter, page Page) ([]models.Book, string, error)
+	Count(ctx context.Context, filter BookFilter) (int64, error)
+	// Facets counts the books matching filter by author, subject, tag,
+	// publisher, language and decade, keeping the limit most common values
+	// of each, and by whether they have an AVAILABLE copy.
+	Facets(ctx context.Context, filter BookFilter, limit int) (BookFacets, error)
+	Get(ctx context.Context, isbn string) (models.Book, error)
+	// Update applies fields as a $set, unsetting those that are nil, and
+	// returns the number of modified books.
+	Update(ctx context.Context, isbn string, fields map[string]interface{}) (int64, error)
+	Delete(ctx context.Context, isbn string) error
+}
+
+type MongoBookStore struct {
+	coll *mongo.Collection
+	// copies is the collection CopyStatuses and the availability facet look
+	// up copies in
+	copies string
+}
+
+func NewMongoBookStore(coll, copies *mongo.Collection) *MongoBookStore {
+	return &MongoBookStore{coll: coll, copies: copies.Name()}
+}
+
+func (f BookFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.Query != "" {
+		filter["$text"] = bson.M{"$search": f.Query}
+	}
+	if f.ISBNs != nil {
+		filter["isbn"] = bson.M{"$in": f.ISBNs}
+	}
+	if f.Contributor != "" {
+		filter["contributors.name"] = f.Contributor
+	}
+	if f.Author != "" {
+		filter["contributors"] = bson.M{"$elemMatch": bson.M{"name": f.Author, "role": models.ContributorAuthor}}
+	}
+	if f.Subject != "" {
+		filter["subjects"] = f.Subject
+	}
+	if len(f.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": f.Tags}
+	}
+	if f.Publisher != "" {
+		filter["publisher"] = f.Publisher
+	}
+	if f.YearFrom != 0 || f.YearTo != 0 {
+		years := bson.M{}
+		if f.YearFrom != 0 {
+			years["$gte"] = f.YearFrom
+		}
+		if f.YearTo != 0 {
+			years["$lte"] = f.YearTo
+		}
+		filter["published_year"] = years
+	}
+	if f.Language != "" {
+		filter["language"] = f.Language
+	}
+	if f.Series != "" {
+		filter["series"] = f.Series
+	}
+	if !f.IncludeWithdrawn {
+		filter["withdrawn_at"] = bson.M{"$exists": false}
+	}
+	return filter
+}
+
+func (s *MongoBookStore) Insert(ctx context.Context, book *models.Book) error {
+	_, err := s.coll.InsertOne(ctx, book)
+	return mongoErr(err)
+}
+
+// copyLookup adds to each book, as field as, one of its copies in status, or
+// none. It relies on the { isbn: 1, status: 1 } index of the copies.
+func (s *MongoBookStore) copyLookup(status models.CopyStatus, as string) bson.D {
+	return bson.D{{Key: "$lookup", Value: bson.M{
+		"from": s.copies,
+		"let":  bson.M{"isbn": "$isbn"},
+		"pipeline": bson.A{
+			bson.M{"$match": bson.M{"$expr": bson.M{"$and": bson.A{
+				bson.M{"$eq": bson.A{"$isbn", "$$isbn"}},
+				bson.M{"$eq": bson.A{"$status", status}},
+			}}}},
+			bson.M{"$limit": 1},
+			bson.M{"$project": bson.M{"_id": 1}},
+		},
+		"as": as,
+	}}}
+}
+
+// matchStages matches filter in an aggregation, which unlike a find can
+// check CopyStatuses. then follows the $match, where a text score is still
+// available.
+func (s *MongoBookStore) matchStages(filter BookFilter, then ...bson.D) mongo.Pipeline {
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: filter.bson()}}}, then...)
+	for i, status := range filter.CopyStatuses {
+		as := "_copy" + strconv.Itoa(i)
+		pipeline = append(pipeline,
+			s.copyLookup(status, as),
+			bson.D{{Key: "$match", Value: bson.M{as: bson.M{"$ne": bson.A{}}}}},
+			bson.D{{Key: "$unset", Value: as}},
+		)
+	}
+	return pipeline
+}
+
+func (s *MongoBookStore) Find(ctx context.Context, filter BookFilter) ([]models.Book, error) {
+	var cursor *mongo.Cursor
+	var err error
+	if len(filter.CopyStatuses) > 0 {
+		cursor, err = s.coll.Aggregate(ctx, s.matchStages(filter))
+	} else {
+		cursor, err = s.coll.Find(ctx, filter.bson())
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var books []models.Book
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (s *MongoBookStore) FindPage(ctx context.Context, filter BookFilter, page Page) ([]models.Book, string, error) {
+	if page.Sort == SortRelevance || len(filter.CopyStatuses) > 0 {
+		return s.findAggregated(ctx, filter, page)
+	}
+
+	query, opts, err := page.mongo(filter.bson(), "isbn")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var books []models.Book
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, "", err
+	}
+	return finishPage(books, page, "isbn")
+}
+
+// findAggregated pages through books as an aggregation, for filters on
+// copies and for sorting by text score, which a cursor cannot filter on and
+// is added as a field first.
+func (s *MongoBookStore) findAggregated(ctx context.Context, filter BookFilter, page Page) ([]models.Book, string, error) {
+	stages, err := page.pipeline("isbn")
+	if err != nil {
+		return nil, "", err
+	}
+	var score []bson.D
+	if page.Sort == SortRelevance {
+		score = append(score, bson.D{{Key: "$addFields", Value: bson.M{SortRelevance: bson.M{"$meta": "textScore"}}}})
+	}
+	pipeline := append(s.matchStages(filter, score...), stages...)
+
+	cursor, err := s.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var books []models.Book
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, "", err
+	}
+	return finishPage(books, page, "isbn")
+}
+
+func (s *MongoBookStore) Count(ctx context.Context, filter BookFilter) (int64, error) {
+	if len(filter.CopyStatuses) == 0 {
+		return s.coll.CountDocuments(ctx, filter.bson())
+	}
+	cursor, err := s.coll.Aggregate(ctx, append(s.matchStages(filter), bson.D{{Key: "$count", Value: "count"}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Count int64 `bson:"count"`
+	}
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&result)
+	}
+	if err == nil {
+		err = cursor.Err()
+	}
+	return result.Count, err
+}
+
+func (s *MongoBookStore) Get(ctx context.Context, isbn string) (models.Book, error) {
+	var book models.Book
+	err := s.coll.FindOne(ctx, bson.M{"isbn": isbn}).Decode(&book)
+	return book, mongoErr(err)
+}
+
+func (s *MongoBookStore) Update(ctx context.Context, isbn string, fields map[string]interface{}) (int64, error) {
+	result, err := s.coll.UpdateOne(ctx, bson.M{"isbn": isbn}, updateDoc(fields))
+	if err != nil {
+		return 0, mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return 0, ErrNotFound
+	}
+	return result.ModifiedCount, nil
+}
+
+func (s *MongoBookStore) Delete(ctx context.Context, isbn string) error {
+	result, err := s.coll.DeleteOne(ctx, bson.M{"isbn": isbn})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type MemoryBookStore struct {
+	mu    sync.RWMutex
+	books []models.Book
+	// Copies answers CopyStatuses and the availability facet; without it no
+	// book has copies
+	Copies CopyStore
+}
+
+func NewMemoryBookStore() *MemoryBookStore {
+	return &MemoryBookStore{}
+}
+
+func (f BookFilter) matches(book models.Book) bool {
+	if !f.IncludeWithdrawn && book.WithdrawnAt != nil {
+		return false
+	}
+	if f.ISBNs != nil && !containsString(f.ISBNs, book.ISBN) {
+		return false
+	}
+	if f.Contributor != "" && !containsString(book.ContributorNames(), f.Contributor) {
+		return false
+	}
+	if f.Author != "" && !containsString(book.Authors(), f.Author) {
+		return false
+	}
+	if f.Subject != "" && !containsString(book.Subjects, f.Subject) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !containsString(book.Tags, tag) {
+			return false
+		}
+	}
+	if f.Publisher != "" && book.Publisher != f.Publisher {
+		return false
+	}
+	if f.YearFrom != 0 && book.PublishedYear < f.YearFrom {
+		return false
+	}
+	if f.YearTo != 0 && book.PublishedYear > f.YearTo {
+		return false
+	}
+	if f.Language != "" && book.Language != f.Language {
+		return false
+	}
+	if f.Series != "" && book.Series != f.Series {
+		return false
+	}
+	if f.Query != "" && textScore(f.Query, book) == 0 {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryBookStore) index(isbn string) int {
+	for i := range s.books {
+		if s.books[i].ISBN == isbn {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryBookStore) Insert(_ context.Context, book *models.Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(book.ISBN) >= 0 {
+		return ErrDuplicate
+	}
+	s.books = append(s.books, *book)
+	return nil
+}
+
+func (s *MemoryBookStore) Find(ctx context.Context, filter BookFilter) ([]models.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var books []models.Book
+	for _, book := range s.books {
+		if filter.matches(book) && s.hasCopies(ctx, book.ISBN, filter.CopyStatuses...) {
+			books = append(books, book)
+		}
+	}
+	return books, nil
+}
+
+// hasCopies reports whether the book isbn has a copy in each of statuses.
+func (s *MemoryBookStore) hasCopies(ctx context.Context, isbn string, statuses ...models.CopyStatus) bool {
+	for _, status := range statuses {
+		if s.Copies == nil {
+			return false
+		}
+		if n, err := s.Copies.Count(ctx, CopyFilter{ISBN: isbn, Status: status}); err != nil || n == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MemoryBookStore) FindPage(ctx context.Context, filter BookFilter, page Page) ([]models.Book, string, error) {
+	books, err := s.Find(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	if page.Sort == SortRelevance {
+		for i := range books {
+			books[i].Score = textScore(filter.Query, books[i])
+		}
+	}
+	return paginate(books, page, "isbn")
+}
+
+func (s *MemoryBookStore) Count(ctx context.Context, filter BookFilter) (int64, error) {
+	books, err := s.Find(ctx, filter)
+	return int64(len(books)), err
+}
+
+func (s *MemoryBookStore) Get(_ context.Context, isbn string) (models.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(isbn)
+	if i < 0 {
+		return models.Book{}, ErrNotFound
+	}
+	return s.books[i], nil
+}
+
+func (s *MemoryBookStore) Update(_ context.Context, isbn string, fields map[string]interface{}) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(isbn)
+	if i < 0 {
+		return 0, ErrNotFound
+	}
+	book := s.books[i]
+	if err := applySet(&book, fields); err != nil {
+		return 0, err
+	}
+	s.books[i] = book
+	return 1, nil
+}
+
+func (s *MemoryBookStore) Delete(_ context.Context, isbn string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(isbn)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.books = append(s.books[:i], s.books[i+1:]...)
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// textScore approximates the score of a Mongo $text search over the fields
+// of the text index: the number of times a word of query appears,
+// case-insensitively, as a word of the book. Zero means no match.
+func textScore(query string, book models.Book) float64 {
+	fields := append([]string{book.Title, book.Series, book.Description}, book.ContributorNames()...)
+	words := map[string]int{}
+	for _, field := range append(fields, book.Subjects...) {
+		for _, w := range strings.Fields(strings.ToLower(field)) {
+			words[w]++
+		}
+	}
+	score := 0
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		score += words[w]
+	}
+	return float64(score)
+}
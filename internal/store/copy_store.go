@@ -0,0 +1,315 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"open-library-explorer/internal/models"
+)
+
+// CopyFilter narrows a copy query. Zero values are ignored.
+type CopyFilter struct {
+	ISBN   string
+	ISBNs  []string // restrict to these ISBNs when non-nil
+	Status models.CopyStatus
+}
+
+type CopyStore interface {
+	// Insert stores copyObj and assigns its ID when it has none.
+	Insert(ctx context.Context, copyObj *models.Copy) error
+	Find(ctx context.Context, filter CopyFilter) ([]models.Copy, error)
+	FindPage(ctx context.Context, filter CopyFilter, page Page) ([]models.Copy, string, error)
+	Get(ctx context.Context, barcode string) (models.Copy, error)
+	// Update applies fields as a $set. The status and its history can only be
+	// changed through SetStatus.
+	Update(ctx context.Context, barcode string, fields map[string]interface{}) error
+	// SetStatus atomically moves a copy from change.From to change.To and
+	// appends change to its history. It returns an error wrapping
+	// models.ErrInvalidTransition when the state machine does not allow the
+	// change, and ErrConflict when the copy is not currently in change.From.
+	SetStatus(ctx context.Context, barcode string, change models.StatusChange) error
+	Delete(ctx context.Context, barcode string) error
+	Count(ctx context.Context, filter CopyFilter) (int64, error)
+}
+
+type MongoCopyStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoCopyStore(coll *mongo.Collection) *MongoCopyStore {
+	return &MongoCopyStore{coll: coll}
+}
+
+// checkCopyFields refuses updates that would bypass SetStatus.
+func checkCopyFields(fields map[string]interface{}) error {
+	for _, field := range []string{"status", "history"} {
+		if _, ok := fields[field]; ok {
+			return fmt.Errorf("%w: %s can only be changed through SetStatus", models.ErrInvalidTransition, field)
+		}
+	}
+	return nil
+}
+
+// prepareChange validates change and stamps it with the current time.
+func prepareChange(change models.StatusChange) (models.StatusChange, error) {
+	if err := change.Validate(); err != nil {
+		return change, err
+	}
+	if change.At.IsZero() {
+		change.At = time.Now()
+	}
+	return change, nil
+}
+
+func (f CopyFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.ISBN != "" {
+		filter["isbn"] = f.ISBN
+	}
+	if f.ISBNs != nil {
+		filter["isbn"] = bson.M{"$in": f.ISBNs}
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	return filter
+}
+
+func (s *MongoCopyStore) Insert(ctx context.Context, copyObj *models.Copy) error {
+	if copyObj.ID.IsZero() {
+		copyObj.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, copyObj)
+	return mongoErr(err)
+}
+
+func (s *MongoCopyStore) Find(ctx context.Context, filter CopyFilter) ([]models.Copy, error) {
+	cursor, err := s.coll.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var copies []models.Copy
+	if err := cursor.All(ctx, &copies); err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+func (s *MongoCopyStore) FindPage(ctx context.Context, filter CopyFilter, page Page) ([]models.Copy, string, error) {
+	query, opts, err := page.mongo(filter.bson(), "barcode")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var copies []models.Copy
+	if err := cursor.All(ctx, &copies); err != nil {
+		return nil, "", err
+	}
+	return finishPage(copies, page, "barcode")
+}
+
+func (s *MongoCopyStore) Get(ctx context.Context, barcode string) (models.Copy, error) {
+	var copyObj models.Copy
+	err := s.coll.FindOne(ctx, bson.M{"barcode": barcode}).Decode(&copyObj)
+	return copyObj, mongoErr(err)
+}
+
+func (s *MongoCopyStore) Update(ctx context.Context, barcode string, fields map[string]interface{}) error {
+	if err := checkCopyFields(fields); err != nil {
+		return err
+	}
+	result, err := s.coll.UpdateOne(ctx, bson.M{"barcode": barcode}, bson.M{"$set": fields})
+	if err != nil {
+		return mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCopyStore) SetStatus(ctx context.Context, barcode string, change models.StatusChange) error {
+	change, err := prepareChange(change)
+	if err != nil {
+		return err
+	}
+	result, err := s.coll.UpdateOne(ctx,
+		bson.M{"barcode": barcode, "status": change.From},
+		bson.M{
+			"$set":  bson.M{"status": change.To, "updated_at": change.At},
+			"$push": bson.M{"history": change},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := s.Get(ctx, barcode); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *MongoCopyStore) Delete(ctx context.Context, barcode string) error {
+	result, err := s.coll.DeleteOne(ctx, bson.M{"barcode": barcode})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCopyStore) Count(ctx context.Context, filter CopyFilter) (int64, error) {
+	return s.coll.CountDocuments(ctx, filter.bson())
+}
+
+type MemoryCopyStore struct {
+	mu     sync.RWMutex
+	copies []models.Copy
+}
+
+func NewMemoryCopyStore() *MemoryCopyStore {
+	return &MemoryCopyStore{}
+}
+
+func (f CopyFilter) matches(copyObj models.Copy) bool {
+	if f.ISBN != "" && copyObj.ISBN != f.ISBN {
+		return false
+	}
+	if f.ISBNs != nil && !containsString(f.ISBNs, copyObj.ISBN) {
+		return false
+	}
+	if f.Status != "" && copyObj.Status != f.Status {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryCopyStore) index(barcode string) int {
+	for i := range s.copies {
+		if s.copies[i].Barcode == barcode {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemoryCopyStore) Insert(_ context.Context, copyObj *models.Copy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(copyObj.Barcode) >= 0 {
+		return ErrDuplicate
+	}
+	if copyObj.ID.IsZero() {
+		copyObj.ID = primitive.NewObjectID()
+	}
+	s.copies = append(s.copies, *copyObj)
+	return nil
+}
+
+func (s *MemoryCopyStore) Find(_ context.Context, filter CopyFilter) ([]models.Copy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var copies []models.Copy
+	for _, copyObj := range s.copies {
+		if filter.matches(copyObj) {
+			copies = append(copies, copyObj)
+		}
+	}
+	return copies, nil
+}
+
+func (s *MemoryCopyStore) FindPage(ctx context.Context, filter CopyFilter, page Page) ([]models.Copy, string, error) {
+	copies, err := s.Find(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	return paginate(copies, page, "barcode")
+}
+
+func (s *MemoryCopyStore) Get(_ context.Context, barcode string) (models.Copy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(barcode)
+	if i < 0 {
+		return models.Copy{}, ErrNotFound
+	}
+	return s.copies[i], nil
+}
+
+func (s *MemoryCopyStore) Update(_ context.Context, barcode string, fields map[string]interface{}) error {
+	if err := checkCopyFields(fields); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(barcode)
+	if i < 0 {
+		return ErrNotFound
+	}
+	copyObj := s.copies[i]
+	if err := applySet(&copyObj, fields); err != nil {
+		return err
+	}
+	s.copies[i] = copyObj
+	return nil
+}
+
+func (s *MemoryCopyStore) SetStatus(_ context.Context, barcode string, change models.StatusChange) error {
+	change, err := prepareChange(change)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(barcode)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.copies[i].Status != change.From {
+		return ErrConflict
+	}
+	s.copies[i].Status = change.To
+	s.copies[i].UpdatedAt = change.At
+	s.copies[i].History = append(s.copies[i].History, change)
+	return nil
+}
+
+func (s *MemoryCopyStore) Delete(_ context.Context, barcode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(barcode)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.copies = append(s.copies[:i], s.copies[i+1:]...)
+	return nil
+}
+
+func (s *MemoryCopyStore) Count(ctx context.Context, filter CopyFilter) (int64, error) {
+	copies, _ := s.Find(ctx, filter)
+	return int64(len(copies)), nil
+}
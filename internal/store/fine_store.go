@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"open-library-explorer/internal/models"
+)
+
+// FineFilter narrows a fine query. Zero values are ignored.
+type FineFilter struct {
+	MemberID primitive.ObjectID
+	LoanID   primitive.ObjectID
+	Status   models.FineStatus
+}
+
+// FineStore returns fines oldest first.
+type FineStore interface {
+	// Insert stores fine and assigns its ID when it has none.
+	Insert(ctx context.Context, fine *models.Fine) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Fine, error)
+	Find(ctx context.Context, filter FineFilter) ([]models.Fine, error)
+	FindPage(ctx context.Context, filter FineFilter, page Page) ([]models.Fine, string, error)
+	Count(ctx context.Context, filter FineFilter) (int64, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	// UpdateIf applies fields as a $set, and appends payment when it is not
+	// nil, only while the fine still has the status and amount paid of read.
+	// It returns ErrConflict when the fine changed since read was loaded.
+	UpdateIf(ctx context.Context, read models.Fine, fields map[string]interface{}, payment *models.FinePayment) error
+}
+
+type MongoFineStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoFineStore(coll *mongo.Collection) *MongoFineStore {
+	return &MongoFineStore{coll: coll}
+}
+
+func (f FineFilter) bson() bson.M {
+	filter := bson.M{}
+	if !f.MemberID.IsZero() {
+		filter["member_id"] = f.MemberID
+	}
+	if !f.LoanID.IsZero() {
+		filter["loan_id"] = f.LoanID
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	return filter
+}
+
+func (s *MongoFineStore) Insert(ctx context.Context, fine *models.Fine) error {
+	if fine.ID.IsZero() {
+		fine.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, fine)
+	return mongoErr(err)
+}
+
+func (s *MongoFineStore) Get(ctx context.Context, id primitive.ObjectID) (models.Fine, error) {
+	var fine models.Fine
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&fine)
+	return fine, mongoErr(err)
+}
+
+func (s *MongoFineStore) Find(ctx context.Context, filter FineFilter) ([]models.Fine, error) {
+	cursor, err := s.coll.Find(ctx, filter.bson(), options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var fines []models.Fine
+	if err := cursor.All(ctx, &fines); err != nil {
+		return nil, err
+	}
+	return fines, nil
+}
+
+func (s *MongoFineStore) FindPage(ctx context.Context, filter FineFilter, page Page) ([]models.Fine, string, error) {
+	query, opts, err := page.mongo(filter.bson(), "_id")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var fines []models.Fine
+	if err := cursor.All(ctx, &fines); err != nil {
+		return nil, "", err
+	}
+	return finishPage(fines, page, "_id")
+}
+
+func (s *MongoFineStore) Count(ctx context.Context, filter FineFilter) (int64, error) {
+	return s.coll.CountDocuments(ctx, filter.bson())
+}
+
+func (s *MongoFineStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := s.coll.UpdateByID(ctx, id, bson.M{"$set": fields})
+	if err != nil {
+		return mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoFineStore) UpdateIf(ctx context.Context, read models.Fine, fields map[string]interface{}, payment *models.FinePayment) error {
+	update := bson.M{"$set": fields}
+	if payment != nil {
+		update["$push"] = bson.M{"payments": payment}
+	}
+	result, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": read.ID, "status": read.Status, "amount_paid": read.AmountPaid},
+		update,
+	)
+	if err != nil {
+		return mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		if _, err := s.Get(ctx, read.ID); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+type MemoryFineStore struct {
+	mu    sync.RWMutex
+	fines []models.Fine
+}
+
+func NewMemoryFineStore() *MemoryFineStore {
+	return &MemoryFineStore{}
+}
+
+func (f FineFilter) matches(fine models.Fine) bool {
+	if !f.MemberID.IsZero() && fine.MemberID != f.MemberID {
+		return false
+	}
+	if !f.LoanID.IsZero() && fine.LoanID != f.LoanID {
+		return false
+	}
+	if f.Status != "" && fine.Status != f.Status {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryFineStore) Insert(_ context.Context, fine *models.Fine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fine.ID.IsZero() {
+		fine.ID = primitive.NewObjectID()
+	}
+	for _, f := range s.fines {
+		if f.ID == fine.ID {
+			return ErrDuplicate
+		}
+	}
+	s.fines = append(s.fines, *fine)
+	return nil
+}
+
+func (s *MemoryFineStore) Get(_ context.Context, id primitive.ObjectID) (models.Fine, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, fine := range s.fines {
+		if fine.ID == id {
+			return fine, nil
+		}
+	}
+	return models.Fine{}, ErrNotFound
+}
+
+func (s *MemoryFineStore) Find(_ context.Context, filter FineFilter) ([]models.Fine, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fines []models.Fine
+	for _, fine := range s.fines {
+		if filter.matches(fine) {
+			fines = append(fines, fine)
+		}
+	}
+	return fines, nil
+}
+
+func (s *MemoryFineStore) FindPage(ctx context.Context, filter FineFilter, page Page) ([]models.Fine, string, error) {
+	fines, err := s.Find(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	return paginate(fines, page, "_id")
+}
+
+func (s *MemoryFineStore) Count(ctx context.Context, filter FineFilter) (int64, error) {
+	fines, _ := s.Find(ctx, filter)
+	return int64(len(fines)), nil
+}
+
+func (s *MemoryFineStore) Update(_ context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.fines {
+		if s.fines[i].ID == id {
+			fine := s.fines[i]
+			if err := applySet(&fine, fields); err != nil {
+				return err
+			}
+			s.fines[i] = fine
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryFineStore) UpdateIf(_ context.Context, read models.Fine, fields map[string]interface{}, payment *models.FinePayment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.fines {
+		if s.fines[i].ID != read.ID {
+			continue
+		}
+		fine := s.fines[i]
+		if fine.Status != read.Status || fine.AmountPaid != read.AmountPaid {
+			return ErrConflict
+		}
+		if payment != nil {
+			set := bson.M{"payments": append(append([]models.FinePayment{}, fine.Payments...), *payment)}
+			for k, v := range fields {
+				set[k] = v
+			}
+			fields = set
+		}
+		if err := applySet(&fine, fields); err != nil {
+			return err
+		}
+		s.fines[i] = fine
+		return nil
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"open-library-explorer/internal/models"
+)
+
+// HoldFilter narrows a hold query. Zero and nil values are ignored.
+type HoldFilter struct {
+	MemberID     primitive.ObjectID
+	CopyBarcode  string
+	CopyBarcodes []string // restrict to these copies when non-nil
+	ISBN         string   // title holds on this book
+	Unassigned   bool     // only title holds not yet assigned a copy
+	Open         bool     // only holds that are not fulfilled, cancelled or expired
+	Fulfilled    *bool
+	Notified     *bool
+	PickupBefore time.Time
+	Queues       *HoldQueues
+}
+
+// HoldQueues selects whole hold queues: the holds on any of Copies and the
+// title holds on any of ISBNs still waiting for a copy.
+type HoldQueues struct {
+	Copies []string
+	ISBNs  []string
+}
+
+// HoldStore returns holds oldest first, which is the order of the queue.
+type HoldStore interface {
+	// Insert stores hold and assigns its ID when it has none.
+	Insert(ctx context.Context, hold *models.Hold) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Hold, error)
+	FindOne(ctx context.Context, filter HoldFilter) (models.Hold, error)
+	Find(ctx context.Context, filter HoldFilter) ([]models.Hold, error)
+	FindPage(ctx context.Context, filter HoldFilter, page Page) ([]models.Hold, string, error)
+	Count(ctx context.Context, filter HoldFilter) (int64, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+}
+
+type MongoHoldStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoHoldStore(coll *mongo.Collection) *MongoHoldStore {
+	return &MongoHoldStore{coll: coll}
+}
+
+var holdQueueOrder = bson.D{{Key: "timestamp", Value: 1}}
+
+func (f HoldFilter) bson() bson.M {
+	filter := bson.M{}
+	if !f.MemberID.IsZero() {
+		filter["member_id"] = f.MemberID
+	}
+	if f.CopyBarcode != "" {
+		filter["copy_barcode"] = f.CopyBarcode
+	}
+	if f.CopyBarcodes != nil {
+		filter["copy_barcode"] = bson.M{"$in": f.CopyBarcodes}
+	}
+	if f.ISBN != "" {
+		filter["isbn"] = f.ISBN
+	}
+	if f.Unassigned {
+		filter["copy_barcode"] = ""
+	}
+	if f.Open {
+		// $ne so holds stored before cancelled/expired existed still match
+		filter["fulfilled"] = false
+		filter["cancelled"] = bson.M{"$ne": true}
+		filter["expired"] = bson.M{"$ne": true}
+	}
+	if f.Fulfilled != nil {
+		filter["fulfilled"] = *f.Fulfilled
+	}
+	if f.Notified != nil {
+		filter["notified"] = *f.Notified
+	}
+	if !f.PickupBefore.IsZero() {
+		filter["pickup_by"] = bson.M{"$lt": f.PickupBefore}
+	}
+	if f.Queues != nil {
+		filter["$or"] = bson.A{
+			bson.M{"copy_barcode": bson.M{"$in": f.Queues.Copies}},
+			bson.M{"isbn": bson.M{"$in": f.Queues.ISBNs}, "copy_barcode": ""},
+		}
+	}
+	return filter
+}
+
+func (s *MongoHoldStore) Insert(ctx context.Context, hold *models.Hold) error {
+	if hold.ID.IsZero() {
+		hold.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, hold)
+	return mongoErr(err)
+}
+
+func (s *MongoHoldStore) Get(ctx context.Context, id primitive.ObjectID) (models.Hold, error) {
+	var hold models.Hold
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&hold)
+	return hold, mongoErr(err)
+}
+
+func (s *MongoHoldStore) FindOne(ctx context.Context, filter HoldFilter) (models.Hold, error) {
+	var hold models.Hold
+	err := s.coll.FindOne(ctx, filter.bson(), options.FindOne().SetSort(holdQueueOrder)).Decode(&hold)
+	return hold, mongoErr(err)
+}
+
+func (s *MongoHoldStore) Find(ctx context.Context, filter HoldFilter) ([]models.Hold, error) {
+	cursor, err := s.coll.Find(ctx, filter.bson(), options.Find().SetSort(holdQueueOrder))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var holds []models.Hold
+	if err := cursor.All(ctx, &holds); err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func (s *MongoHoldStore) FindPage(ctx context.Context, filter HoldFilter, page Page) ([]models.Hold, string, error) {
+	query, opts, err := page.mongo(filter.bson(), "_id")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var holds []models.Hold
+	if err := cursor.All(ctx, &holds); err != nil {
+		return nil, "", err
+	}
+	return finishPage(holds, page, "_id")
+}
+
+func (s *MongoHoldStore) Count(ctx context.Context, filter HoldFilter) (int64, error) {
+	return s.coll.CountDocuments(ctx, filter.bson())
+}
+
+func (s *MongoHoldStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := s.coll.UpdateByID(ctx, id, bson.M{"$set": fields})
+	if err != nil {
+		return mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type MemoryHoldStore struct {
+	mu    sync.RWMutex
+	holds []models.Hold
+}
+
+func NewMemoryHoldStore() *MemoryHoldStore {
+	return &MemoryHoldStore{}
+}
+
+func (f HoldFilter) matches(hold models.Hold) bool {
+	if !f.MemberID.IsZero() && hold.MemberID != f.MemberID {
+		return false
+	}
+	if f.CopyBarcode != "" && hold.CopyBarcode != f.CopyBarcode {
+		return false
+	}
+	if f.CopyBarcodes != nil && !containsString(f.CopyBarcodes, hold.CopyBarcode) {
+		return false
+	}
+	if f.ISBN != "" && hold.ISBN != f.ISBN {
+		return false
+	}
+	if f.Unassigned && hold.CopyBarcode != "" {
+		return false
+	}
+	if f.Open && !hold.IsOpen() {
+		return false
+	}
+	if f.Fulfilled != nil && hold.Fulfilled != *f.Fulfilled {
+		return false
+	}
+	if f.Notified != nil && hold.Notified != *f.Notified {
+		return false
+	}
+	if !f.PickupBefore.IsZero() && (hold.PickupBy == nil || !hold.PickupBy.Before(f.PickupBefore)) {
+		return false
+	}
+	if f.Queues != nil && !containsString(f.Queues.Copies, hold.CopyBarcode) &&
+		(hold.CopyBarcode != "" || !containsString(f.Queues.ISBNs, hold.ISBN)) {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryHoldStore) Insert(_ context.Context, hold *models.Hold) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hold.ID.IsZero() {
+		hold.ID = primitive.NewObjectID()
+	}
+	for _, h := range s.holds {
+		if h.ID == hold.ID {
+			return ErrDuplicate
+		}
+	}
+	s.holds = append(s.holds, *hold)
+	sort.SliceStable(s.holds, func(i, j int) bool {
+		return s.holds[i].Timestamp.Before(s.holds[j].Timestamp)
+	})
+	return nil
+}
+
+func (s *MemoryHoldStore) Get(_ context.Context, id primitive.ObjectID) (models.Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, hold := range s.holds {
+		if hold.ID == id {
+			return hold, nil
+		}
+	}
+	return models.Hold{}, ErrNotFound
+}
+
+func (s *MemoryHoldStore) FindOne(ctx context.Context, filter HoldFilter) (models.Hold, error) {
+	holds, _ := s.Find(ctx, filter)
+	if len(holds) == 0 {
+		return models.Hold{}, ErrNotFound
+	}
+	return holds[0], nil
+}
+
+func (s *MemoryHoldStore) Find(_ context.Context, filter HoldFilter) ([]models.Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var holds []models.Hold
+	for _, hold := range s.holds {
+		if filter.matches(hold) {
+			holds = append(holds, hold)
+		}
+	}
+	return holds, nil
+}
+
+func (s *MemoryHoldStore) FindPage(ctx context.Context, filter HoldFilter, page Page) ([]models.Hold, string, error) {
+	holds, err := s.Find(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	return paginate(holds, page, "_id")
+}
+
+func (s *MemoryHoldStore) Count(ctx context.Context, filter HoldFilter) (int64, error) {
+	holds, _ := s.Find(ctx, filter)
+	return int64(len(holds)), nil
+}
+
+func (s *MemoryHoldStore) Update(_ context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.holds {
+		if s.holds[i].ID == id {
+			hold := s.holds[i]
+			if err := applySet(&hold, fields); err != nil {
+				return err
+			}
+			s.holds[i] = hold
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"open-library-explorer/internal/models"
+)
+
+// LoanFilter narrows a loan query. Zero and nil values are ignored.
+type LoanFilter struct {
+	ID           primitive.ObjectID
+	MemberID     primitive.ObjectID
+	CopyBarcode  string
+	CopyBarcodes []string // restrict to these copies when non-nil
+	Returned     *bool
+	DueBefore    time.Time
+	LoanedSince  time.Time
+}
+
+type LoanStore interface {
+	// Insert stores loan and assigns its ID when it has none. A copy can have
+	// only one unreturned loan; a second one fails with ErrDuplicate.
+	Insert(ctx context.Context, loan *models.Loan) error
+	FindOne(ctx context.Context, filter LoanFilter) (models.Loan, error)
+	Find(ctx context.Context, filter LoanFilter) ([]models.Loan, error)
+	FindPage(ctx context.Context, filter LoanFilter, page Page) ([]models.Loan, string, error)
+	Count(ctx context.Context, filter LoanFilter) (int64, error)
+	// CountByCopy returns how many loans, returned or not, each of barcodes
+	// has had. Copies never loaned are left out.
+	CountByCopy(ctx context.Context, barcodes []string) (map[string]int64, error)
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	// FindOneAndUpdate applies fields to the first loan matching filter and
+	// returns that loan as it was before the update.
+	FindOneAndUpdate(ctx context.Context, filter LoanFilter, fields map[string]interface{}) (models.Loan, error)
+}
+
+type MongoLoanStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoLoanStore(coll *mongo.Collection) *MongoLoanStore {
+	return &MongoLoanStore{coll: coll}
+}
+
+func (f LoanFilter) bson() bson.M {
+	filter := bson.M{}
+	if !f.ID.IsZero() {
+		filter["_id"] = f.ID
+	}
+	if !f.MemberID.IsZero() {
+		filter["member_id"] = f.MemberID
+	}
+	if f.CopyBarcode != "" {
+		filter["copy_barcode"] = f.CopyBarcode
+	}
+	if f.CopyBarcodes != nil {
+		filter["copy_barcode"] = bson.M{"$in": f.CopyBarcodes}
+	}
+	if f.Returned != nil {
+		filter["returned"] = *f.Returned
+	}
+	if !f.DueBefore.IsZero() {
+		filter["due_date"] = bson.M{"$lt": f.DueBefore}
+	}
+	if !f.LoanedSince.IsZero() {
+		filter["loan_date"] = bson.M{"$gte": f.LoanedSince}
+	}
+	return filter
+}
+
+func (s *MongoLoanStore) Insert(ctx context.Context, loan *models.Loan) error {
+	if loan.ID.IsZero() {
+		loan.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, loan)
+	return mongoErr(err)
+}
+
+func (s *MongoLoanStore) FindOne(ctx context.Context, filter LoanFilter) (models.Loan, error) {
+	var loan models.Loan
+	err := s.coll.FindOne(ctx, filter.bson()).Decode(&loan)
+	return loan, mongoErr(err)
+}
+
+func (s *MongoLoanStore) Find(ctx context.Context, filter LoanFilter) ([]models.Loan, error) {
+	cursor, err := s.coll.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var loans []models.Loan
+	if err := cursor.All(ctx, &loans); err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+func (s *MongoLoanStore) FindPage(ctx context.Context, filter LoanFilter, page Page) ([]models.Loan, string, error) {
+	query, opts, err := page.mongo(filter.bson(), "_id")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var loans []models.Loan
+	if err := cursor.All(ctx, &loans); err != nil {
+		return nil, "", err
+	}
+	return finishPage(loans, page, "_id")
+}
+
+func (s *MongoLoanStore) Count(ctx context.Context, filter LoanFilter) (int64, error) {
+	return s.coll.CountDocuments(ctx, filter.bson())
+}
+
+func (s *MongoLoanStore) CountByCopy(ctx context.Context, barcodes []string) (map[string]int64, error) {
+	cursor, err := s.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"copy_barcode": bson.M{"$in": barcodes}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$copy_barcode", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Barcode string `bson:"_id"`
+		Count   int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Barcode] = row.Count
+	}
+	return counts, nil
+}
+
+func (s *MongoLoanStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := s.coll.UpdateByID(ctx, id, bson.M{"$set": fields})
+	if err != nil {
+		return mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoLoanStore) FindOneAndUpdate(ctx context.Context, filter LoanFilter, fields map[string]interface{}) (models.Loan, error) {
+	var loan models.Loan
+	err := s.coll.FindOneAndUpdate(ctx, filter.bson(), bson.M{"$set": fields}).Decode(&loan)
+	return loan, mongoErr(err)
+}
+
+type MemoryLoanStore struct {
+	mu    sync.RWMutex
+	loans []models.Loan
+}
+
+func NewMemoryLoanStore() *MemoryLoanStore {
+	return &MemoryLoanStore{}
+}
+
+func (f LoanFilter) matches(loan models.Loan) bool {
+	if !f.ID.IsZero() && loan.ID != f.ID {
+		return false
+	}
+	if !f.MemberID.IsZero() && loan.MemberID != f.MemberID {
+		return false
+	}
+	if f.CopyBarcode != "" && loan.CopyBarcode != f.CopyBarcode {
+		return false
+	}
+	if f.CopyBarcodes != nil && !containsString(f.CopyBarcodes, loan.CopyBarcode) {
+		return false
+	}
+	if f.Returned != nil && loan.Returned != *f.Returned {
+		return false
+	}
+	if !f.DueBefore.IsZero() && !loan.DueDate.Before(f.DueBefore) {
+		return false
+	}
+	if !f.LoanedSince.IsZero() && loan.LoanDate.Before(f.LoanedSince) {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryLoanStore) Insert(_ context.Context, loan *models.Loan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if loan.ID.IsZero() {
+		loan.ID = primitive.NewObjectID()
+	}
+	for _, l := range s.loans {
+		if l.ID == loan.ID {
+			return ErrDuplicate
+		}
+		// mirrors the partial unique index on copy_barcode for open loans
+		if !loan.Returned && !l.Returned && l.CopyBarcode == loan.CopyBarcode {
+			return ErrDuplicate
+		}
+	}
+	s.loans = append(s.loans, *loan)
+	return nil
+}
+
+func (s *MemoryLoanStore) FindOne(_ context.Context, filter LoanFilter) (models.Loan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, loan := range s.loans {
+		if filter.matches(loan) {
+			return loan, nil
+		}
+	}
+	return models.Loan{}, ErrNotFound
+}
+
+func (s *MemoryLoanStore) Find(_ context.Context, filter LoanFilter) ([]models.Loan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var loans []models.Loan
+	for _, loan := range s.loans {
+		if filter.matches(loan) {
+			loans = append(loans, loan)
+		}
+	}
+	return loans, nil
+}
+
+func (s *MemoryLoanStore) CountByCopy(_ context.Context, barcodes []string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int64{}
+	for _, loan := range s.loans {
+		if containsString(barcodes, loan.CopyBarcode) {
+			counts[loan.CopyBarcode]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *MemoryLoanStore) FindPage(ctx context.Context, filter LoanFilter, page Page) ([]models.Loan, string, error) {
+	loans, err := s.Find(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	return paginate(loans, page, "_id")
+}
+
+func (s *MemoryLoanStore) Count(ctx context.Context, filter LoanFilter) (int64, error) {
+	loans, _ := s.Find(ctx, filter)
+	return int64(len(loans)), nil
+}
+
+func (s *MemoryLoanStore) Update(_ context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.loans {
+		if s.loans[i].ID == id {
+			loan := s.loans[i]
+			if err := applySet(&loan, fields); err != nil {
+				return err
+			}
+			s.loans[i] = loan
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryLoanStore) FindOneAndUpdate(_ context.Context, filter LoanFilter, fields map[string]interface{}) (models.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.loans {
+		if filter.matches(s.loans[i]) {
+			before := s.loans[i]
+			loan := before
+			if err := applySet(&loan, fields); err != nil {
+				return models.Loan{}, err
+			}
+			s.loans[i] = loan
+			return before, nil
+		}
+	}
+	return models.Loan{}, ErrNotFound
+}
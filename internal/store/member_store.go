@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"open-library-explorer/internal/models"
+)
+
+// MemberFilter narrows a member query. Zero and nil values are ignored.
+type MemberFilter struct {
+	Blocked *bool
+	Query   string // case-insensitive substring of the name, email or phone
+	Name    string // case-insensitive substring of the name
+	Email   string // whole email, ignoring case
+	Phone   string
+	// CardNumber is the bare digits, as models.NormalizeCardNumber returns them
+	CardNumber string
+	// Status is the stored status; MemberActive also matches members
+	// registered before statuses existed
+	Status models.MemberStatus
+	// SuspensionEndedBy keeps suspensions with an end date no later than this
+	SuspensionEndedBy time.Time
+	// ExpiresBy and ExpiresAfter bound the membership expiry date, the first
+	// inclusively; members without one never match
+	ExpiresBy      time.Time
+	ExpiresAfter   time.Time
+	ExpiryNotified *bool
+}
+
+type MemberStore interface {
+	// Insert stores member and assigns its ID when it has none. Emails, and
+	// phones and card numbers when set, are unique; a taken one fails with
+	// ErrDuplicate, as does an Update that would take one.
+	Insert(ctx context.Context, member *models.Member) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Member, error)
+	FindOne(ctx context.Context, filter MemberFilter) (models.Member, error)
+	// FindPage returns one page of the members matching filter and the
+	// cursor of the next page, which is empty on the last one.
+	FindPage(ctx context.Context, filter MemberFilter, page Page) ([]models.Member, string, error)
+	// Update sets fields on member id; fields set to nil are removed.
+	Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error
+	Count(ctx context.Context, filter MemberFilter) (int64, error)
+}
+
+type MongoMemberStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoMemberStore(coll *mongo.Collection) *MongoMemberStore {
+	return &MongoMemberStore{coll: coll}
+}
+
+func (f MemberFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.Blocked != nil {
+		filter["blocked"] = *f.Blocked
+	}
+	switch f.Status {
+	case "":
+	case models.MemberActive:
+		filter["status"] = bson.M{"$in": bson.A{models.MemberActive, nil}}
+	default:
+		filter["status"] = f.Status
+	}
+	if !f.SuspensionEndedBy.IsZero() {
+		filter["suspension.until"] = bson.M{"$lte": f.SuspensionEndedBy}
+	}
+	if !f.ExpiresBy.IsZero() || !f.ExpiresAfter.IsZero() {
+		expiry := bson.M{"$exists": true}
+		if !f.ExpiresBy.IsZero() {
+			expiry["$lte"] = f.ExpiresBy
+		}
+		if !f.ExpiresAfter.IsZero() {
+			expiry["$gt"] = f.ExpiresAfter
+		}
+		filter["membership_expiry"] = expiry
+	}
+	if f.ExpiryNotified != nil {
+		filter["expiry_notice_sent"] = bson.M{"$exists": *f.ExpiryNotified}
+	}
+	if f.Query != "" {
+		pattern := containsPattern(f.Query)
+		filter["$or"] = bson.A{
+			bson.M{"name": pattern},
+			bson.M{"email": pattern},
+			bson.M{"phone": pattern},
+		}
+	}
+	if f.Name != "" {
+		filter["name"] = containsPattern(f.Name)
+	}
+	if f.Email != "" {
+		filter["email"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Email) + "$", Options: "i"}
+	}
+	if f.Phone != "" {
+		filter["phone"] = f.Phone
+	}
+	if f.CardNumber != "" {
+		filter["card_number"] = f.CardNumber
+	}
+	return filter
+}
+
+// containsPattern matches values containing s, ignoring case.
+func containsPattern(s string) primitive.Regex {
+	return primitive.Regex{Pattern: regexp.QuoteMeta(s), Options: "i"}
+}
+
+func (s *MongoMemberStore) Insert(ctx context.Context, member *models.Member) error {
+	if member.ID.IsZero() {
+		member.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, member)
+	return mongoErr(err)
+}
+
+func (s *MongoMemberStore) Get(ctx context.Context, id primitive.ObjectID) (models.Member, error) {
+	var member models.Member
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&member)
+	return member, mongoErr(err)
+}
+
+func (s *MongoMemberStore) FindOne(ctx context.Context, filter MemberFilter) (models.Member, error) {
+	var member models.Member
+	err := s.coll.FindOne(ctx, filter.bson()).Decode(&member)
+	return member, mongoErr(err)
+}
+
+func (s *MongoMemberStore) FindPage(ctx context.Context, filter MemberFilter, page Page) ([]models.Member, string, error) {
+	query, opts, err := page.mongo(filter.bson(), "_id")
+	if err != nil {
+		return nil, "", err
+	}
+	cursor, err := s.coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var members []models.Member
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, "", err
+	}
+	return finishPage(members, page, "_id")
+}
+
+func (s *MongoMemberStore) Update(ctx context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	result, err := s.coll.UpdateByID(ctx, id, updateDoc(fields))
+	if err != nil {
+		return mongoErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoMemberStore) Count(ctx context.Context, filter MemberFilter) (int64, error) {
+	return s.coll.CountDocuments(ctx, filter.bson())
+}
+
+type MemoryMemberStore struct {
+	mu      sync.RWMutex
+	members []models.Member
+}
+
+func NewMemoryMemberStore() *MemoryMemberStore {
+	return &MemoryMemberStore{}
+}
+
+func (f MemberFilter) matches(member models.Member) bool {
+	if f.Blocked != nil && member.Blocked != *f.Blocked {
+		return false
+	}
+	if f.Status != "" && member.Status != f.Status && !(f.Status == models.MemberActive && member.Status == "") {
+		return false
+	}
+	if !f.SuspensionEndedBy.IsZero() && (member.Suspension == nil || member.Suspension.Until == nil ||
+		member.Suspension.Until.After(f.SuspensionEndedBy)) {
+		return false
+	}
+	if (!f.ExpiresBy.IsZero() || !f.ExpiresAfter.IsZero()) && member.MembershipExpiry == nil {
+		return false
+	}
+	if !f.ExpiresBy.IsZero() && member.MembershipExpiry.After(f.ExpiresBy) {
+		return false
+	}
+	if !f.ExpiresAfter.IsZero() && !member.MembershipExpiry.After(f.ExpiresAfter) {
+		return false
+	}
+	if f.ExpiryNotified != nil && (member.ExpiryNoticeSent != nil) != *f.ExpiryNotified {
+		return false
+	}
+	if f.Query != "" && !containsFold(member.Name, f.Query) && !containsFold(member.Email, f.Query) &&
+		!containsFold(member.Phone, f.Query) {
+		return false
+	}
+	if f.Name != "" && !containsFold(member.Name, f.Name) {
+		return false
+	}
+	if f.Email != "" && !strings.EqualFold(member.Email, f.Email) {
+		return false
+	}
+	if f.Phone != "" && member.Phone != f.Phone {
+		return false
+	}
+	if f.CardNumber != "" && member.CardNumber != f.CardNumber {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (s *MemoryMemberStore) index(id primitive.ObjectID) int {
+	for i := range s.members {
+		if s.members[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// taken reports whether a member other than member already has its email,
+// phone or card number.
+func (s *MemoryMemberStore) taken(member models.Member) bool {
+	for _, m := range s.members {
+		if m.ID == member.ID {
+			continue
+		}
+		if member.Email != "" && strings.EqualFold(m.Email, member.Email) ||
+			member.Phone != "" && m.Phone == member.Phone ||
+			member.CardNumber != "" && m.CardNumber == member.CardNumber {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryMemberStore) Insert(_ context.Context, member *models.Member) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if member.ID.IsZero() {
+		member.ID = primitive.NewObjectID()
+	}
+	if s.index(member.ID) >= 0 || s.taken(*member) {
+		return ErrDuplicate
+	}
+	s.members = append(s.members, *member)
+	return nil
+}
+
+func (s *MemoryMemberStore) Get(_ context.Context, id primitive.ObjectID) (models.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return models.Member{}, ErrNotFound
+	}
+	return s.members[i], nil
+}
+
+func (s *MemoryMemberStore) FindOne(_ context.Context, filter MemberFilter) (models.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, member := range s.members {
+		if filter.matches(member) {
+			return member, nil
+		}
+	}
+	return models.Member{}, ErrNotFound
+}
+
+func (s *MemoryMemberStore) FindPage(_ context.Context, filter MemberFilter, page Page) ([]models.Member, string, error) {
+	s.mu.RLock()
+	var members []models.Member
+	for _, member := range s.members {
+		if filter.matches(member) {
+			members = append(members, member)
+		}
+	}
+	s.mu.RUnlock()
+	return paginate(members, page, "_id")
+}
+
+func (s *MemoryMemberStore) Update(_ context.Context, id primitive.ObjectID, fields map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	member := s.members[i]
+	if err := applySet(&member, fields); err != nil {
+		return err
+	}
+	if s.taken(member) {
+		return ErrDuplicate
+	}
+	s.members[i] = member
+	return nil
+}
+
+func (s *MemoryMemberStore) Count(_ context.Context, filter MemberFilter) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var n int64
+	for _, member := range s.members {
+		if filter.matches(member) {
+			n++
+		}
+	}
+	return n, nil
+}
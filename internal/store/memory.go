@@ -0,0 +1,36 @@
+package store
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// applySet applies a Mongo style $set of bson field names to dst, which must
+// be a pointer to a model struct. A nil value removes the field, as
+// updateDoc turns it into an $unset. The in-memory stores use it so that
+// updates behave the same way they do against a real collection.
+func applySet(dst interface{}, fields map[string]interface{}) error {
+	raw, err := bson.Marshal(dst)
+	if err != nil {
+		return err
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		doc[k] = v
+	}
+	raw, err = bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(dst).Elem()
+	target.Set(reflect.Zero(target.Type()))
+	return bson.Unmarshal(raw, dst)
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by a
+// previous page of the same query and sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects one page of a list query. Results are ordered by Sort and then
+// by the collection's unique key, which keeps the order total so that a
+// cursor can resume exactly after the last item it saw.
+type Page struct {
+	Limit  int
+	Sort   string // bson field name; empty means the unique key
+	Desc   bool
+	Cursor string   // Next from the previous page; empty for the first page
+	Fields []string // bson fields to load; nil loads whole documents
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+func (p Page) sortField(key string) string {
+	if p.Sort == "" {
+		return key
+	}
+	return p.Sort
+}
+
+// pageCursor is the position after the last item of a page. It is handed to
+// clients as opaque base64 encoded bson.
+type pageCursor struct {
+	Sort  string      `bson:"s"`
+	Desc  bool        `bson:"d"`
+	Value interface{} `bson:"v"`
+	Key   interface{} `bson:"k"`
+}
+
+func (p Page) decodeCursor(key string) (*pageCursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := bson.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != p.sortField(key) || c.Desc != p.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// mongo adds the sort, limit, projection and cursor position of p to a query
+// on filter. One extra document is requested to tell whether a next page
+// exists.
+func (p Page) mongo(filter bson.M, key string) (bson.M, *options.FindOptions, error) {
+	opts := options.Find().SetLimit(int64(p.limit() + 1)).SetSort(p.sortDoc(key))
+	if projection := p.projection(key); projection != nil {
+		opts.SetProjection(projection)
+	}
+
+	c, err := p.decodeCursor(key)
+	if err != nil || c == nil {
+		return filter, opts, err
+	}
+	return bson.M{"$and": bson.A{filter, p.after(c, key)}}, opts, nil
+}
+
+// pipeline is the aggregation counterpart of mongo, for sorting on fields an
+// earlier stage computes, such as a text score. Its stages go after the
+// $match of the filter.
+func (p Page) pipeline(key string) (mongo.Pipeline, error) {
+	c, err := p.decodeCursor(key)
+	if err != nil {
+		return nil, err
+	}
+	var stages mongo.Pipeline
+	if c != nil {
+		stages = append(stages, bson.D{{Key: "$match", Value: p.after(c, key)}})
+	}
+	stages = append(stages,
+		bson.D{{Key: "$sort", Value: p.sortDoc(key)}},
+		bson.D{{Key: "$limit", Value: p.limit() + 1}},
+	)
+	if projection := p.projection(key); projection != nil {
+		stages = append(stages, bson.D{{Key: "$project", Value: projection}})
+	}
+	return stages, nil
+}
+
+func (p Page) sortDoc(key string) bson.D {
+	sortField := p.sortField(key)
+	dir := 1
+	if p.Desc {
+		dir = -1
+	}
+	if sortField == key {
+		return bson.D{{Key: key, Value: dir}}
+	}
+	return bson.D{{Key: sortField, Value: dir}, {Key: key, Value: dir}}
+}
+
+func (p Page) projection(key string) bson.M {
+	if p.Fields == nil {
+		return nil
+	}
+	projection := bson.M{p.sortField(key): 1, key: 1}
+	for _, f := range p.Fields {
+		projection[f] = 1
+	}
+	return projection
+}
+
+// after matches the documents that come after cursor c. Mongo sorts missing
+// and null values first but never matches them with $gt or $lt, so they get
+// clauses of their own: ascending they all come before any value, descending
+// they all come after one.
+func (p Page) after(c *pageCursor, key string) bson.M {
+	sortField := p.sortField(key)
+	op := "$gt"
+	if p.Desc {
+		op = "$lt"
+	}
+	if sortField == key {
+		return bson.M{key: bson.M{op: c.Key}}
+	}
+
+	tie := bson.M{sortField: c.Value, key: bson.M{op: c.Key}}
+	switch {
+	case c.Value == nil && p.Desc:
+		return tie
+	case c.Value == nil:
+		return bson.M{"$or": bson.A{bson.M{sortField: bson.M{"$ne": nil}}, tie}}
+	case p.Desc:
+		return bson.M{"$or": bson.A{bson.M{sortField: bson.M{op: c.Value}}, tie, bson.M{sortField: nil}}}
+	}
+	return bson.M{"$or": bson.A{bson.M{sortField: bson.M{op: c.Value}}, tie}}
+}
+
+// finishPage cuts items, fetched with one extra, down to the page limit and
+// returns the cursor for the following page, or "" on the last page.
+func finishPage[T any](items []T, p Page, key string) ([]T, string, error) {
+	limit := p.limit()
+	if len(items) <= limit {
+		return items, "", nil
+	}
+	items = items[:limit]
+
+	doc, err := toDoc(items[limit-1])
+	if err != nil {
+		return nil, "", err
+	}
+	sortField := p.sortField(key)
+	raw, err := bson.Marshal(pageCursor{Sort: sortField, Desc: p.Desc, Value: doc[sortField], Key: doc[key]})
+	if err != nil {
+		return nil, "", err
+	}
+	return items, base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// paginate is the in-memory counterpart of Page.mongo and finishPage for
+// items that already matched the filter.
+func paginate[T any](items []T, p Page, key string) ([]T, string, error) {
+	c, err := p.decodeCursor(key)
+	if err != nil {
+		return nil, "", err
+	}
+	sortField := p.sortField(key)
+
+	docs := make([]bson.M, len(items))
+	for i := range items {
+		if docs[i], err = toDoc(items[i]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// compare orders a before b in the requested direction
+	compare := func(aValue, aKey, bValue, bKey interface{}) int {
+		n := compareValues(aValue, bValue)
+		if n == 0 {
+			n = compareValues(aKey, bKey)
+		}
+		if p.Desc {
+			n = -n
+		}
+		return n
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := docs[order[i]], docs[order[j]]
+		return compare(a[sortField], a[key], b[sortField], b[key]) < 0
+	})
+
+	var page []T
+	for _, i := range order {
+		if c != nil && compare(docs[i][sortField], docs[i][key], c.Value, c.Key) <= 0 {
+			continue
+		}
+		page = append(page, items[i])
+		if len(page) > p.limit() {
+			break
+		}
+	}
+	return finishPage(page, p, key)
+}
+
+func toDoc(v interface{}) (bson.M, error) {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	return doc, bson.Unmarshal(raw, &doc)
+}
+
+// compareValues orders decoded bson values the way Mongo sorts the types the
+// models use. Missing values sort first.
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return compareOrdered(x, y)
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return compareOrdered(x, y)
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return compareOrdered(x, y)
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(x[:], y[:])
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case y:
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareOrdered(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func compareOrdered[T string | float64 | primitive.DateTime](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type pagedDoc struct {
+	ID     int32  `bson:"_id"`
+	Series string `bson:"series,omitempty"`
+}
+
+// matchQuery evaluates the subset of Mongo query operators Page.after uses.
+// Like Mongo, $gt and $lt never match a missing value and {field: nil}
+// matches a missing one.
+func matchQuery(doc bson.M, query bson.M) bool {
+	for field, cond := range query {
+		switch field {
+		case "$or":
+			matched := false
+			for _, clause := range cond.(bson.A) {
+				matched = matched || matchQuery(doc, clause.(bson.M))
+			}
+			if !matched {
+				return false
+			}
+			continue
+		case "$and":
+			for _, clause := range cond.(bson.A) {
+				if !matchQuery(doc, clause.(bson.M)) {
+					return false
+				}
+			}
+			continue
+		}
+
+		value := doc[field]
+		ops, ok := cond.(bson.M)
+		if !ok {
+			if compareValues(value, cond) != 0 {
+				return false
+			}
+			continue
+		}
+		for op, arg := range ops {
+			var matched bool
+			switch op {
+			case "$gt":
+				matched = value != nil && compareValues(value, arg) > 0
+			case "$lt":
+				matched = value != nil && compareValues(value, arg) < 0
+			case "$ne":
+				matched = compareValues(value, arg) != 0
+			}
+			if !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestPage_AfterMissingValues(t *testing.T) {
+	docs := []pagedDoc{{1, "b"}, {2, ""}, {3, "a"}, {4, ""}, {5, "b"}, {6, ""}, {7, "c"}}
+	raw := make([]bson.M, len(docs))
+	for i := range docs {
+		raw[i], _ = toDoc(docs[i])
+	}
+
+	for _, desc := range []bool{false, true} {
+		p := Page{Limit: 2, Sort: "series", Desc: desc}
+		sorted := append([]bson.M(nil), raw...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			n := compareValues(sorted[i]["series"], sorted[j]["series"])
+			if n == 0 {
+				n = compareValues(sorted[i]["_id"], sorted[j]["_id"])
+			}
+			return (n < 0) != desc
+		})
+
+		var seen []int32
+		for pages := 0; ; pages++ {
+			if pages > len(docs) {
+				t.Fatalf("desc=%v: paging did not end, saw %v", desc, seen)
+			}
+			c, err := p.decodeCursor("_id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var page []pagedDoc
+			for _, doc := range sorted {
+				if c != nil && !matchQuery(doc, p.after(c, "_id")) {
+					continue
+				}
+				if len(page) > p.limit() {
+					break
+				}
+				var d pagedDoc
+				b, _ := bson.Marshal(doc)
+				bson.Unmarshal(b, &d)
+				page = append(page, d)
+			}
+			page, next, err := finishPage(page, p, "_id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range page {
+				seen = append(seen, d.ID)
+			}
+			if next == "" {
+				break
+			}
+			p.Cursor = next
+		}
+
+		if len(seen) != len(sorted) {
+			t.Fatalf("desc=%v: paged through %v, want all %d documents", desc, seen, len(sorted))
+		}
+		for i, doc := range sorted {
+			if seen[i] != doc["_id"] {
+				t.Fatalf("desc=%v: paged through %v out of order", desc, seen)
+			}
+		}
+	}
+}
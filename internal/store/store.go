@@ -0,0 +1,108 @@
+// Package store holds the persistence layer used by the HTTP handlers. Every
+// collection is hidden behind an interface with a MongoDB implementation and
+// an in-memory implementation, so the API can run without a database for
+// tests and demos.
+package store
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNotFound is returned when no document matches a lookup or update.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when an insert violates a unique key.
+	ErrDuplicate = errors.New("duplicate key")
+	// ErrConflict is returned when a conditional update finds the document
+	// in a different state than the caller expected.
+	ErrConflict = errors.New("conflicting update")
+)
+
+// Stores bundles one store per collection.
+type Stores struct {
+	Books   BookStore
+	Copies  CopyStore
+	Members MemberStore
+	Loans   LoanStore
+	Holds   HoldStore
+	Fines   FineStore
+	Users   UserStore
+	Audit   AuditStore
+	// Suggestions is the trigram index behind book autocomplete
+	Suggestions SuggestionStore
+}
+
+// NewMongoStores returns stores backed by the collections of database.
+func NewMongoStores(database *mongo.Database) Stores {
+	return Stores{
+		Books:   NewMongoBookStore(database.Collection("books"), database.Collection("copies")),
+		Copies:  NewMongoCopyStore(database.Collection("copies")),
+		Members: NewMongoMemberStore(database.Collection("members")),
+		Loans:   NewMongoLoanStore(database.Collection("loans")),
+		Holds:   NewMongoHoldStore(database.Collection("holds")),
+		Fines:   NewMongoFineStore(database.Collection("fines")),
+		Users:   NewMongoUserStore(database.Collection("users")),
+		Audit:   NewMongoAuditStore(database.Collection("audit_logs")),
+
+		Suggestions: NewMongoSuggestionStore(database.Collection("suggestions")),
+	}
+}
+
+// NewMemoryStores returns empty in-memory stores.
+func NewMemoryStores() Stores {
+	books, copies := NewMemoryBookStore(), NewMemoryCopyStore()
+	books.Copies = copies
+	return Stores{
+		Books:   books,
+		Copies:  copies,
+		Members: NewMemoryMemberStore(),
+		Loans:   NewMemoryLoanStore(),
+		Holds:   NewMemoryHoldStore(),
+		Fines:   NewMemoryFineStore(),
+		Users:   NewMemoryUserStore(),
+		Audit:   NewMemoryAuditStore(),
+
+		Suggestions: NewMemorySuggestionStore(),
+	}
+}
+
+// Bool returns a pointer to b, for the optional flags of the filter types.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// updateDoc turns fields into a $set, moving the fields set to nil into an
+// $unset so that an update can also remove them.
+func updateDoc(fields map[string]interface{}) bson.M {
+	set, unset := bson.M{}, bson.M{}
+	for k, v := range fields {
+		if v == nil {
+			unset[k] = ""
+		} else {
+			set[k] = v
+		}
+	}
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+func mongoErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"open-library-explorer/internal/models"
+)
+
+// SuggestionMatch is an index entry with the number of query trigrams it
+// shares.
+type SuggestionMatch struct {
+	models.Suggestion `bson:",inline"`
+	Overlap           int `bson:"overlap"`
+}
+
+type SuggestionStore interface {
+	// Replace swaps the entries of a book for entries; nil removes them.
+	Replace(ctx context.Context, isbn string, entries []models.Suggestion) error
+	// Match returns up to limit entries sharing at least one of trigrams,
+	// the largest overlap first.
+	Match(ctx context.Context, trigrams []string, limit int) ([]SuggestionMatch, error)
+}
+
+type MongoSuggestionStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoSuggestionStore(coll *mongo.Collection) *MongoSuggestionStore {
+	return &MongoSuggestionStore{coll: coll}
+}
+
+func (s *MongoSuggestionStore) Replace(ctx context.Context, isbn string, entries []models.Suggestion) error {
+	if _, err := s.coll.DeleteMany(ctx, bson.M{"isbn": isbn}); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(entries))
+	for i := range entries {
+		docs[i] = entries[i]
+	}
+	_, err := s.coll.InsertMany(ctx, docs)
+	return mongoErr(err)
+}
+
+func (s *MongoSuggestionStore) Match(ctx context.Context, trigrams []string, limit int) ([]SuggestionMatch, error) {
+	cursor, err := s.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"trigrams": bson.M{"$in": trigrams}}}},
+		{{Key: "$addFields", Value: bson.M{"overlap": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$trigrams", trigrams}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "overlap", Value: -1}, {Key: "text", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var matches []SuggestionMatch
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+type MemorySuggestionStore struct {
+	mu      sync.RWMutex
+	entries []models.Suggestion
+}
+
+func NewMemorySuggestionStore() *MemorySuggestionStore {
+	return &MemorySuggestionStore{}
+}
+
+func (s *MemorySuggestionStore) Replace(_ context.Context, isbn string, entries []models.Suggestion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.entries[:0]
+	for _, e := range s.entries {
+		if e.ISBN != isbn {
+			kept = append(kept, e)
+		}
+	}
+	s.entries = append(kept, entries...)
+	return nil
+}
+
+func (s *MemorySuggestionStore) Match(_ context.Context, trigrams []string, limit int) ([]SuggestionMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []SuggestionMatch
+	for _, e := range s.entries {
+		overlap := 0
+		for _, gram := range uniqueStrings(e.Trigrams) {
+			if containsString(trigrams, gram) {
+				overlap++
+			}
+		}
+		if overlap > 0 {
+			matches = append(matches, SuggestionMatch{Suggestion: e, Overlap: overlap})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Overlap != matches[j].Overlap {
+			return matches[i].Overlap > matches[j].Overlap
+		}
+		return matches[i].Text < matches[j].Text
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// uniqueStrings drops repeats, as $setIntersection does.
+func uniqueStrings(list []string) []string {
+	var unique []string
+	for _, v := range list {
+		if !containsString(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"open-library-explorer/internal/models"
+)
+
+type UserStore interface {
+	// Insert stores user and assigns its ID when it has none. Usernames are
+	// unique; a taken one fails with ErrDuplicate.
+	Insert(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	Count(ctx context.Context) (int64, error)
+}
+
+type MongoUserStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoUserStore(coll *mongo.Collection) *MongoUserStore {
+	return &MongoUserStore{coll: coll}
+}
+
+func (s *MongoUserStore) Insert(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, user)
+	return mongoErr(err)
+}
+
+func (s *MongoUserStore) Get(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	var user models.User
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, mongoErr(err)
+}
+
+func (s *MongoUserStore) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := s.coll.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	return user, mongoErr(err)
+}
+
+func (s *MongoUserStore) Count(ctx context.Context) (int64, error) {
+	return s.coll.CountDocuments(ctx, bson.M{})
+}
+
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users []models.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{}
+}
+
+func (s *MemoryUserStore) Insert(_ context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	for _, u := range s.users {
+		if u.ID == user.ID || u.Username == user.Username {
+			return ErrDuplicate
+		}
+	}
+	s.users = append(s.users, *user)
+	return nil
+}
+
+func (s *MemoryUserStore) Get(_ context.Context, id primitive.ObjectID) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *MemoryUserStore) GetByUsername(_ context.Context, username string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *MemoryUserStore) Count(_ context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.users)), nil
+}
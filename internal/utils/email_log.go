@@ -3,8 +3,13 @@ package utils
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 func AppendToEmailLog(ctx context.Context, memberID string, barcode string) {
 	fmt.Printf("[EMAIL LOG] Notified member %s about reserved copy %s\n", memberID, barcode)
 }
+
+func NotifyMembershipExpiry(ctx context.Context, memberID string, expiry time.Time) {
+	fmt.Printf("[EMAIL LOG] Notified member %s that their membership expires on %s\n", memberID, expiry.Format("2006-01-02"))
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"open-library-explorer/internal/models"
 )
 
 var jwtSecret = []byte{} // set via env
@@ -13,18 +14,24 @@ func InitJwtSecret(secret string) {
 }
 
 type JWTClaims struct {
-	UserID string `json:"user_id"`
+	UserID   string      `json:"user_id"`
+	Role     models.Role `json:"role"`
+	MemberID string      `json:"member_id,omitempty"` // only for patrons
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID string) (string, error) {
+func GenerateJWT(user models.User) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
+		UserID: user.ID.Hex(),
+		Role:   user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if !user.MemberID.IsZero() {
+		claims.MemberID = user.MemberID.Hex()
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
@@ -4,22 +4,35 @@ import (
 	"context"
 	"time"
 
-	"go.mongodb.org/mongo-driver/mongo"
 	"open-library-explorer/internal/models"
+	"open-library-explorer/internal/store"
 )
 
 type Logger struct {
-	Collection *mongo.Collection
+	Store store.AuditStore
 }
 
+// Log records an audit entry. The actor, request ID and client IP are taken
+// from the RequestInfo on ctx; entries without an authenticated user are
+// attributed to SystemActor.
 func (l *Logger) Log(ctx context.Context, entity, action string, data any) error {
+	if l.Store == nil {
+		return nil
+	}
+	info := RequestInfoFrom(ctx)
+	performedBy := info.UserID
+	if performedBy == "" {
+		performedBy = SystemActor
+	}
 	log := models.AuditLog{
-		Timestamp: time.Now(),
-		Entity:    entity,
-		Action:    action,
-		Data:      data,
-		Exported:  false,
+		Timestamp:   time.Now(),
+		Entity:      entity,
+		Action:      action,
+		PerformedBy: performedBy,
+		RequestID:   info.RequestID,
+		ClientIP:    info.ClientIP,
+		Data:        data,
+		Exported:    false,
 	}
-	_, err := l.Collection.InsertOne(ctx, log)
-	return err
+	return l.Store.Insert(ctx, &log)
 }
@@ -0,0 +1,27 @@
+package utils
+
+import "context"
+
+// SystemActor is recorded as the actor of audit entries that do not stem from
+// an authenticated request, such as those written by the daemons.
+const SystemActor = "system"
+
+// RequestInfo describes who made a request and where it came from.
+type RequestInfo struct {
+	UserID    string
+	RequestID string
+	ClientIP  string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of ctx carrying info.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the RequestInfo stored on ctx, or the zero value.
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
@@ -7,10 +7,17 @@ import (
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // stable identifier for clients to act on
 }
 
 func JSONError(w http.ResponseWriter, message string, status int) {
+	JSONErrorCode(w, message, "", status)
+}
+
+// JSONErrorCode is JSONError with a code clients can match on instead of the
+// message.
+func JSONErrorCode(w http.ResponseWriter, message, code string, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message, Code: code})
 }